@@ -5,11 +5,15 @@ import (
 	"crypto/rsa"
 	"fmt"
 	_ "net/http/pprof"
+	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/Mr-Filatik/go-metrics-collector/internal/agent/collector"
 	config "github.com/Mr-Filatik/go-metrics-collector/internal/agent/config"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/agent/metric"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/agent/queue"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/agent/reporter"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/agent/updater"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/client"
@@ -51,6 +55,10 @@ func main() {
 		panic(err)
 	}
 
+	if conf.HostLabels {
+		metrics.SetLabels(getHostLabels(realIP))
+	}
+
 	// Привязка сигналов ОС к контексту
 	exitCtx, exitFn := signal.NotifyContext(
 		context.Background(),
@@ -66,6 +74,7 @@ func main() {
 		PublicKey: key,
 		URL:       conf.ServerAddress,
 		XRealIP:   realIP,
+		AgentID:   conf.AgentID,
 		HashKey:   conf.HashKey,
 	}
 	mainClient = client.NewRestyClient(clientConfig, log)
@@ -75,27 +84,58 @@ func main() {
 		addConfig := &client.GrpcClientConfig{
 			URL:     conf.ServerAddress,
 			XRealIP: realIP,
+			AgentID: conf.AgentID,
 			HashKey: conf.HashKey,
+			Stream:  conf.GrpcStream,
 		}
 		addClient := client.NewGrpcClient(addConfig, log)
 
 		mainClient = client.NewAllClient(mainClient, addClient)
 	}
 
+	// Выбор сборщиков метрик
+	registry := collector.NewDefaultRegistry(time.Duration(conf.PollInterval) * time.Second)
+	if targets := collector.ParseNames(conf.Processes); len(targets) > 0 {
+		if err := registry.Register(collector.NewProcess(time.Duration(conf.PollInterval)*time.Second, targets)); err != nil {
+			log.Error("Register collector error", err)
+			return
+		}
+	}
+	collectors, err := registry.Select(
+		collector.ParseNames(conf.Collectors),
+		collector.ParseNames(conf.CollectorsDisabled))
+	if err != nil {
+		log.Error("Select collectors error", err, "available", registry.Names())
+		return
+	}
+
+	// Создание очереди отправки на диске
+	var outQueue *queue.DiskQueue
+	if conf.QueuePath != "" {
+		q, err := queue.New(conf.QueuePath, conf.QueueMaxSize, time.Duration(conf.QueueMaxAge)*time.Second, log)
+		if err != nil {
+			log.Error("Open queue error", err)
+			return
+		}
+		outQueue = q
+	}
+
 	startErr := mainClient.Start(exitCtx)
 	if startErr != nil {
 		log.Error("Start client error", startErr)
 		return
 	}
 
-	go updater.Run(exitCtx, metrics, conf.PollInterval)
-	go updater.RunMemory(exitCtx, metrics, conf.PollInterval)
+	for _, c := range collectors {
+		go updater.Run(exitCtx, c, metrics, log)
+	}
 	go reporter.Run(
 		exitCtx,
 		metrics,
 		conf.ReportInterval,
 		conf.RateLimit,
 		mainClient,
+		outQueue,
 		log)
 
 	// Ожидание сигнала остановки
@@ -115,3 +155,11 @@ func getExternalRealIP() (string, error) {
 
 	return string(resp.Body()), nil
 }
+
+func getHostLabels(realIP string) map[string]string {
+	labels := map[string]string{"ip": realIP}
+	if host, err := os.Hostname(); err == nil {
+		labels["host"] = host
+	}
+	return labels
+}
@@ -4,18 +4,25 @@ import (
 	"context"
 	"crypto/rsa"
 	"fmt"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	crypto "github.com/Mr-Filatik/go-metrics-collector/internal/crypto/rsa"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
 	logger "github.com/Mr-Filatik/go-metrics-collector/internal/logger/zap/sugar"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/repository"
 	repositoryMemory "github.com/Mr-Filatik/go-metrics-collector/internal/repository/memory"
-	repositoryPostgres "github.com/Mr-Filatik/go-metrics-collector/internal/repository/postgres"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/server"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/server/alerting"
 	config "github.com/Mr-Filatik/go-metrics-collector/internal/server/config"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/server/idempotency"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/service"
-	storage "github.com/Mr-Filatik/go-metrics-collector/internal/storage/file"
+
+	// Пакеты репозиториев регистрируют свои схемы строки подключения.
+	_ "github.com/Mr-Filatik/go-metrics-collector/internal/repository/postgres"
+	_ "github.com/Mr-Filatik/go-metrics-collector/internal/repository/sqlite"
 
 	// Installing the gzip encoding registers it as an available compressor.
 	// The gRPC will automatically negotiate and use gzip if the client supports it.
@@ -41,6 +48,13 @@ func main() {
 	log.Info(fmt.Sprintf("Build date: %v", buildDate))
 	log.Info(fmt.Sprintf("Build commit: %v", buildCommit))
 
+	if len(os.Args) > 1 && os.Args[1] == migrateCommand {
+		if err := runMigrate(os.Args[2:], log); err != nil {
+			panic(err.Error())
+		}
+		return
+	}
+
 	conf := config.Initialize()
 
 	var key *rsa.PrivateKey = nil
@@ -52,19 +66,45 @@ func main() {
 		key = k
 	}
 
-	var srvc *service.Service
-	if conf.ConnectionString != "" {
-		repo, err := repositoryPostgres.New(conf.ConnectionString, log)
-		if err != nil {
-			panic(err.Error())
+	dsn := conf.ConnectionString
+	if dsn == "" {
+		// без строки подключения метрики хранятся в памяти и сохраняются в файл
+		scheme := repositoryMemory.FileScheme
+		if conf.StoreWAL {
+			scheme = repositoryMemory.WALScheme
+		}
+		dsn = repositoryMemory.FileDSN(scheme, conf.FileStoragePath, int(conf.StoreGenerations), conf.StoreFormat)
+	}
+	repo, err := repository.Open(dsn, log)
+	if err != nil {
+		log.Error("Open repository error", err)
+		return
+	}
+	defer repo.Close()
+
+	srvc := service.New(repo.Repository, repo.Storage, conf.StoreInterval, log)
+	if conf.HistoryEnabled {
+		if history, ok := repo.Repository.(repository.HistoryRepository); ok {
+			srvc.SetHistory(history)
+		} else {
+			log.Warn("Metric history is not supported by repository", nil, "scheme", repository.Scheme(dsn))
 		}
-		defer repo.Close()
-		srvc = service.New(repo, nil, 0, log)
-	} else {
-		repo := repositoryMemory.New(conf.ConnectionString, log)
-		stor := storage.New(conf.FileStoragePath, log)
-		srvc = service.New(repo, stor, conf.StoreInterval, log)
 	}
+	buckets, err := entity.ParseHistogramBounds(conf.HistogramBuckets)
+	if err != nil {
+		log.Error("Parse histogram buckets error", err)
+		return
+	}
+	srvc.SetHistogramBuckets(buckets)
+	retentionRules, err := service.ParseRetentionRules(conf.RetentionRules)
+	if err != nil {
+		log.Error("Parse retention rules error", err)
+		return
+	}
+	srvc.SetRetention(service.RetentionPolicy{
+		Rules:   retentionRules,
+		Default: time.Duration(conf.Retention) * time.Second,
+	})
 	srvc.Start(conf.Restore)
 	defer srvc.Stop()
 
@@ -76,6 +116,23 @@ func main() {
 		syscall.SIGQUIT)
 	defer exitFn()
 
+	// Хранилище ключей идемпотентности, общее для HTTP и gRPC серверов
+	var idempotencyStore *idempotency.Store
+	if conf.IdempotencyWindow > 0 {
+		idempotencyStore = idempotency.New(time.Duration(conf.IdempotencyWindow) * time.Second)
+	}
+
+	// Запуск вычисления правил оповещений
+	if conf.AlertRulesPath != "" {
+		alertConf, aerr := alerting.LoadConfig(conf.AlertRulesPath)
+		if aerr != nil {
+			log.Error("Load alert rules error", aerr)
+			return
+		}
+		engine := alerting.New(alertConf, srvc, alerting.NewSinks(alertConf.Sinks, log), log)
+		go engine.Run(exitCtx)
+	}
+
 	var mainServer server.Server
 
 	// Создание и запуск HTTP сервера
@@ -85,6 +142,7 @@ func main() {
 		HashKey:       conf.HashKey,
 		TrustedSubnet: conf.TrustedSubnet,
 		PrivateRsaKey: key,
+		Idempotency:   idempotencyStore,
 	}
 	mainServer = server.NewHTTPServer(exitCtx, servConf, log)
 
@@ -104,6 +162,7 @@ func main() {
 			HashKey:       conf.HashKey,
 			TrustedSubnet: conf.TrustedSubnet,
 			PrivateRsaKey: key,
+			Idempotency:   idempotencyStore,
 		}
 		grpcServer = server.NewGrpcServer(exitCtx, grpcConf, log)
 
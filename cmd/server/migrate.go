@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/repository"
+	repositoryPostgres "github.com/Mr-Filatik/go-metrics-collector/internal/repository/postgres"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/repository/postgres/migrations"
+	config "github.com/Mr-Filatik/go-metrics-collector/internal/server/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Константы - подкоманда migrate и её действия.
+const (
+	migrateCommand = "migrate" // подкоманда управления миграциями схемы
+	migrateUp      = "up"      // применить все неприменённые миграции
+	migrateDown    = "down"    // откатить последние миграции (по умолчанию одну)
+	migrateStatus  = "status"  // вывести состояние миграций
+)
+
+// runMigrate выполняет подкоманду migrate:
+//
+//	server migrate up [флаги]
+//	server migrate down [количество] [флаги]
+//	server migrate status [флаги]
+//
+// Строка подключения к базе данных задаётся так же, как при обычном запуске сервера.
+//
+// Параметры:
+//   - args: аргументы после имени подкоманды
+//   - log: логгер
+func runMigrate(args []string, log logger.Logger) error {
+	action, steps, flagArgs, err := parseMigrateArgs(args)
+	if err != nil {
+		return err
+	}
+
+	conf := config.InitializeFromArgs(flagArgs)
+	if conf.ConnectionString == "" {
+		return errors.New("database connection string is not set")
+	}
+	if scheme := repository.Scheme(conf.ConnectionString); scheme != repositoryPostgres.Scheme &&
+		scheme != repositoryPostgres.SchemeAlias {
+		return fmt.Errorf("migrate supports only postgres repository, got scheme %q", scheme)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer cancel()
+
+	pool, err := pgxpool.New(ctx, conf.ConnectionString)
+	if err != nil {
+		return fmt.Errorf("connect to database error: %w", err)
+	}
+	defer pool.Close()
+
+	migrator, err := migrations.New(pool, log)
+	if err != nil {
+		return fmt.Errorf("load migrations error: %w", err)
+	}
+
+	switch action {
+	case migrateUp:
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			return fmt.Errorf("apply migrations error: %w", err)
+		}
+		log.Info("Migrations applied", "count", n)
+	case migrateDown:
+		n, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return fmt.Errorf("revert migrations error: %w", err)
+		}
+		log.Info("Migrations reverted", "count", n)
+	case migrateStatus:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("get migrations status error: %w", err)
+		}
+		for _, s := range statuses {
+			log.Info("Migration", "version", s.Version, "name", s.Name, "applied", s.Applied, "applied_at", s.AppliedAt)
+		}
+	}
+	return nil
+}
+
+// parseMigrateArgs разбирает аргументы подкоманды migrate: действие, количество откатываемых миграций
+// (только для down, по умолчанию 1) и оставшиеся флаги конфигурации сервера.
+func parseMigrateArgs(args []string) (string, int, []string, error) {
+	if len(args) == 0 {
+		return "", 0, nil, errors.New("migrate action is required: up, down or status")
+	}
+
+	action, rest := args[0], args[1:]
+	switch action {
+	case migrateUp, migrateStatus:
+		return action, 0, rest, nil
+	case migrateDown:
+		steps := 1
+		if len(rest) > 0 {
+			if n, err := strconv.Atoi(rest[0]); err == nil {
+				if n < 1 {
+					return "", 0, nil, fmt.Errorf("invalid number of steps %d", n)
+				}
+				steps, rest = n, rest[1:]
+			}
+		}
+		return action, steps, rest, nil
+	default:
+		return "", 0, nil, fmt.Errorf("unknown migrate action %q", action)
+	}
+}
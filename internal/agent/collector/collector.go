@@ -0,0 +1,170 @@
+// Пакет collector предоставляет интерфейс источников метрик агента и их реестр.
+// Собственный сборщик достаточно реализовать интерфейсом Collector и зарегистрировать в Registry.
+package collector
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+)
+
+// Ошибки реестра сборщиков.
+var (
+	ErrDuplicateCollector = errors.New("collector already registered")
+	ErrUnknownCollector   = errors.New("unknown collector")
+)
+
+// Collector - источник метрик агента.
+type Collector interface {
+	// Name возвращает уникальное имя сборщика (используется в конфигурации).
+	Name() string
+	// Interval возвращает интервал опроса сборщика.
+	Interval() time.Duration
+	// Collect собирает текущие значения метрик.
+	// Для gauge возвращается значение, для counter - прирост с прошлого вызова.
+	Collect(ctx context.Context) ([]entity.Metrics, error)
+}
+
+// Registry хранит зарегистрированные сборщики в порядке регистрации.
+type Registry struct {
+	collectors []Collector
+}
+
+// NewRegistry создаёт пустой реестр сборщиков.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register добавляет сборщик в реестр.
+//
+// Параметры:
+//   - c: сборщик с уникальным именем
+func (r *Registry) Register(c Collector) error {
+	if r.get(c.Name()) != nil {
+		return errors.New(ErrDuplicateCollector.Error() + ": " + c.Name())
+	}
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+// Names возвращает имена всех зарегистрированных сборщиков.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		names = append(names, c.Name())
+	}
+	return names
+}
+
+// Select возвращает сборщики, которые нужно запустить.
+// Пустой список enabled означает все зарегистрированные сборщики,
+// после чего из них исключаются перечисленные в disabled.
+//
+// Параметры:
+//   - enabled: имена включаемых сборщиков
+//   - disabled: имена отключаемых сборщиков
+func (r *Registry) Select(enabled, disabled []string) ([]Collector, error) {
+	for _, name := range slices.Concat(enabled, disabled) {
+		if r.get(name) == nil {
+			return nil, errors.New(ErrUnknownCollector.Error() + ": " + name)
+		}
+	}
+
+	selected := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		if len(enabled) > 0 && !slices.Contains(enabled, c.Name()) {
+			continue
+		}
+		if slices.Contains(disabled, c.Name()) {
+			continue
+		}
+		selected = append(selected, c)
+	}
+	return selected, nil
+}
+
+func (r *Registry) get(name string) Collector {
+	for _, c := range r.collectors {
+		if c.Name() == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// ParseNames разбирает список имён сборщиков вида "runtime,system".
+//
+// Параметры:
+//   - s: имена через запятую
+func ParseNames(s string) []string {
+	var names []string
+	for _, p := range strings.Split(s, ",") {
+		if name := strings.TrimSpace(p); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// gauge создаёт gauge метрику.
+func gauge(name string, value float64) entity.Metrics {
+	return entity.Metrics{ID: name, MType: entity.Gauge, Value: &value}
+}
+
+// counter создаёт counter метрику с приростом delta.
+func counter(name string, delta int64) entity.Metrics {
+	return entity.Metrics{ID: name, MType: entity.Counter, Delta: &delta}
+}
+
+// NewDefaultRegistry создаёт реестр со встроенными сборщиками агента.
+//
+// Параметры:
+//   - interval: интервал опроса встроенных сборщиков
+func NewDefaultRegistry(interval time.Duration) *Registry {
+	r := NewRegistry()
+	r.collectors = append(r.collectors,
+		NewRuntime(interval),
+		NewSystem(interval),
+		NewCPU(interval),
+		NewLoad(interval),
+		NewDisk(interval),
+		NewNet(interval),
+	)
+	return r
+}
+
+// deltas превращает накопительные счётчики ОС (байты, пакеты, операции) в приросты counter метрик.
+// Используется из горутины одного сборщика, поэтому не защищён от конкурентного доступа.
+type deltas struct {
+	prev map[string]uint64 // предыдущие значения по ключу метрики
+}
+
+func newDeltas() *deltas {
+	return &deltas{prev: map[string]uint64{}}
+}
+
+// counter возвращает counter метрику с приростом total с прошлого вызова.
+// При первом наблюдении прирост неизвестен и метрика не возвращается (ok = false).
+// Если значение уменьшилось (счётчик сброшен), приростом считается само значение.
+func (d *deltas) counter(name string, labels map[string]string, total uint64) (entity.Metrics, bool) {
+	m := counter(name, 0)
+	m.Labels = labels
+
+	key := m.Key()
+	prev, seen := d.prev[key]
+	d.prev[key] = total
+	if !seen {
+		return m, false
+	}
+
+	delta := total
+	if total >= prev {
+		delta = total - prev
+	}
+	*m.Delta = int64(delta) //nolint:gosec // прирост за интервал опроса не превышает int64
+	return m, true
+}
@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubCollector struct {
+	name string
+}
+
+func (c *stubCollector) Name() string            { return c.name }
+func (c *stubCollector) Interval() time.Duration { return time.Second }
+func (c *stubCollector) Collect(ctx context.Context) ([]entity.Metrics, error) {
+	return []entity.Metrics{gauge(c.name, 1)}, nil
+}
+
+func names(cs []Collector) []string {
+	res := make([]string, 0, len(cs))
+	for _, c := range cs {
+		res = append(res, c.Name())
+	}
+	return res
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+
+	require.NoError(t, r.Register(&stubCollector{name: "a"}))
+	require.NoError(t, r.Register(&stubCollector{name: "b"}))
+	require.ErrorContains(t, r.Register(&stubCollector{name: "a"}), ErrDuplicateCollector.Error())
+
+	assert.Equal(t, []string{"a", "b"}, r.Names())
+}
+
+func TestRegistry_Select(t *testing.T) {
+	r := NewRegistry()
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, r.Register(&stubCollector{name: name}))
+	}
+
+	tests := []struct {
+		name     string
+		enabled  []string
+		disabled []string
+		expected []string
+		wantErr  bool
+	}{
+		{name: "all by default", expected: []string{"a", "b", "c"}},
+		{name: "enabled only", enabled: []string{"c", "a"}, expected: []string{"a", "c"}},
+		{name: "disabled", disabled: []string{"b"}, expected: []string{"a", "c"}},
+		{name: "enabled and disabled", enabled: []string{"a", "b"}, disabled: []string{"b"}, expected: []string{"a"}},
+		{name: "unknown enabled", enabled: []string{"x"}, wantErr: true},
+		{name: "unknown disabled", disabled: []string{"x"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, err := r.Select(tt.enabled, tt.disabled)
+			if tt.wantErr {
+				require.ErrorContains(t, err, ErrUnknownCollector.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, names(cs))
+		})
+	}
+}
+
+func TestParseNames(t *testing.T) {
+	assert.Nil(t, ParseNames(""))
+	assert.Equal(t, []string{"runtime", "system"}, ParseNames(" runtime, ,system "))
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	r := NewDefaultRegistry(time.Second)
+	assert.Equal(t, []string{"runtime", "system", "cpu", "load", "disk", "net"}, r.Names())
+}
+
+func TestDeltas(t *testing.T) {
+	d := newDeltas()
+	labels := map[string]string{"interface": "eth0"}
+
+	_, ok := d.counter("NetBytesRecv", labels, 100)
+	assert.False(t, ok, "first observation has no delta")
+
+	m, ok := d.counter("NetBytesRecv", labels, 150)
+	require.True(t, ok)
+	assert.Equal(t, entity.Counter, m.MType)
+	assert.Equal(t, labels, m.Labels)
+	assert.Equal(t, int64(50), *m.Delta)
+
+	_, ok = d.counter("NetBytesRecv", map[string]string{"interface": "eth1"}, 10)
+	assert.False(t, ok, "series are tracked by labels")
+
+	m, ok = d.counter("NetBytesRecv", labels, 20)
+	require.True(t, ok)
+	assert.Equal(t, int64(20), *m.Delta, "reset counter reports its new value")
+}
+
+func TestLoadCollector(t *testing.T) {
+	ms, err := NewLoad(time.Second).Collect(context.Background())
+	if err != nil {
+		t.Skipf("load average is not available: %v", err)
+	}
+
+	ids := make([]string, 0, len(ms))
+	for _, m := range ms {
+		assert.Equal(t, entity.Gauge, m.MType)
+		ids = append(ids, m.ID)
+	}
+	assert.Equal(t, []string{"Load1", "Load5", "Load15"}, ids)
+}
+
+func TestRuntimeCollector(t *testing.T) {
+	c := NewRuntime(2 * time.Second)
+	assert.Equal(t, 2*time.Second, c.Interval())
+
+	ms, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	byName := map[string]entity.Metrics{}
+	for _, m := range ms {
+		byName[m.ID] = m
+	}
+
+	require.Contains(t, byName, "Alloc")
+	assert.Equal(t, entity.Gauge, byName["Alloc"].MType)
+	assert.Greater(t, *byName["Alloc"].Value, 0.0)
+
+	require.Contains(t, byName, "RandomValue")
+	assert.GreaterOrEqual(t, *byName["RandomValue"].Value, 0.0)
+	assert.Less(t, *byName["RandomValue"].Value, 1.0)
+
+	require.Contains(t, byName, "PollCount")
+	assert.Equal(t, entity.Counter, byName["PollCount"].MType)
+	assert.Equal(t, int64(1), *byName["PollCount"].Delta)
+}
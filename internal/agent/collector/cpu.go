@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// CPUCollector собирает загрузку каждого ядра процессора (метрика CPUutilization с меткой core).
+type CPUCollector struct {
+	interval time.Duration // интервал опроса
+}
+
+// NewCPU создаёт сборщик "cpu".
+//
+// Параметры:
+//   - interval: интервал опроса
+func NewCPU(interval time.Duration) *CPUCollector {
+	return &CPUCollector{interval: interval}
+}
+
+// Name возвращает имя сборщика.
+func (c *CPUCollector) Name() string {
+	return "cpu"
+}
+
+// Interval возвращает интервал опроса.
+func (c *CPUCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect собирает загрузку ядер (в процентах) с момента предыдущего вызова.
+func (c *CPUCollector) Collect(ctx context.Context) ([]entity.Metrics, error) {
+	percents, err := cpu.PercentWithContext(ctx, 0, true)
+	if err != nil {
+		return nil, fmt.Errorf("read cpu utilization error: %w", err)
+	}
+
+	metrics := make([]entity.Metrics, 0, len(percents))
+	for i, p := range percents {
+		m := gauge("CPUutilization", p)
+		m.Labels = map[string]string{"core": strconv.Itoa(i)}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
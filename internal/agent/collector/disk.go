@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// DiskCollector собирает занятость каждой точки монтирования (метка mountpoint)
+// и приросты операций ввода-вывода каждого устройства (метка device).
+type DiskCollector struct {
+	io       *deltas       // предыдущие значения счётчиков ввода-вывода
+	interval time.Duration // интервал опроса
+}
+
+// NewDisk создаёт сборщик "disk".
+//
+// Параметры:
+//   - interval: интервал опроса
+func NewDisk(interval time.Duration) *DiskCollector {
+	return &DiskCollector{io: newDeltas(), interval: interval}
+}
+
+// Name возвращает имя сборщика.
+func (c *DiskCollector) Name() string {
+	return "disk"
+}
+
+// Interval возвращает интервал опроса.
+func (c *DiskCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect собирает DiskTotal, DiskUsed, DiskFree, DiskUsedPercent для точек монтирования
+// и DiskReadBytes, DiskWriteBytes, DiskReadCount, DiskWriteCount для устройств.
+// Недоступные точки монтирования пропускаются.
+func (c *DiskCollector) Collect(ctx context.Context) ([]entity.Metrics, error) {
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("read disk partitions error: %w", err)
+	}
+
+	var metrics []entity.Metrics
+	for _, p := range partitions {
+		usage, err := disk.UsageWithContext(ctx, p.Mountpoint)
+		if err != nil {
+			continue
+		}
+		labels := map[string]string{"mountpoint": p.Mountpoint}
+		for _, m := range []entity.Metrics{
+			gauge("DiskTotal", float64(usage.Total)),
+			gauge("DiskUsed", float64(usage.Used)),
+			gauge("DiskFree", float64(usage.Free)),
+			gauge("DiskUsedPercent", usage.UsedPercent),
+		} {
+			m.Labels = labels
+			metrics = append(metrics, m)
+		}
+	}
+
+	counters, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		return metrics, fmt.Errorf("read disk io counters error: %w", err)
+	}
+	for device, io := range counters {
+		labels := map[string]string{"device": device}
+		for name, total := range map[string]uint64{
+			"DiskReadBytes":  io.ReadBytes,
+			"DiskWriteBytes": io.WriteBytes,
+			"DiskReadCount":  io.ReadCount,
+			"DiskWriteCount": io.WriteCount,
+		} {
+			if m, ok := c.io.counter(name, labels, total); ok {
+				metrics = append(metrics, m)
+			}
+		}
+	}
+
+	return metrics, nil
+}
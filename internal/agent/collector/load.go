@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/shirou/gopsutil/v3/load"
+)
+
+// LoadCollector собирает среднюю загрузку системы за 1, 5 и 15 минут.
+type LoadCollector struct {
+	interval time.Duration // интервал опроса
+}
+
+// NewLoad создаёт сборщик "load".
+//
+// Параметры:
+//   - interval: интервал опроса
+func NewLoad(interval time.Duration) *LoadCollector {
+	return &LoadCollector{interval: interval}
+}
+
+// Name возвращает имя сборщика.
+func (c *LoadCollector) Name() string {
+	return "load"
+}
+
+// Interval возвращает интервал опроса.
+func (c *LoadCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect собирает Load1, Load5 и Load15.
+func (c *LoadCollector) Collect(ctx context.Context) ([]entity.Metrics, error) {
+	avg, err := load.AvgWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read load average error: %w", err)
+	}
+
+	return []entity.Metrics{
+		gauge("Load1", avg.Load1),
+		gauge("Load5", avg.Load5),
+		gauge("Load15", avg.Load15),
+	}, nil
+}
@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// NetCollector собирает приросты переданных байт и пакетов каждого сетевого интерфейса (метка interface).
+type NetCollector struct {
+	io       *deltas       // предыдущие значения счётчиков интерфейсов
+	interval time.Duration // интервал опроса
+}
+
+// NewNet создаёт сборщик "net".
+//
+// Параметры:
+//   - interval: интервал опроса
+func NewNet(interval time.Duration) *NetCollector {
+	return &NetCollector{io: newDeltas(), interval: interval}
+}
+
+// Name возвращает имя сборщика.
+func (c *NetCollector) Name() string {
+	return "net"
+}
+
+// Interval возвращает интервал опроса.
+func (c *NetCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect собирает NetBytesSent, NetBytesRecv, NetPacketsSent и NetPacketsRecv.
+// При первом вызове приросты неизвестны, и метрики не возвращаются.
+func (c *NetCollector) Collect(ctx context.Context) ([]entity.Metrics, error) {
+	counters, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("read network io counters error: %w", err)
+	}
+
+	var metrics []entity.Metrics
+	for _, io := range counters {
+		labels := map[string]string{"interface": io.Name}
+		for name, total := range map[string]uint64{
+			"NetBytesSent":   io.BytesSent,
+			"NetBytesRecv":   io.BytesRecv,
+			"NetPacketsSent": io.PacketsSent,
+			"NetPacketsRecv": io.PacketsRecv,
+		} {
+			if m, ok := c.io.counter(name, labels, total); ok {
+				metrics = append(metrics, m)
+			}
+		}
+	}
+
+	return metrics, nil
+}
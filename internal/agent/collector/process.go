@@ -0,0 +1,222 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// Константы для определения процессов.
+const (
+	pidFileSuffix string = ".pid" // суффикс PID-файла
+)
+
+// ProcessCollector собирает метрики выбранных процессов хоста (метка process):
+// ProcessCPUPercent, ProcessRSS, ProcessOpenFDs, ProcessThreads и ProcessUptime.
+// Процесс задаётся именем или путём до PID-файла; после перезапуска процесса
+// (новый PID) он находится заново при следующем опросе.
+// ProcessCPUPercent считается между опросами, поэтому при первом наблюдении процесса не возвращается.
+type ProcessCollector struct {
+	now      func() time.Time            // источник текущего времени
+	tracked  map[string]*process.Process // найденные процессы по цели
+	cpuBase  map[string]*process.Process // процессы по цели, для которых снят первый замер CPU
+	targets  []string                    // имена процессов или пути до PID-файлов
+	interval time.Duration               // интервал опроса
+}
+
+// NewProcess создаёт сборщик "process".
+//
+// Параметры:
+//   - interval: интервал опроса
+//   - targets: имена процессов или пути до PID-файлов (содержат "/" или оканчиваются на ".pid")
+func NewProcess(interval time.Duration, targets []string) *ProcessCollector {
+	return &ProcessCollector{
+		now:      time.Now,
+		tracked:  map[string]*process.Process{},
+		cpuBase:  map[string]*process.Process{},
+		targets:  targets,
+		interval: interval,
+	}
+}
+
+// Name возвращает имя сборщика.
+func (c *ProcessCollector) Name() string {
+	return "process"
+}
+
+// Interval возвращает интервал опроса.
+func (c *ProcessCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect собирает метрики всех найденных процессов.
+// Ненайденные процессы пропускаются, ошибки по ним объединяются в возвращаемую ошибку.
+func (c *ProcessCollector) Collect(ctx context.Context) ([]entity.Metrics, error) {
+	var metrics []entity.Metrics
+	var errs []error
+	for _, target := range c.targets {
+		p, err := c.resolve(ctx, target)
+		if err != nil {
+			c.forget(target)
+			errs = append(errs, fmt.Errorf("process %s: %w", target, err))
+			continue
+		}
+		ms, err := c.collectProcess(ctx, target, p)
+		if err != nil {
+			c.forget(target)
+			errs = append(errs, fmt.Errorf("process %s: %w", target, err))
+			continue
+		}
+		metrics = append(metrics, ms...)
+	}
+	return metrics, errors.Join(errs...)
+}
+
+// collectProcess собирает метрики одного процесса.
+func (c *ProcessCollector) collectProcess(
+	ctx context.Context,
+	target string,
+	p *process.Process,
+) ([]entity.Metrics, error) {
+	name := target
+	if isPIDFile(target) {
+		n, err := p.NameWithContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("read name error: %w", err)
+		}
+		name = n
+	}
+
+	cpuPercent, err := p.PercentWithContext(ctx, 0)
+	if err != nil {
+		return nil, fmt.Errorf("read cpu percent error: %w", err)
+	}
+	memInfo, err := p.MemoryInfoWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read memory info error: %w", err)
+	}
+	threads, err := p.NumThreadsWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read threads error: %w", err)
+	}
+	created, err := p.CreateTimeWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read create time error: %w", err)
+	}
+
+	metrics := []entity.Metrics{
+		gauge("ProcessRSS", float64(memInfo.RSS)),
+		gauge("ProcessThreads", float64(threads)),
+		gauge("ProcessUptime", c.now().Sub(time.UnixMilli(created)).Seconds()),
+	}
+	// первый вызов Percent для процесса только запоминает время CPU и возвращает 0
+	if c.cpuBase[target] == p {
+		metrics = append(metrics, gauge("ProcessCPUPercent", cpuPercent))
+	}
+	c.cpuBase[target] = p
+	// количество дескрипторов доступно не на всех платформах и не для чужих процессов
+	if fds, err := p.NumFDsWithContext(ctx); err == nil {
+		metrics = append(metrics, gauge("ProcessOpenFDs", float64(fds)))
+	}
+
+	for i := range metrics {
+		metrics[i].Labels = map[string]string{"process": name}
+	}
+	return metrics, nil
+}
+
+// forget забывает найденный процесс цели и его замер CPU.
+func (c *ProcessCollector) forget(target string) {
+	delete(c.tracked, target)
+	delete(c.cpuBase, target)
+}
+
+// resolve возвращает процесс цели: ранее найденный, если он всё ещё работает, иначе ищет заново.
+func (c *ProcessCollector) resolve(ctx context.Context, target string) (*process.Process, error) {
+	if isPIDFile(target) {
+		pid, err := readPIDFile(target)
+		if err != nil {
+			return nil, err
+		}
+		if p, ok := c.tracked[target]; ok && p.Pid == pid && isRunning(ctx, p) {
+			return p, nil
+		}
+		p, err := process.NewProcessWithContext(ctx, pid)
+		if err != nil {
+			return nil, fmt.Errorf("find process error: %w", err)
+		}
+		c.tracked[target] = p
+		return p, nil
+	}
+
+	if p, ok := c.tracked[target]; ok && isRunning(ctx, p) {
+		if name, err := p.NameWithContext(ctx); err == nil && name == target {
+			return p, nil
+		}
+	}
+	p, err := findByName(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	c.tracked[target] = p
+	return p, nil
+}
+
+// findByName ищет процесс по имени. Если процессов несколько, выбирается самый старый
+// (обычно это главный процесс сервиса, запускающий остальные).
+func findByName(ctx context.Context, name string) (*process.Process, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list processes error: %w", err)
+	}
+
+	var found *process.Process
+	var foundCreated int64
+	for _, p := range procs {
+		n, err := p.NameWithContext(ctx)
+		if err != nil || n != name {
+			continue
+		}
+		created, err := p.CreateTimeWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		if found == nil || created < foundCreated {
+			found, foundCreated = p, created
+		}
+	}
+	if found == nil {
+		return nil, errors.New("process not found")
+	}
+	return found, nil
+}
+
+// readPIDFile читает PID из файла.
+func readPIDFile(path string) (int32, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("read pid file error: %w", err)
+	}
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("parse pid file error: %w", err)
+	}
+	return int32(pid), nil
+}
+
+func isRunning(ctx context.Context, p *process.Process) bool {
+	running, err := p.IsRunningWithContext(ctx)
+	return err == nil && running
+}
+
+func isPIDFile(target string) bool {
+	return strings.ContainsRune(target, filepath.Separator) || strings.HasSuffix(target, pidFileSuffix)
+}
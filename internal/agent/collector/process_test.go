@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePIDFile(t *testing.T, path string, pid int) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(strconv.Itoa(pid)+"\n"), 0o600))
+}
+
+func metricsByID(ms []entity.Metrics) map[string]entity.Metrics {
+	res := map[string]entity.Metrics{}
+	for _, m := range ms {
+		res[m.ID] = m
+	}
+	return res
+}
+
+func TestProcessCollector_PIDFile(t *testing.T) {
+	self, err := process.NewProcess(int32(os.Getpid()))
+	require.NoError(t, err)
+	selfName, err := self.Name()
+	require.NoError(t, err)
+
+	pidFile := filepath.Join(t.TempDir(), "self.pid")
+	writePIDFile(t, pidFile, os.Getpid())
+
+	c := NewProcess(time.Second, []string{pidFile})
+	ms, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	// при первом наблюдении загрузка CPU ещё неизвестна
+	assert.NotContains(t, metricsByID(ms), "ProcessCPUPercent")
+
+	ms, err = c.Collect(context.Background())
+	require.NoError(t, err)
+
+	byID := metricsByID(ms)
+	for _, id := range []string{"ProcessCPUPercent", "ProcessRSS", "ProcessThreads", "ProcessUptime"} {
+		require.Contains(t, byID, id)
+		assert.Equal(t, entity.Gauge, byID[id].MType)
+		assert.Equal(t, map[string]string{"process": selfName}, byID[id].Labels)
+	}
+	assert.Greater(t, *byID["ProcessRSS"].Value, 0.0)
+	assert.GreaterOrEqual(t, *byID["ProcessThreads"].Value, 1.0)
+}
+
+func TestProcessCollector_Restart(t *testing.T) {
+	start := func() *exec.Cmd {
+		cmd := exec.Command("sleep", "30")
+		if err := cmd.Start(); err != nil {
+			t.Skipf("sleep is not available: %v", err)
+		}
+		t.Cleanup(func() {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		})
+		return cmd
+	}
+
+	pidFile := filepath.Join(t.TempDir(), "sleep.pid")
+	c := NewProcess(time.Second, []string{pidFile, "sleep"})
+
+	first := start()
+	writePIDFile(t, pidFile, first.Process.Pid)
+	ms, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.NotEmpty(t, ms)
+	assert.Equal(t, int32(first.Process.Pid), c.tracked[pidFile].Pid)
+
+	// перезапуск сервиса: старый процесс завершён, PID-файл указывает на новый
+	_ = first.Process.Kill()
+	_ = first.Wait()
+	second := start()
+	writePIDFile(t, pidFile, second.Process.Pid)
+
+	ms, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.NotEmpty(t, ms)
+	assert.Equal(t, int32(second.Process.Pid), c.tracked[pidFile].Pid)
+	assert.Contains(t, c.tracked, "sleep")
+	// перезапущенный процесс наблюдается впервые: загрузка CPU не передаётся
+	assert.NotContains(t, metricsByID(ms), "ProcessCPUPercent")
+}
+
+func TestProcessCollector_NotFound(t *testing.T) {
+	c := NewProcess(time.Second, []string{
+		"no-such-process-name",
+		filepath.Join(t.TempDir(), "missing.pid"),
+	})
+
+	ms, err := c.Collect(context.Background())
+	require.Error(t, err)
+	assert.Empty(t, ms)
+	assert.Empty(t, c.tracked)
+}
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+)
+
+// RuntimeCollector собирает статистику аллокатора и GC из runtime.MemStats,
+// а также RandomValue и счётчик опросов PollCount.
+type RuntimeCollector struct {
+	interval time.Duration // интервал опроса
+}
+
+// NewRuntime создаёт сборщик "runtime".
+//
+// Параметры:
+//   - interval: интервал опроса
+func NewRuntime(interval time.Duration) *RuntimeCollector {
+	return &RuntimeCollector{interval: interval}
+}
+
+// Name возвращает имя сборщика.
+func (c *RuntimeCollector) Name() string {
+	return "runtime"
+}
+
+// Interval возвращает интервал опроса.
+func (c *RuntimeCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect собирает метрики runtime.MemStats.
+func (c *RuntimeCollector) Collect(ctx context.Context) ([]entity.Metrics, error) {
+	var mems runtime.MemStats
+	runtime.ReadMemStats(&mems)
+
+	return []entity.Metrics{
+		gauge("Alloc", float64(mems.Alloc)),
+		gauge("BuckHashSys", float64(mems.BuckHashSys)),
+		gauge("Frees", float64(mems.Frees)),
+		gauge("GCCPUFraction", mems.GCCPUFraction),
+		gauge("GCSys", float64(mems.GCSys)),
+		gauge("HeapAlloc", float64(mems.HeapAlloc)),
+		gauge("HeapIdle", float64(mems.HeapIdle)),
+		gauge("HeapInuse", float64(mems.HeapInuse)),
+		gauge("HeapObjects", float64(mems.HeapObjects)),
+		gauge("HeapReleased", float64(mems.HeapReleased)),
+		gauge("HeapSys", float64(mems.HeapSys)),
+		gauge("LastGC", float64(mems.LastGC)),
+		gauge("Lookups", float64(mems.Lookups)),
+		gauge("MCacheInuse", float64(mems.MCacheInuse)),
+		gauge("MCacheSys", float64(mems.MCacheSys)),
+		gauge("MSpanInuse", float64(mems.MSpanInuse)),
+		gauge("MSpanSys", float64(mems.MSpanSys)),
+		gauge("Mallocs", float64(mems.Mallocs)),
+		gauge("NextGC", float64(mems.NextGC)),
+		gauge("NumForcedGC", float64(mems.NumForcedGC)),
+		gauge("NumGC", float64(mems.NumGC)),
+		gauge("OtherSys", float64(mems.OtherSys)),
+		gauge("PauseTotalNs", float64(mems.PauseTotalNs)),
+		gauge("StackInuse", float64(mems.StackInuse)),
+		gauge("StackSys", float64(mems.StackSys)),
+		gauge("Sys", float64(mems.Sys)),
+		gauge("TotalAlloc", float64(mems.TotalAlloc)),
+		gauge("RandomValue", rand.Float64()), //nolint:gosec // случайное значение не используется для безопасности
+		counter("PollCount", 1),
+	}, nil
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// SystemCollector собирает метрики памяти и загрузки процессора хоста через gopsutil.
+type SystemCollector struct {
+	interval time.Duration // интервал опроса
+}
+
+// NewSystem создаёт сборщик "system".
+//
+// Параметры:
+//   - interval: интервал опроса
+func NewSystem(interval time.Duration) *SystemCollector {
+	return &SystemCollector{interval: interval}
+}
+
+// Name возвращает имя сборщика.
+func (c *SystemCollector) Name() string {
+	return "system"
+}
+
+// Interval возвращает интервал опроса.
+func (c *SystemCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect собирает TotalMemory, FreeMemory и CPUutilization1.
+func (c *SystemCollector) Collect(ctx context.Context) ([]entity.Metrics, error) {
+	vals, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read virtual memory error: %w", err)
+	}
+	metrics := []entity.Metrics{
+		gauge("TotalMemory", float64(vals.Total)),
+		gauge("FreeMemory", float64(vals.Free)),
+	}
+
+	percents, err := cpu.PercentWithContext(ctx, time.Second, true)
+	if err != nil {
+		return metrics, fmt.Errorf("read cpu utilization error: %w", err)
+	}
+	if len(percents) > 0 {
+		metrics = append(metrics, gauge("CPUutilization1", percents[0]))
+	}
+
+	return metrics, nil
+}
@@ -4,24 +4,42 @@ package config
 
 // Костанты - значения по умолчанию.
 const (
-	defaultServerAddress  string = "localhost:8080" // адрес сервера
-	defaultHashKey        string = ""               // ключ хэширования (отсутствует)
-	defaultPollInterval   int64  = 2                // интервал опроса (в секундах)
-	defaultReportInterval int64  = 10               // интервал отправки данных (в секундах)
-	defaultRateLimit      int64  = 1                // лимит запросов для агента
-	defaultCryptoKeyPath  string = ""               // путь до публичного ключа
-	defaultGrpcEnabled    bool   = false            // включать ли поддержку gRPC
+	defaultServerAddress      string = "localhost:8080" // адрес сервера
+	defaultHashKey            string = ""               // ключ хэширования (отсутствует)
+	defaultPollInterval       int64  = 2                // интервал опроса (в секундах)
+	defaultReportInterval     int64  = 10               // интервал отправки данных (в секундах)
+	defaultRateLimit          int64  = 1                // лимит запросов для агента
+	defaultCryptoKeyPath      string = ""               // путь до публичного ключа
+	defaultGrpcEnabled        bool   = false            // включать ли поддержку gRPC
+	defaultHostLabels         bool   = false            // добавлять ли метки хоста к метрикам
+	defaultQueuePath          string = ""               // путь до каталога очереди отправки (пусто - очередь отключена)
+	defaultQueueMaxSize       int64  = 64 << 20         // максимальный размер очереди отправки (в байтах)
+	defaultQueueMaxAge        int64  = 3600             // максимальный возраст пакета в очереди отправки (в секундах)
+	defaultCollectors         string = ""               // включённые сборщики метрик через запятую (пусто - все)
+	defaultCollectorsDisabled string = ""               // отключённые сборщики метрик через запятую
+	defaultProcesses          string = ""               // отслеживаемые процессы (имена или пути до PID-файлов)
+	defaultGrpcStream         bool   = false            // отправлять ли метрики через поток gRPC
+	defaultAgentID            string = ""               // идентификатор агента (пусто - источником считается IP)
 )
 
 // Config - структура, содержащая основные параметры приложения.
 type Config struct {
-	ServerAddress  string // Aдрес сервера
-	HashKey        string // Ключ хэширования
-	CryptoKeyPath  string // Путь до публичного ключа
-	PollInterval   int64  // Интервал опроса (в секундах)
-	ReportInterval int64  // Интервал отправки данных (в секундах)
-	RateLimit      int64  // Лимит запросов для агента
-	GrpcEnabled    bool   // Bключать ли поддержку gRPC
+	ServerAddress      string // Aдрес сервера
+	HashKey            string // Ключ хэширования
+	CryptoKeyPath      string // Путь до публичного ключа
+	PollInterval       int64  // Интервал опроса (в секундах)
+	ReportInterval     int64  // Интервал отправки данных (в секундах)
+	RateLimit          int64  // Лимит запросов для агента
+	GrpcEnabled        bool   // Bключать ли поддержку gRPC
+	HostLabels         bool   // Добавлять ли метки хоста к метрикам
+	QueuePath          string // Путь до каталога очереди отправки (пусто - очередь отключена)
+	QueueMaxSize       int64  // Максимальный размер очереди отправки (в байтах)
+	QueueMaxAge        int64  // Максимальный возраст пакета в очереди отправки (в секундах)
+	Collectors         string // Включённые сборщики метрик через запятую (пусто - все)
+	CollectorsDisabled string // Отключённые сборщики метрик через запятую
+	Processes          string // Отслеживаемые процессы (имена или пути до PID-файлов)
+	GrpcStream         bool   // Отправлять ли метрики через поток gRPC
+	AgentID            string // Идентификатор агента (пусто - источником считается IP)
 }
 
 // Initialize создаёт и иницализирует объект *Config.
@@ -53,13 +71,22 @@ func Initialize() *Config {
 
 func createAndOverrideConfig(fileConf *configJSONs, flagsConf, envsConf *configEnvsAndFlags) *Config {
 	config := &Config{
-		ServerAddress:  defaultServerAddress,
-		HashKey:        defaultHashKey,
-		CryptoKeyPath:  defaultCryptoKeyPath,
-		PollInterval:   defaultPollInterval,
-		ReportInterval: defaultReportInterval,
-		RateLimit:      defaultRateLimit,
-		GrpcEnabled:    defaultGrpcEnabled,
+		ServerAddress:      defaultServerAddress,
+		HashKey:            defaultHashKey,
+		CryptoKeyPath:      defaultCryptoKeyPath,
+		PollInterval:       defaultPollInterval,
+		ReportInterval:     defaultReportInterval,
+		RateLimit:          defaultRateLimit,
+		GrpcEnabled:        defaultGrpcEnabled,
+		HostLabels:         defaultHostLabels,
+		QueuePath:          defaultQueuePath,
+		QueueMaxSize:       defaultQueueMaxSize,
+		QueueMaxAge:        defaultQueueMaxAge,
+		Collectors:         defaultCollectors,
+		CollectorsDisabled: defaultCollectorsDisabled,
+		Processes:          defaultProcesses,
+		GrpcStream:         defaultGrpcStream,
+		AgentID:            defaultAgentID,
 	}
 
 	config.overrideConfigFromJSONs(fileConf)
@@ -51,29 +51,56 @@ func TestGetEnvsConfig(t *testing.T) {
 		{
 			name: "full values",
 			env: map[string]string{
-				"CONFIG":          "/config.json",
-				"CRYPTO_KEY":      "/keys/public.pem",
-				"KEY":             "myhashkey",
-				"ADDRESS":         "example.com:8080",
-				"POLL_INTERVAL":   "3",
-				"REPORT_INTERVAL": "15",
-				"RATE_LIMIT":      "5",
+				"CONFIG":              "/config.json",
+				"CRYPTO_KEY":          "/keys/public.pem",
+				"KEY":                 "myhashkey",
+				"ADDRESS":             "example.com:8080",
+				"POLL_INTERVAL":       "3",
+				"REPORT_INTERVAL":     "15",
+				"RATE_LIMIT":          "5",
+				"HOST_LABELS":         "true",
+				"QUEUE_PATH":          "/tmp/queue",
+				"QUEUE_MAX_SIZE":      "1024",
+				"QUEUE_MAX_AGE":       "60",
+				"COLLECTORS":          "runtime",
+				"COLLECTORS_DISABLED": "system",
+				"PROCESSES":           "nginx",
+				"GRPC_STREAM":         "true",
+				"AGENT_ID":            "agent-7",
 			},
 			expected: configEnvsAndFlags{
-				configPath:            "/config.json",
-				configPathIsValue:     true,
-				cryptoKeyPath:         "/keys/public.pem",
-				cryptoKeyPathIsValue:  true,
-				hashKey:               "myhashkey",
-				hashKeyIsValue:        true,
-				serverAddress:         "example.com:8080",
-				serverAddressIsValue:  true,
-				pollInterval:          3,
-				pollIntervalIsValue:   true,
-				reportInterval:        15,
-				reportIntervalIsValue: true,
-				rateLimit:             5,
-				rateLimitIsValue:      true,
+				configPath:                "/config.json",
+				configPathIsValue:         true,
+				cryptoKeyPath:             "/keys/public.pem",
+				cryptoKeyPathIsValue:      true,
+				hashKey:                   "myhashkey",
+				hashKeyIsValue:            true,
+				serverAddress:             "example.com:8080",
+				serverAddressIsValue:      true,
+				pollInterval:              3,
+				pollIntervalIsValue:       true,
+				reportInterval:            15,
+				reportIntervalIsValue:     true,
+				rateLimit:                 5,
+				rateLimitIsValue:          true,
+				hostLabels:                true,
+				hostLabelsIsValue:         true,
+				queuePath:                 "/tmp/queue",
+				queuePathIsValue:          true,
+				queueMaxSize:              1024,
+				queueMaxSizeIsValue:       true,
+				queueMaxAge:               60,
+				queueMaxAgeIsValue:        true,
+				collectors:                "runtime",
+				collectorsIsValue:         true,
+				collectorsDisabled:        "system",
+				collectorsDisabledIsValue: true,
+				processes:                 "nginx",
+				processesIsValue:          true,
+				grpcStream:                true,
+				grpcStreamIsValue:         true,
+				agentID:                   "agent-7",
+				agentIDIsValue:            true,
 			},
 		},
 		{
@@ -150,6 +177,33 @@ func TestGetEnvsConfig(t *testing.T) {
 
 			assert.Equal(t, tt.expected.rateLimit, config.rateLimit)
 			assert.Equal(t, tt.expected.rateLimitIsValue, config.rateLimitIsValue)
+
+			assert.Equal(t, tt.expected.hostLabels, config.hostLabels)
+			assert.Equal(t, tt.expected.hostLabelsIsValue, config.hostLabelsIsValue)
+
+			assert.Equal(t, tt.expected.queuePath, config.queuePath)
+			assert.Equal(t, tt.expected.queuePathIsValue, config.queuePathIsValue)
+
+			assert.Equal(t, tt.expected.queueMaxSize, config.queueMaxSize)
+			assert.Equal(t, tt.expected.queueMaxSizeIsValue, config.queueMaxSizeIsValue)
+
+			assert.Equal(t, tt.expected.queueMaxAge, config.queueMaxAge)
+			assert.Equal(t, tt.expected.queueMaxAgeIsValue, config.queueMaxAgeIsValue)
+
+			assert.Equal(t, tt.expected.collectors, config.collectors)
+			assert.Equal(t, tt.expected.collectorsIsValue, config.collectorsIsValue)
+
+			assert.Equal(t, tt.expected.collectorsDisabled, config.collectorsDisabled)
+			assert.Equal(t, tt.expected.collectorsDisabledIsValue, config.collectorsDisabledIsValue)
+
+			assert.Equal(t, tt.expected.processes, config.processes)
+			assert.Equal(t, tt.expected.processesIsValue, config.processesIsValue)
+
+			assert.Equal(t, tt.expected.grpcStream, config.grpcStream)
+			assert.Equal(t, tt.expected.grpcStreamIsValue, config.grpcStreamIsValue)
+
+			assert.Equal(t, tt.expected.agentID, config.agentID)
+			assert.Equal(t, tt.expected.agentIDIsValue, config.agentIDIsValue)
 		})
 	}
 }
@@ -170,22 +224,49 @@ func TestGetFlagsConfig(t *testing.T) {
 				"-p", "3",
 				"-r", "15",
 				"-l", "5",
+				"-host-labels",
+				"-queue-path", "/tmp/queue",
+				"-queue-max-size", "1024",
+				"-queue-max-age", "60",
+				"-collectors", "runtime",
+				"-collectors-disabled", "system",
+				"-processes", "nginx",
+				"-grpc-stream",
+				"-agent-id", "agent-7",
 			},
 			expected: configEnvsAndFlags{
-				configPath:            "/config.json",
-				configPathIsValue:     true,
-				cryptoKeyPath:         "/keys/public.pem",
-				cryptoKeyPathIsValue:  true,
-				hashKey:               "myhashkey",
-				hashKeyIsValue:        true,
-				serverAddress:         "example.com:8080",
-				serverAddressIsValue:  true,
-				pollInterval:          3,
-				pollIntervalIsValue:   true,
-				reportInterval:        15,
-				reportIntervalIsValue: true,
-				rateLimit:             5,
-				rateLimitIsValue:      true,
+				configPath:                "/config.json",
+				configPathIsValue:         true,
+				cryptoKeyPath:             "/keys/public.pem",
+				cryptoKeyPathIsValue:      true,
+				hashKey:                   "myhashkey",
+				hashKeyIsValue:            true,
+				serverAddress:             "example.com:8080",
+				serverAddressIsValue:      true,
+				pollInterval:              3,
+				pollIntervalIsValue:       true,
+				reportInterval:            15,
+				reportIntervalIsValue:     true,
+				rateLimit:                 5,
+				rateLimitIsValue:          true,
+				hostLabels:                true,
+				hostLabelsIsValue:         true,
+				queuePath:                 "/tmp/queue",
+				queuePathIsValue:          true,
+				queueMaxSize:              1024,
+				queueMaxSizeIsValue:       true,
+				queueMaxAge:               60,
+				queueMaxAgeIsValue:        true,
+				collectors:                "runtime",
+				collectorsIsValue:         true,
+				collectorsDisabled:        "system",
+				collectorsDisabledIsValue: true,
+				processes:                 "nginx",
+				processesIsValue:          true,
+				grpcStream:                true,
+				grpcStreamIsValue:         true,
+				agentID:                   "agent-7",
+				agentIDIsValue:            true,
 			},
 		},
 		{
@@ -262,6 +343,33 @@ func TestGetFlagsConfig(t *testing.T) {
 
 			assert.Equal(t, tt.expected.rateLimit, config.rateLimit)
 			assert.Equal(t, tt.expected.rateLimitIsValue, config.rateLimitIsValue)
+
+			assert.Equal(t, tt.expected.hostLabels, config.hostLabels)
+			assert.Equal(t, tt.expected.hostLabelsIsValue, config.hostLabelsIsValue)
+
+			assert.Equal(t, tt.expected.queuePath, config.queuePath)
+			assert.Equal(t, tt.expected.queuePathIsValue, config.queuePathIsValue)
+
+			assert.Equal(t, tt.expected.queueMaxSize, config.queueMaxSize)
+			assert.Equal(t, tt.expected.queueMaxSizeIsValue, config.queueMaxSizeIsValue)
+
+			assert.Equal(t, tt.expected.queueMaxAge, config.queueMaxAge)
+			assert.Equal(t, tt.expected.queueMaxAgeIsValue, config.queueMaxAgeIsValue)
+
+			assert.Equal(t, tt.expected.collectors, config.collectors)
+			assert.Equal(t, tt.expected.collectorsIsValue, config.collectorsIsValue)
+
+			assert.Equal(t, tt.expected.collectorsDisabled, config.collectorsDisabled)
+			assert.Equal(t, tt.expected.collectorsDisabledIsValue, config.collectorsDisabledIsValue)
+
+			assert.Equal(t, tt.expected.processes, config.processes)
+			assert.Equal(t, tt.expected.processesIsValue, config.processesIsValue)
+
+			assert.Equal(t, tt.expected.grpcStream, config.grpcStream)
+			assert.Equal(t, tt.expected.grpcStreamIsValue, config.grpcStreamIsValue)
+
+			assert.Equal(t, tt.expected.agentID, config.agentID)
+			assert.Equal(t, tt.expected.agentIDIsValue, config.agentIDIsValue)
 		})
 	}
 }
@@ -278,17 +386,44 @@ func TestGetJSONConfig(t *testing.T) {
 				"server_address": "localhost:8080", 
 				"crypto_key": "/keys/public.pem", 
 				"poll_interval": 5, 
-				"report_interval": 10
+				"report_interval": 10,
+				"host_labels": true,
+				"queue_path": "/tmp/queue",
+				"queue_max_size": 1024,
+				"queue_max_age": 60,
+				"collectors": "runtime",
+				"collectors_disabled": "system",
+				"processes": "nginx",
+				"grpc_stream": true,
+				"agent_id": "agent-7"
 			}`,
 			expected: configJSONs{
-				ServerAddress:         "localhost:8080",
-				serverAddressIsValue:  true,
-				CryptoKeyPath:         "/keys/public.pem",
-				cryptoKeyPathIsValue:  true,
-				PollInterval:          5,
-				pollIntervalIsValue:   true,
-				ReportInterval:        10,
-				reportIntervalIsValue: true,
+				ServerAddress:             "localhost:8080",
+				serverAddressIsValue:      true,
+				CryptoKeyPath:             "/keys/public.pem",
+				cryptoKeyPathIsValue:      true,
+				PollInterval:              5,
+				pollIntervalIsValue:       true,
+				ReportInterval:            10,
+				reportIntervalIsValue:     true,
+				HostLabels:                true,
+				hostLabelsIsValue:         true,
+				QueuePath:                 "/tmp/queue",
+				queuePathIsValue:          true,
+				QueueMaxSize:              1024,
+				queueMaxSizeIsValue:       true,
+				QueueMaxAge:               60,
+				queueMaxAgeIsValue:        true,
+				Collectors:                "runtime",
+				collectorsIsValue:         true,
+				CollectorsDisabled:        "system",
+				collectorsDisabledIsValue: true,
+				Processes:                 "nginx",
+				processesIsValue:          true,
+				GrpcStream:                true,
+				grpcStreamIsValue:         true,
+				AgentID:                   "agent-7",
+				agentIDIsValue:            true,
 			},
 		},
 		{
@@ -340,6 +475,33 @@ func TestGetJSONConfig(t *testing.T) {
 
 			assert.Equal(t, tt.expected.ReportInterval, config.ReportInterval)
 			assert.Equal(t, tt.expected.reportIntervalIsValue, config.reportIntervalIsValue)
+
+			assert.Equal(t, tt.expected.HostLabels, config.HostLabels)
+			assert.Equal(t, tt.expected.hostLabelsIsValue, config.hostLabelsIsValue)
+
+			assert.Equal(t, tt.expected.QueuePath, config.QueuePath)
+			assert.Equal(t, tt.expected.queuePathIsValue, config.queuePathIsValue)
+
+			assert.Equal(t, tt.expected.QueueMaxSize, config.QueueMaxSize)
+			assert.Equal(t, tt.expected.queueMaxSizeIsValue, config.queueMaxSizeIsValue)
+
+			assert.Equal(t, tt.expected.QueueMaxAge, config.QueueMaxAge)
+			assert.Equal(t, tt.expected.queueMaxAgeIsValue, config.queueMaxAgeIsValue)
+
+			assert.Equal(t, tt.expected.Collectors, config.Collectors)
+			assert.Equal(t, tt.expected.collectorsIsValue, config.collectorsIsValue)
+
+			assert.Equal(t, tt.expected.CollectorsDisabled, config.CollectorsDisabled)
+			assert.Equal(t, tt.expected.collectorsDisabledIsValue, config.collectorsDisabledIsValue)
+
+			assert.Equal(t, tt.expected.Processes, config.Processes)
+			assert.Equal(t, tt.expected.processesIsValue, config.processesIsValue)
+
+			assert.Equal(t, tt.expected.GrpcStream, config.GrpcStream)
+			assert.Equal(t, tt.expected.grpcStreamIsValue, config.grpcStreamIsValue)
+
+			assert.Equal(t, tt.expected.AgentID, config.AgentID)
+			assert.Equal(t, tt.expected.agentIDIsValue, config.agentIDIsValue)
 		})
 	}
 }
@@ -7,22 +7,40 @@ import (
 
 // configEnvsAndFlags - структура, содержащая основные переменные окружения для приложения.
 type configEnvsAndFlags struct {
-	configPath            string // путь до JSON конфига
-	cryptoKeyPath         string // путь до публичного ключа
-	hashKey               string // ключ хэширования
-	serverAddress         string // адрес сервера
-	pollInterval          int64  // интервал опроса (в секундах)
-	reportInterval        int64  // интервал отправки данных (в секундах)
-	rateLimit             int64  // лимит запросов для агента
-	grpcEnabled           bool   // включать ли поддержку gRPC
-	configPathIsValue     bool
-	cryptoKeyPathIsValue  bool
-	hashKeyIsValue        bool
-	serverAddressIsValue  bool
-	pollIntervalIsValue   bool
-	reportIntervalIsValue bool
-	rateLimitIsValue      bool
-	grpcEnabledIsValue    bool
+	configPath                string // путь до JSON конфига
+	cryptoKeyPath             string // путь до публичного ключа
+	hashKey                   string // ключ хэширования
+	serverAddress             string // адрес сервера
+	pollInterval              int64  // интервал опроса (в секундах)
+	reportInterval            int64  // интервал отправки данных (в секундах)
+	rateLimit                 int64  // лимит запросов для агента
+	grpcEnabled               bool   // включать ли поддержку gRPC
+	hostLabels                bool   // добавлять ли метки хоста к метрикам
+	queuePath                 string // путь до каталога очереди отправки (пусто - очередь отключена)
+	queueMaxSize              int64  // максимальный размер очереди отправки (в байтах)
+	queueMaxAge               int64  // максимальный возраст пакета в очереди отправки (в секундах)
+	collectors                string // включённые сборщики метрик через запятую (пусто - все)
+	collectorsDisabled        string // отключённые сборщики метрик через запятую
+	processes                 string // отслеживаемые процессы (имена или пути до PID-файлов)
+	grpcStream                bool   // отправлять ли метрики через поток gRPC
+	agentID                   string // идентификатор агента (пусто - источником считается IP)
+	configPathIsValue         bool
+	cryptoKeyPathIsValue      bool
+	hashKeyIsValue            bool
+	serverAddressIsValue      bool
+	pollIntervalIsValue       bool
+	reportIntervalIsValue     bool
+	rateLimitIsValue          bool
+	grpcEnabledIsValue        bool
+	hostLabelsIsValue         bool
+	queuePathIsValue          bool
+	queueMaxSizeIsValue       bool
+	queueMaxAgeIsValue        bool
+	collectorsIsValue         bool
+	collectorsDisabledIsValue bool
+	processesIsValue          bool
+	grpcStreamIsValue         bool
+	agentIDIsValue            bool
 }
 
 // envReader — интерфейс для чтения переменных окружения.
@@ -88,6 +106,68 @@ func getEnvsConfig(getenv envReader) *configEnvsAndFlags {
 		}
 	}
 
+	envHostLabels, ok := getenv("HOST_LABELS")
+	if ok && envHostLabels != "" {
+		if val, err := strconv.ParseBool(envHostLabels); err == nil {
+			config.hostLabels = val
+			config.hostLabelsIsValue = true
+		}
+	}
+
+	envQueuePath, ok := getenv("QUEUE_PATH")
+	if ok && envQueuePath != "" {
+		config.queuePath = envQueuePath
+		config.queuePathIsValue = true
+	}
+
+	envQueueMaxSize, ok := getenv("QUEUE_MAX_SIZE")
+	if ok && envQueueMaxSize != "" {
+		if val, err := strconv.ParseInt(envQueueMaxSize, 10, 64); err == nil {
+			config.queueMaxSize = val
+			config.queueMaxSizeIsValue = true
+		}
+	}
+
+	envQueueMaxAge, ok := getenv("QUEUE_MAX_AGE")
+	if ok && envQueueMaxAge != "" {
+		if val, err := strconv.ParseInt(envQueueMaxAge, 10, 64); err == nil {
+			config.queueMaxAge = val
+			config.queueMaxAgeIsValue = true
+		}
+	}
+
+	envCollectors, ok := getenv("COLLECTORS")
+	if ok && envCollectors != "" {
+		config.collectors = envCollectors
+		config.collectorsIsValue = true
+	}
+
+	envCollectorsDisabled, ok := getenv("COLLECTORS_DISABLED")
+	if ok && envCollectorsDisabled != "" {
+		config.collectorsDisabled = envCollectorsDisabled
+		config.collectorsDisabledIsValue = true
+	}
+
+	envProcesses, ok := getenv("PROCESSES")
+	if ok && envProcesses != "" {
+		config.processes = envProcesses
+		config.processesIsValue = true
+	}
+
+	envGrpcStream, ok := getenv("GRPC_STREAM")
+	if ok && envGrpcStream != "" {
+		if val, err := strconv.ParseBool(envGrpcStream); err == nil {
+			config.grpcStream = val
+			config.grpcStreamIsValue = true
+		}
+	}
+
+	envAgentID, ok := getenv("AGENT_ID")
+	if ok && envAgentID != "" {
+		config.agentID = envAgentID
+		config.agentIDIsValue = true
+	}
+
 	return config
 }
 
@@ -126,4 +206,31 @@ func (c *Config) overrideConfigFromEnvsAndFlags(conf *configEnvsAndFlags) {
 	if conf.grpcEnabledIsValue {
 		c.GrpcEnabled = conf.grpcEnabled
 	}
+	if conf.hostLabelsIsValue {
+		c.HostLabels = conf.hostLabels
+	}
+	if conf.queuePathIsValue {
+		c.QueuePath = conf.queuePath
+	}
+	if conf.queueMaxSizeIsValue {
+		c.QueueMaxSize = conf.queueMaxSize
+	}
+	if conf.queueMaxAgeIsValue {
+		c.QueueMaxAge = conf.queueMaxAge
+	}
+	if conf.collectorsIsValue {
+		c.Collectors = conf.collectors
+	}
+	if conf.collectorsDisabledIsValue {
+		c.CollectorsDisabled = conf.collectorsDisabled
+	}
+	if conf.processesIsValue {
+		c.Processes = conf.processes
+	}
+	if conf.grpcStreamIsValue {
+		c.GrpcStream = conf.grpcStream
+	}
+	if conf.agentIDIsValue {
+		c.AgentID = conf.agentID
+	}
 }
@@ -19,6 +19,15 @@ func getFlagsConfig(fs *flag.FlagSet, args []string) (*configEnvsAndFlags, error
 	argR := fs.Int64("r", 0, "Report interval")
 	argL := fs.Int64("l", 0, "Rate limit")
 	argG := fs.Bool("g", false, "gRPC enabled")
+	argHostLabels := fs.Bool("host-labels", false, "Add host labels to metrics")
+	argQueuePath := fs.String("queue-path", "", "Outbound disk queue directory")
+	argQueueMaxSize := fs.Int64("queue-max-size", 0, "Outbound disk queue max size (bytes)")
+	argQueueMaxAge := fs.Int64("queue-max-age", 0, "Outbound disk queue max batch age (seconds)")
+	argCollectors := fs.String("collectors", "", "Enabled collectors, comma separated (empty - all)")
+	argCollectorsDisabled := fs.String("collectors-disabled", "", "Disabled collectors, comma separated")
+	argProcesses := fs.String("processes", "", "Watched processes, comma separated (names or PID file paths)")
+	argGrpcStream := fs.Bool("grpc-stream", false, "Send metrics over one long-lived gRPC stream")
+	argAgentID := fs.String("agent-id", "", "Agent ID for per-source counter breakdown (empty - agent IP)")
 
 	if err := fs.Parse(args); err != nil {
 		return nil, fmt.Errorf("parse argument %w", err)
@@ -60,6 +69,42 @@ func getFlagsConfig(fs *flag.FlagSet, args []string) (*configEnvsAndFlags, error
 		config.grpcEnabled = *argG
 		config.grpcEnabledIsValue = true
 	}
+	if argHostLabels != nil && *argHostLabels {
+		config.hostLabels = *argHostLabels
+		config.hostLabelsIsValue = true
+	}
+	if argQueuePath != nil && *argQueuePath != "" {
+		config.queuePath = *argQueuePath
+		config.queuePathIsValue = true
+	}
+	if argQueueMaxSize != nil && *argQueueMaxSize != 0 {
+		config.queueMaxSize = *argQueueMaxSize
+		config.queueMaxSizeIsValue = true
+	}
+	if argQueueMaxAge != nil && *argQueueMaxAge != 0 {
+		config.queueMaxAge = *argQueueMaxAge
+		config.queueMaxAgeIsValue = true
+	}
+	if argCollectors != nil && *argCollectors != "" {
+		config.collectors = *argCollectors
+		config.collectorsIsValue = true
+	}
+	if argCollectorsDisabled != nil && *argCollectorsDisabled != "" {
+		config.collectorsDisabled = *argCollectorsDisabled
+		config.collectorsDisabledIsValue = true
+	}
+	if argProcesses != nil && *argProcesses != "" {
+		config.processes = *argProcesses
+		config.processesIsValue = true
+	}
+	if argGrpcStream != nil && *argGrpcStream {
+		config.grpcStream = *argGrpcStream
+		config.grpcStreamIsValue = true
+	}
+	if argAgentID != nil && *argAgentID != "" {
+		config.agentID = *argAgentID
+		config.agentIDIsValue = true
+	}
 
 	return config, nil
 }
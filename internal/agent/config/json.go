@@ -10,14 +10,32 @@ import (
 
 // configJSONs - структура, содержащая основные настройки в JSON для приложения.
 type configJSONs struct {
-	CryptoKeyPath         string `json:"crypto_key,omitempty"`
-	ServerAddress         string `json:"server_address,omitempty"`
-	PollInterval          int64  `json:"poll_interval,omitempty"`
-	ReportInterval        int64  `json:"report_interval,omitempty"`
-	cryptoKeyPathIsValue  bool   `json:"-"`
-	serverAddressIsValue  bool   `json:"-"`
-	pollIntervalIsValue   bool   `json:"-"`
-	reportIntervalIsValue bool   `json:"-"`
+	CryptoKeyPath             string `json:"crypto_key,omitempty"`
+	ServerAddress             string `json:"server_address,omitempty"`
+	PollInterval              int64  `json:"poll_interval,omitempty"`
+	ReportInterval            int64  `json:"report_interval,omitempty"`
+	HostLabels                bool   `json:"host_labels,omitempty"`
+	QueuePath                 string `json:"queue_path,omitempty"`
+	QueueMaxSize              int64  `json:"queue_max_size,omitempty"`
+	QueueMaxAge               int64  `json:"queue_max_age,omitempty"`
+	Collectors                string `json:"collectors,omitempty"`
+	CollectorsDisabled        string `json:"collectors_disabled,omitempty"`
+	Processes                 string `json:"processes,omitempty"`
+	GrpcStream                bool   `json:"grpc_stream,omitempty"`
+	AgentID                   string `json:"agent_id,omitempty"`
+	cryptoKeyPathIsValue      bool   `json:"-"`
+	serverAddressIsValue      bool   `json:"-"`
+	pollIntervalIsValue       bool   `json:"-"`
+	reportIntervalIsValue     bool   `json:"-"`
+	hostLabelsIsValue         bool   `json:"-"`
+	queuePathIsValue          bool   `json:"-"`
+	queueMaxSizeIsValue       bool   `json:"-"`
+	queueMaxAgeIsValue        bool   `json:"-"`
+	collectorsIsValue         bool   `json:"-"`
+	collectorsDisabledIsValue bool   `json:"-"`
+	processesIsValue          bool   `json:"-"`
+	grpcStreamIsValue         bool   `json:"-"`
+	agentIDIsValue            bool   `json:"-"`
 }
 
 // getJSONConfig получает конфиг из универсального io.Reader.
@@ -50,6 +68,42 @@ func getJSONConfig(r io.Reader) (*configJSONs, error) {
 		config.ReportInterval = c.ReportInterval
 		config.reportIntervalIsValue = true
 	}
+	if c.HostLabels {
+		config.HostLabels = c.HostLabels
+		config.hostLabelsIsValue = true
+	}
+	if c.QueuePath != "" {
+		config.QueuePath = c.QueuePath
+		config.queuePathIsValue = true
+	}
+	if c.QueueMaxSize != 0 {
+		config.QueueMaxSize = c.QueueMaxSize
+		config.queueMaxSizeIsValue = true
+	}
+	if c.QueueMaxAge != 0 {
+		config.QueueMaxAge = c.QueueMaxAge
+		config.queueMaxAgeIsValue = true
+	}
+	if c.Collectors != "" {
+		config.Collectors = c.Collectors
+		config.collectorsIsValue = true
+	}
+	if c.CollectorsDisabled != "" {
+		config.CollectorsDisabled = c.CollectorsDisabled
+		config.collectorsDisabledIsValue = true
+	}
+	if c.Processes != "" {
+		config.Processes = c.Processes
+		config.processesIsValue = true
+	}
+	if c.GrpcStream {
+		config.GrpcStream = c.GrpcStream
+		config.grpcStreamIsValue = true
+	}
+	if c.AgentID != "" {
+		config.AgentID = c.AgentID
+		config.agentIDIsValue = true
+	}
 
 	return config, nil
 }
@@ -89,4 +143,31 @@ func (c *Config) overrideConfigFromJSONs(conf *configJSONs) {
 	if conf.reportIntervalIsValue {
 		c.ReportInterval = conf.ReportInterval
 	}
+	if conf.hostLabelsIsValue {
+		c.HostLabels = conf.HostLabels
+	}
+	if conf.queuePathIsValue {
+		c.QueuePath = conf.QueuePath
+	}
+	if conf.queueMaxSizeIsValue {
+		c.QueueMaxSize = conf.QueueMaxSize
+	}
+	if conf.queueMaxAgeIsValue {
+		c.QueueMaxAge = conf.QueueMaxAge
+	}
+	if conf.collectorsIsValue {
+		c.Collectors = conf.Collectors
+	}
+	if conf.collectorsDisabledIsValue {
+		c.CollectorsDisabled = conf.CollectorsDisabled
+	}
+	if conf.processesIsValue {
+		c.Processes = conf.Processes
+	}
+	if conf.grpcStreamIsValue {
+		c.GrpcStream = conf.GrpcStream
+	}
+	if conf.agentIDIsValue {
+		c.AgentID = conf.AgentID
+	}
 }
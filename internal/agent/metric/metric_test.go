@@ -1,136 +1,88 @@
 package metric
 
 import (
-	"encoding/json"
-	"strconv"
 	"testing"
-	"unsafe"
 
 	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func TestNew(t *testing.T) {
-	am := New()
+func gauge(name string, value float64) entity.Metrics {
+	return entity.Metrics{ID: name, MType: entity.Gauge, Value: &value}
+}
 
-	require.NotNil(t, am)
-	assert.Equal(t, int64(0), am.PollCount)
-	assert.Len(t, am.Metrics, len(gaugeNames)+len(counterNames))
-
-	for _, name := range gaugeNames {
-		m, exists := am.Metrics[name]
-		assert.True(t, exists, "gauge метрика %s должна существовать", name)
-		assert.Equal(t, entity.Gauge, m.Type)
-		assert.Equal(t, "0", m.Value)
-	}
-
-	for _, name := range counterNames {
-		m, exists := am.Metrics[name]
-		assert.True(t, exists, "counter метрика %s должна существовать", name)
-		assert.Equal(t, entity.Counter, m.Type)
-		assert.Equal(t, "0", m.Value)
-	}
+func counter(name string, delta int64) entity.Metrics {
+	return entity.Metrics{ID: name, MType: entity.Counter, Delta: &delta}
 }
 
-func TestUpdate(t *testing.T) {
+func TestNew(t *testing.T) {
 	am := New()
 
-	initialPollCount := am.PollCount
-
-	am.Update()
-
-	assert.Equal(t, initialPollCount+1, am.PollCount)
-	counterMetric := am.Metrics["PollCount"]
-	assert.Equal(t, strconv.FormatInt(am.PollCount, 10), counterMetric.Value)
-
-	alloc := am.Metrics["Alloc"]
-	allocVal, err := strconv.ParseUint(alloc.Value, 10, 64)
-	require.NoError(t, err)
-	assert.Greater(t, allocVal, uint64(0), "Alloc должен быть > 0 после Update")
-
-	random := am.Metrics["RandomValue"]
-	randomVal, err := strconv.ParseFloat(random.Value, 64)
-	require.NoError(t, err)
-	assert.GreaterOrEqual(t, randomVal, 0.0)
-	assert.Less(t, randomVal, 1.0)
+	require.NotNil(t, am)
+	assert.Empty(t, am.Take())
 }
 
-func TestUpdate_MultipleCalls(t *testing.T) {
+func TestAdd(t *testing.T) {
 	am := New()
 
-	am.Update()
+	am.Add([]entity.Metrics{gauge("Alloc", 1), counter("PollCount", 1)})
+	am.Add([]entity.Metrics{gauge("Alloc", 2), counter("PollCount", 1)})
 
-	assert.Equal(t, int64(1), am.PollCount)
-	assert.Equal(t, "1", am.Metrics["PollCount"].Value)
+	ms := am.Take()
+	require.Len(t, ms, 2)
+	assert.Equal(t, "Alloc", ms[0].ID)
+	assert.InDelta(t, 2.0, *ms[0].Value, 0)
+	assert.Equal(t, "PollCount", ms[1].ID)
+	assert.Equal(t, int64(2), *ms[1].Delta)
 }
 
-func TestGetByName_Exists(t *testing.T) {
+func TestAdd_IgnoresEmptyValues(t *testing.T) {
 	am := New()
-	am.Update() // чтобы Alloc был > 0
 
-	metric := am.GetByName("Alloc")
-	assert.Equal(t, "Alloc", metric.Name)
-	assert.Equal(t, entity.Gauge, metric.Type)
-	assert.NotEqual(t, "0", metric.Value)
+	am.Add([]entity.Metrics{
+		{ID: "a", MType: entity.Gauge},
+		{ID: "b", MType: entity.Counter},
+		{ID: "c", MType: "unknown"},
+	})
+	assert.Empty(t, am.Take())
 }
 
-func TestGetByName_NotExists(t *testing.T) {
+func TestTake_ResetsCounters(t *testing.T) {
 	am := New()
-	metric := am.GetByName("unknown")
-	assert.Equal(t, Metric{}, metric)
-}
+	am.Add([]entity.Metrics{gauge("Alloc", 1), counter("PollCount", 3)})
 
-func TestGetAllGaugeNames(t *testing.T) {
-	am := New()
-	names := am.GetAllGaugeNames()
-	assert.ElementsMatch(t, gaugeNames, names)
-	assert.Len(t, names, len(gaugeNames))
-}
+	first := am.Take()
+	am.Add([]entity.Metrics{counter("PollCount", 1)})
+	second := am.Take()
 
-func TestGetAllCounterNames(t *testing.T) {
-	am := New()
-	names := am.GetAllCounterNames()
-	assert.ElementsMatch(t, counterNames, names)
-	assert.Len(t, names, len(counterNames))
+	assert.Equal(t, int64(3), *first[1].Delta)
+	assert.Equal(t, int64(1), *second[1].Delta)
+	assert.InDelta(t, 1.0, *second[0].Value, 0, "gauge must keep its last value")
 }
 
-func TestClearCounter(t *testing.T) {
+func TestRestore(t *testing.T) {
 	am := New()
-	am.Update()
-	am.Update()
+	am.Add([]entity.Metrics{gauge("Alloc", 1), counter("PollCount", 3)})
 
-	assert.Equal(t, int64(2), am.PollCount)
-	assert.Equal(t, "2", am.Metrics["PollCount"].Value)
+	unsent := am.Take()
+	am.Add([]entity.Metrics{gauge("Alloc", 5), counter("PollCount", 1)})
+	am.Restore(unsent)
 
-	am.ClearCounter("PollCount")
-
-	assert.Equal(t, int64(0), am.PollCount)
-	assert.Equal(t, "0", am.Metrics["PollCount"].Value)
+	ms := am.Take()
+	assert.InDelta(t, 5.0, *ms[0].Value, 0, "restore must not overwrite newer gauges")
+	assert.Equal(t, int64(4), *ms[1].Delta)
 }
 
-func TestClearCounter_IgnoredForOther(t *testing.T) {
+func TestSetLabels(t *testing.T) {
 	am := New()
-	am.Update()
-	am.ClearCounter("SomeOther")
-	// Должно не изменить состояние
-	assert.Equal(t, int64(1), am.PollCount)
-}
-
-func TestMetric_JSONTags(t *testing.T) {
-	var m Metric
-	typ := unsafe.Sizeof(m)
-	_ = typ // избежать unused
-
-	m = Metric{
-		Type:  "gauge",
-		Name:  "test_gauge",
-		Value: "3.14",
-	}
+	am.SetLabels(map[string]string{"host": "h1", "disk": "common"})
 
-	data, err := json.Marshal(m)
-	require.NoError(t, err)
+	m := gauge("Usage", 1)
+	m.Labels = map[string]string{"disk": "sda"}
+	am.Add([]entity.Metrics{m})
 
-	expected := `{"type":"gauge","name":"test_gauge","value":"3.14"}`
-	assert.JSONEq(t, expected, string(data))
+	ms := am.Take()
+	require.Len(t, ms, 1)
+	assert.Equal(t, map[string]string{"host": "h1", "disk": "sda"}, ms[0].Labels)
 }
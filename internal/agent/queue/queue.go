@@ -0,0 +1,430 @@
+// Пакет queue предоставляет очередь отправки агента, хранящую пакеты метрик на диске.
+// Пакеты дописываются в файлы-сегменты (по одному JSON на строку) и отправляются в порядке поступления,
+// поэтому переживают как недоступность сервера, так и перезапуск агента.
+package queue
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
+)
+
+// Костанты для работы с файловой системой.
+const (
+	filePermission   os.FileMode = 0o600      // разрешения для работы с файлом
+	dirPermission    os.FileMode = 0o700      // разрешения для каталога очереди
+	segmentPrefix    string      = "segment-" // префикс имени файла-сегмента
+	segmentSuffix    string      = ".jsonl"   // суффикс имени файла-сегмента
+	cursorFileName   string      = "cursor"   // имя файла с позицией чтения
+	segmentsPerQueue int64       = 4          // на сколько сегментов делится максимальный размер очереди
+)
+
+// record описывает пакет метрик, сохранённый в очереди.
+type record struct {
+	Created time.Time        `json:"created"` // время постановки в очередь
+	Key     string           `json:"key"`     // ключ идемпотентности, общий для всех попыток отправки
+	Metrics []entity.Metrics `json:"metrics"` // метрики пакета
+}
+
+// cursor описывает позицию чтения, сохраняемую на диске.
+type cursor struct {
+	Segment uint64 `json:"segment"` // номер сегмента
+	Offset  int64  `json:"offset"`  // смещение в сегменте (в байтах)
+}
+
+// segment описывает файл-сегмент очереди.
+type segment struct {
+	id   uint64 // номер сегмента (растёт с каждым новым сегментом)
+	size int64  // размер файла (в байтах)
+}
+
+// SendFunc - функция отправки пакета метрик с ключом идемпотентности (например, client.Client.SendMetrics).
+// Повторная отправка пакета использует тот же ключ, поэтому не применяется сервером дважды.
+type SendFunc func(ctx context.Context, ms []entity.Metrics, idempotencyKey string) error
+
+// DiskQueue - очередь пакетов метрик на диске.
+// Размер очереди ограничен maxSize байтами, возраст пакета - maxAge,
+// при превышении ограничений удаляются самые старые пакеты.
+type DiskQueue struct {
+	log         logger.Logger    // логгер
+	now         func() time.Time // источник текущего времени
+	dir         string           // каталог очереди
+	segments    []segment        // сегменты от старого к новому, запись идёт в последний
+	nextID      uint64           // номер следующего сегмента
+	offset      int64            // позиция чтения в первом сегменте
+	size        int64            // объём неотправленных данных (в байтах)
+	maxSize     int64            // максимальный объём очереди (в байтах)
+	segmentSize int64            // размер сегмента, после которого начинается новый
+	maxAge      time.Duration    // максимальный возраст пакета (0 - без ограничения)
+	active      bool             // дописывается ли последний сегмент в текущем запуске
+	mu          sync.Mutex       // защита состояния очереди
+	sendMu      sync.Mutex       // не позволяет отправлять очередь параллельно
+}
+
+// New создаёт и инициализирует новый экзепляр *DiskQueue.
+// Если каталог уже содержит очередь (например, после перезапуска агента), её пакеты сохраняются.
+//
+// Параметры:
+//   - dir: каталог для файлов очереди
+//   - maxSize: максимальный объём очереди (в байтах)
+//   - maxAge: максимальный возраст пакета (0 - без ограничения)
+//   - log: логгер
+func New(dir string, maxSize int64, maxAge time.Duration, log logger.Logger) (*DiskQueue, error) {
+	if maxSize <= 0 {
+		return nil, errors.New("queue max size must be positive")
+	}
+	if err := os.MkdirAll(dir, dirPermission); err != nil {
+		return nil, fmt.Errorf("create queue dir error: %w", err)
+	}
+
+	q := &DiskQueue{
+		log:         log,
+		now:         time.Now,
+		dir:         dir,
+		nextID:      1,
+		maxSize:     maxSize,
+		segmentSize: max(maxSize/segmentsPerQueue, 1),
+		maxAge:      maxAge,
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Push добавляет пакет метрик в конец очереди.
+// Если очередь превышает максимальный объём, самые старые пакеты удаляются.
+//
+// Параметры:
+//   - ms: пакет метрик
+func (q *DiskQueue) Push(ms []entity.Metrics) error {
+	if len(ms) == 0 {
+		return nil
+	}
+
+	line, err := json.Marshal(record{Created: q.now(), Key: uuid.New().String(), Metrics: ms})
+	if err != nil {
+		return fmt.Errorf("marshal batch error: %w", err)
+	}
+	line = append(line, '\n')
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.appendLine(line); err != nil {
+		return err
+	}
+
+	dropped := 0
+	for q.size > q.maxSize {
+		_, n, err := q.readHead()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		if err := q.advance(n); err != nil {
+			return err
+		}
+		dropped++
+	}
+	if dropped > 0 {
+		q.log.Warn("Queue is full, oldest batches dropped", nil, "count", dropped)
+	}
+	return nil
+}
+
+// Drain отправляет пакеты из очереди по порядку, пока очередь не опустеет
+// или отправка не завершится ошибкой. Неотправленный пакет остаётся в начале очереди.
+// Возвращает количество отправленных пакетов.
+//
+// Параметры:
+//   - ctx: контекст для отмены
+//   - send: функция отправки пакета
+func (q *DiskQueue) Drain(ctx context.Context, send SendFunc) (int, error) {
+	q.sendMu.Lock()
+	defer q.sendMu.Unlock()
+
+	sent := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return sent, fmt.Errorf("drain queue canceled: %w", err)
+		}
+
+		q.mu.Lock()
+		rec, pos, n, err := q.peek()
+		q.mu.Unlock()
+		if err != nil {
+			return sent, err
+		}
+		if rec == nil {
+			return sent, nil
+		}
+
+		if err := send(ctx, rec.Metrics, rec.Key); err != nil {
+			return sent, err
+		}
+		sent++
+
+		q.mu.Lock()
+		err = q.ack(pos, n)
+		q.mu.Unlock()
+		if err != nil {
+			return sent, err
+		}
+	}
+}
+
+// Size возвращает объём неотправленных данных в очереди (в байтах).
+func (q *DiskQueue) Size() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.size
+}
+
+// peek возвращает первый неустаревший пакет очереди и его позицию.
+// Устаревшие и повреждённые пакеты удаляются из очереди.
+func (q *DiskQueue) peek() (*record, cursor, int64, error) {
+	dropped := 0
+	defer func() {
+		if dropped > 0 {
+			q.log.Warn("Expired batches dropped from queue", nil, "count", dropped)
+		}
+	}()
+
+	for {
+		line, n, err := q.readHead()
+		if err != nil || n == 0 {
+			return nil, cursor{}, 0, err
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			q.log.Warn("Skipping corrupted queue record", err)
+			if err := q.advance(n); err != nil {
+				return nil, cursor{}, 0, err
+			}
+			continue
+		}
+		if rec.Key == "" {
+			// пакет записан без ключа: ключ выводится из содержимого, чтобы не меняться между попытками
+			sum := sha256.Sum256(line)
+			rec.Key = hex.EncodeToString(sum[:])
+		}
+		if q.maxAge > 0 && q.now().Sub(rec.Created) > q.maxAge {
+			if err := q.advance(n); err != nil {
+				return nil, cursor{}, 0, err
+			}
+			dropped++
+			continue
+		}
+
+		return &rec, cursor{Segment: q.segments[0].id, Offset: q.offset}, n, nil
+	}
+}
+
+// ack удаляет отправленный пакет из начала очереди,
+// если за время отправки он не был вытеснен более новыми пакетами.
+func (q *DiskQueue) ack(pos cursor, n int64) error {
+	if len(q.segments) == 0 || q.segments[0].id != pos.Segment || q.offset != pos.Offset {
+		return nil
+	}
+	return q.advance(n)
+}
+
+// readHead читает первую строку очереди. Возвращает нулевую длину, если очередь пуста.
+func (q *DiskQueue) readHead() ([]byte, int64, error) {
+	for len(q.segments) > 0 {
+		seg := q.segments[0]
+		if q.offset >= seg.size {
+			if len(q.segments) == 1 && q.active {
+				return nil, 0, nil
+			}
+			if err := q.removeHeadSegment(); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+
+		line, err := q.readLine(seg.id, q.offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		return line, int64(len(line)), nil
+	}
+	return nil, 0, nil
+}
+
+// readLine читает строку сегмента, начиная со смещения offset.
+// Незавершённая строка в конце файла (например, после сбоя) возвращается как есть.
+func (q *DiskQueue) readLine(id uint64, offset int64) ([]byte, error) {
+	f, err := os.Open(q.segmentPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("open queue segment error: %w", err)
+	}
+	defer f.Close() //nolint:errcheck // файл открыт только для чтения
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek queue segment error: %w", err)
+	}
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("read queue segment error: %w", err)
+	}
+	return line, nil
+}
+
+// advance сдвигает позицию чтения на n байт и сохраняет её на диск.
+func (q *DiskQueue) advance(n int64) error {
+	q.offset += n
+	q.size -= n
+	if q.offset >= q.segments[0].size && (len(q.segments) > 1 || !q.active) {
+		return q.removeHeadSegment()
+	}
+	return q.saveCursor()
+}
+
+// appendLine дописывает строку в последний сегмент, при необходимости начиная новый.
+func (q *DiskQueue) appendLine(line []byte) error {
+	n := int64(len(line))
+	if !q.active || q.segments[len(q.segments)-1].size+n > q.segmentSize {
+		q.segments = append(q.segments, segment{id: q.nextID})
+		q.nextID++
+		q.active = true
+	}
+	last := &q.segments[len(q.segments)-1]
+
+	f, err := os.OpenFile(q.segmentPath(last.id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePermission)
+	if err != nil {
+		return fmt.Errorf("open queue segment error: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write queue segment error: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("sync queue segment error: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close queue segment error: %w", err)
+	}
+
+	last.size += n
+	q.size += n
+	return nil
+}
+
+// removeHeadSegment удаляет первый (полностью прочитанный) сегмент.
+func (q *DiskQueue) removeHeadSegment() error {
+	seg := q.segments[0]
+	q.size -= seg.size - q.offset
+	q.segments = q.segments[1:]
+	q.offset = 0
+	if len(q.segments) == 0 {
+		q.active = false
+	}
+
+	if err := os.Remove(q.segmentPath(seg.id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove queue segment error: %w", err)
+	}
+	return q.saveCursor()
+}
+
+// saveCursor атомарно сохраняет позицию чтения на диск.
+func (q *DiskQueue) saveCursor() error {
+	c := cursor{Segment: q.nextID}
+	if len(q.segments) > 0 {
+		c = cursor{Segment: q.segments[0].id, Offset: q.offset}
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshal queue cursor error: %w", err)
+	}
+
+	path := filepath.Join(q.dir, cursorFileName)
+	if err := os.WriteFile(path+".tmp", data, filePermission); err != nil {
+		return fmt.Errorf("write queue cursor error: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("rename queue cursor error: %w", err)
+	}
+	return nil
+}
+
+// load восстанавливает состояние очереди из каталога.
+// Новые пакеты всегда пишутся в новый сегмент, чтобы не дописывать их к возможно оборванной строке.
+func (q *DiskQueue) load() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("read queue dir error: %w", err)
+	}
+
+	var c cursor
+	if data, err := os.ReadFile(filepath.Join(q.dir, cursorFileName)); err == nil {
+		if err := json.Unmarshal(data, &c); err != nil {
+			q.log.Warn("Queue cursor is corrupted, replaying from the beginning", err)
+			c = cursor{}
+		}
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return fmt.Errorf("stat queue segment error: %w", err)
+		}
+		q.nextID = max(q.nextID, id+1)
+		if id < c.Segment {
+			// сегмент уже отправлен, но не был удалён до остановки агента
+			if err := os.Remove(q.segmentPath(id)); err != nil {
+				return fmt.Errorf("remove queue segment error: %w", err)
+			}
+			continue
+		}
+		q.segments = append(q.segments, segment{id: id, size: info.Size()})
+		q.size += info.Size()
+	}
+	slices.SortFunc(q.segments, func(a, b segment) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	if len(q.segments) > 0 && q.segments[0].id == c.Segment {
+		q.offset = min(c.Offset, q.segments[0].size)
+		q.size -= q.offset
+	}
+	q.nextID = max(q.nextID, c.Segment)
+	return nil
+}
+
+// segmentPath возвращает путь до файла-сегмента.
+func (q *DiskQueue) segmentPath(id uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%s%020d%s", segmentPrefix, id, segmentSuffix))
+}
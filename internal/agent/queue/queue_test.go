@@ -0,0 +1,155 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func batch(name string) []entity.Metrics {
+	delta := int64(1)
+	return []entity.Metrics{{ID: name, MType: entity.Counter, Delta: &delta}}
+}
+
+// collect возвращает функцию отправки, запоминающую имена отправленных пакетов.
+func collect(names *[]string) SendFunc {
+	return func(ctx context.Context, ms []entity.Metrics, _ string) error {
+		*names = append(*names, ms[0].ID)
+		return nil
+	}
+}
+
+func TestDiskQueue_DrainInOrder(t *testing.T) {
+	q, err := New(t.TempDir(), 1<<20, 0, &testutil.MockLogger{})
+	require.NoError(t, err)
+
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, q.Push(batch(name)))
+	}
+
+	var sent []string
+	n, err := q.Drain(context.Background(), collect(&sent))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []string{"a", "b", "c"}, sent)
+	assert.Equal(t, int64(0), q.Size())
+}
+
+func TestDiskQueue_SendErrorKeepsBatch(t *testing.T) {
+	q, err := New(t.TempDir(), 1<<20, 0, &testutil.MockLogger{})
+	require.NoError(t, err)
+
+	require.NoError(t, q.Push(batch("a")))
+	require.NoError(t, q.Push(batch("b")))
+
+	sendErr := errors.New("server is down")
+	n, err := q.Drain(context.Background(), func(ctx context.Context, ms []entity.Metrics, _ string) error {
+		return sendErr
+	})
+	require.ErrorIs(t, err, sendErr)
+	assert.Equal(t, 0, n)
+
+	var sent []string
+	_, err = q.Drain(context.Background(), collect(&sent))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, sent)
+}
+
+func TestDiskQueue_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	q, err := New(dir, 1<<20, 0, &testutil.MockLogger{})
+	require.NoError(t, err)
+
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, q.Push(batch(name)))
+	}
+
+	// отправляем только первый пакет
+	calls := 0
+	_, err = q.Drain(context.Background(), func(ctx context.Context, ms []entity.Metrics, _ string) error {
+		calls++
+		if calls > 1 {
+			return errors.New("server is down")
+		}
+		return nil
+	})
+	require.Error(t, err)
+
+	q, err = New(dir, 1<<20, 0, &testutil.MockLogger{})
+	require.NoError(t, err)
+	require.NoError(t, q.Push(batch("d")))
+
+	var sent []string
+	_, err = q.Drain(context.Background(), collect(&sent))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c", "d"}, sent)
+}
+
+func TestDiskQueue_MaxSizeDropsOldest(t *testing.T) {
+	q, err := New(t.TempDir(), 1<<20, 0, &testutil.MockLogger{})
+	require.NoError(t, err)
+	require.NoError(t, q.Push(batch("a")))
+	lineSize := q.Size()
+
+	// очередь вмещает ровно два пакета
+	q, err = New(t.TempDir(), 2*lineSize, 0, &testutil.MockLogger{})
+	require.NoError(t, err)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, q.Push(batch(name)))
+	}
+	assert.LessOrEqual(t, q.Size(), 2*lineSize)
+
+	var sent []string
+	_, err = q.Drain(context.Background(), collect(&sent))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, sent)
+}
+
+func TestDiskQueue_MaxAgeDropsExpired(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	q, err := New(t.TempDir(), 1<<20, time.Minute, &testutil.MockLogger{})
+	require.NoError(t, err)
+	q.now = func() time.Time { return now }
+
+	require.NoError(t, q.Push(batch("a")))
+	now = now.Add(2 * time.Minute)
+	require.NoError(t, q.Push(batch("b")))
+
+	var sent []string
+	_, err = q.Drain(context.Background(), collect(&sent))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, sent)
+}
+
+func TestDiskQueue_ReplayReusesKey(t *testing.T) {
+	dir := t.TempDir()
+	q, err := New(dir, 1<<20, 0, &testutil.MockLogger{})
+	require.NoError(t, err)
+	require.NoError(t, q.Push(batch("a")))
+
+	var keys []string
+	_, err = q.Drain(context.Background(), func(ctx context.Context, ms []entity.Metrics, key string) error {
+		keys = append(keys, key)
+		return errors.New("server is down")
+	})
+	require.Error(t, err)
+
+	// пакет повторяется после перезапуска агента
+	q, err = New(dir, 1<<20, 0, &testutil.MockLogger{})
+	require.NoError(t, err)
+	_, err = q.Drain(context.Background(), func(ctx context.Context, ms []entity.Metrics, key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, keys, 2)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1])
+}
@@ -4,12 +4,13 @@ package reporter
 
 import (
 	"context"
-	"strconv"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/Mr-Filatik/go-metrics-collector/internal/agent/metric"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/agent/queue"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/client"
-	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
 )
 
@@ -23,6 +24,7 @@ import (
 //   - reportInterval: интервал отправки метрик (в секундах)
 //   - hashKey: ключ для хэширования метрик
 //   - lim: количество параллельных воркеров
+//   - q: очередь отправки на диске (nil - метрики отправляются напрямую)
 //   - log: логгер
 func Run(
 	ctx context.Context,
@@ -30,12 +32,13 @@ func Run(
 	reportInterval int64,
 	lim int64,
 	cl client.Client,
+	q *queue.DiskQueue,
 	log logger.Logger) {
 	jobs := make(chan struct{}, lim)
 	defer close(jobs)
 
 	for w := int64(1); w <= lim; w++ {
-		go worker(ctx, m, cl, log, jobs)
+		go worker(ctx, m, cl, q, log, jobs)
 	}
 
 	ticker := time.NewTicker(time.Duration(reportInterval) * time.Second)
@@ -59,6 +62,7 @@ func worker(
 	ctx context.Context,
 	m *metric.AgentMetrics,
 	cl client.Client,
+	q *queue.DiskQueue,
 	log logger.Logger,
 	jobs <-chan struct{},
 ) {
@@ -67,43 +71,37 @@ func worker(
 		case <-ctx.Done():
 			return
 		case <-jobs:
-			var metrics []entity.Metrics
-			gMetrics := m.GetAllGaugeNames()
-			for _, name := range gMetrics {
-				met := m.GetByName(name)
-				if num, err := strconv.ParseFloat(met.Value, 64); err == nil {
-					metrics = append(metrics, entity.Metrics{
-						ID:    met.Name,
-						MType: met.Type,
-						Value: &num,
-					})
-				}
-			}
-			cMetrics := m.GetAllCounterNames()
-			for _, name := range cMetrics {
-				met := m.GetByName(name)
-				if num, err := strconv.ParseInt(met.Value, 10, 64); err == nil {
-					metrics = append(metrics, entity.Metrics{
-						ID:    met.Name,
-						MType: met.Type,
-						Delta: &num,
-					})
+			metrics := m.Take()
+
+			if q != nil {
+				qerr := q.Push(metrics)
+				if qerr == nil {
+					drainQueue(ctx, q, cl, log)
+					continue
 				}
+				log.Error("Queue metrics error, sending directly", qerr)
 			}
 
-			err := cl.SendMetrics(ctx, metrics)
+			err := cl.SendMetrics(ctx, metrics, uuid.New().String())
 			if err != nil {
 				log.Error("Sending metrics error", err)
+				m.Restore(metrics)
 				continue
 			}
 
 			log.Info("Send metrics success")
-
-			for _, name := range cMetrics {
-				m.ClearCounter(name)
-			}
-
-			log.Info("Clear counter metrics success")
 		}
 	}
 }
+
+// drainQueue отправляет накопленные в очереди пакеты по порядку.
+// При ошибке отправки оставшиеся пакеты ждут следующего запуска.
+func drainQueue(ctx context.Context, q *queue.DiskQueue, cl client.Client, log logger.Logger) {
+	sent, err := q.Drain(ctx, cl.SendMetrics)
+	if err != nil {
+		log.Error("Sending queued metrics error", err, "sent", sent, "queue_size", q.Size())
+		return
+	}
+
+	log.Info("Send queued metrics success", "sent", sent)
+}
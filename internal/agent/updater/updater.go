@@ -1,22 +1,25 @@
-// Пакет updater предоставляет реализацию воркера для отправки метрик на сервер.
-// Пакет использует клиент resty, поддерживает отправку наборами данных и их сжатие по алгоритму gzip.
+// Пакет updater предоставляет реализацию воркера для периодического сбора метрик агента.
+// Каждый сборщик опрашивается в отдельной горутине со своим интервалом.
 package updater
 
 import (
 	"context"
 	"time"
 
+	"github.com/Mr-Filatik/go-metrics-collector/internal/agent/collector"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/agent/metric"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
 )
 
-// Run запускает обновление основных метрик с заданным интервалом.
+// Run запускает опрос сборщика с его интервалом и сохраняет собранные метрики.
 //
 // Параметры:
 //   - ctx: контекст для отмены
+//   - c: сборщик метрик
 //   - m: объект метрик (AgentMetrics)
-//   - pollInterval: интервал обновления метрик (в секундах)
-func Run(ctx context.Context, m *metric.AgentMetrics, pollInterval int64) {
-	ticker := time.NewTicker(time.Duration(pollInterval) * time.Second)
+//   - log: логгер
+func Run(ctx context.Context, c collector.Collector, m *metric.AgentMetrics, log logger.Logger) {
+	ticker := time.NewTicker(c.Interval())
 	defer ticker.Stop()
 
 	for {
@@ -24,27 +27,12 @@ func Run(ctx context.Context, m *metric.AgentMetrics, pollInterval int64) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			m.Update()
-		}
-	}
-}
-
-// RunMemory запускает обновление метрик памяти приложения с заданным интервалом.
-//
-// Параметры:
-//   - ctx: контекст для отмены
-//   - m: объект метрик (AgentMetrics)
-//   - pollInterval: интервал обновления метрик (в секундах)
-func RunMemory(ctx context.Context, m *metric.AgentMetrics, pollInterval int64) {
-	ticker := time.NewTicker(time.Duration(pollInterval) * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			m.UpdateMemory()
+			ms, err := c.Collect(ctx)
+			if err != nil {
+				log.Warn("Collect metrics error", err, "collector", c.Name())
+			}
+			m.Add(ms)
+			log.Debug("Update metrics", "collector", c.Name(), "count", len(ms))
 		}
 	}
 }
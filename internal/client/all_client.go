@@ -56,11 +56,11 @@ func (c *AllClient) SendMetric(ctx context.Context, m entity.Metrics) error {
 	return nil
 }
 
-func (c *AllClient) SendMetrics(ctx context.Context, ms []entity.Metrics) error {
+func (c *AllClient) SendMetrics(ctx context.Context, ms []entity.Metrics, idempotencyKey string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	err := c.clients[c.current].SendMetrics(ctx, ms)
+	err := c.clients[c.current].SendMetrics(ctx, ms, idempotencyKey)
 	c.nextClient()
 	if err != nil {
 		return fmt.Errorf("send metric in AllClient error: %w", err)
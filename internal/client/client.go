@@ -21,5 +21,7 @@ type Client interface {
 	common.Starter
 	io.Closer
 	SendMetric(ctx context.Context, m entity.Metrics) error
-	SendMetrics(ctx context.Context, ms []entity.Metrics) error
+	// SendMetrics отправляет пакет метрик. Повторные отправки одного пакета должны передавать
+	// тот же ключ идемпотентности, чтобы сервер не применил пакет дважды.
+	SendMetrics(ctx context.Context, ms []entity.Metrics, idempotencyKey string) error
 }
@@ -2,8 +2,10 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -22,27 +24,41 @@ type GrpcClient struct {
 	conn                 *grpc.ClientConn
 	metricsServiceClient myProto.MetricsServiceClient
 	log                  logger.Logger
+	ctx                  context.Context // контекст клиента, ограничивающий время жизни потока
+	stream               metricsStream   // открытый поток метрик (nil - поток не открыт)
+	streamCancel         context.CancelFunc
 	url                  string
 	xRealIP              string
+	agentID              string
 	hashKey              string
+	sequence             uint64     // номер последнего отправленного в поток сообщения
+	streamMu             sync.Mutex // сообщения потока отправляются и подтверждаются по одному
+	useStream            bool       // отправлять метрики через поток StreamMetrics
 }
 
+// metricsStream - клиентская сторона потока StreamMetrics.
+type metricsStream = grpc.BidiStreamingClient[myProto.StreamMetricsRequest, myProto.StreamMetricsResponse]
+
 var _ Client = (*GrpcClient)(nil)
 
 // GrpcClientConfig - структура, содержащая основные параметры для RestyClient.
 type GrpcClientConfig struct {
 	URL     string
 	XRealIP string
+	AgentID string // идентификатор агента (пусто - сервер использует XRealIP)
 	HashKey string
+	Stream  bool // отправлять метрики через один долгоживущий поток StreamMetrics
 }
 
 // NewGrpcClient создаёт новый экземпляр *GrpcClient.
 func NewGrpcClient(config *GrpcClientConfig, l logger.Logger) *GrpcClient {
 	client := &GrpcClient{
-		log:     l,
-		xRealIP: config.XRealIP,
-		url:     config.URL,
-		hashKey: config.HashKey,
+		log:       l,
+		xRealIP:   config.XRealIP,
+		agentID:   config.AgentID,
+		url:       config.URL,
+		hashKey:   config.HashKey,
+		useStream: config.Stream,
 	}
 
 	if adr, err := common.ChangePortForGRPC(config.URL); err == nil {
@@ -52,7 +68,7 @@ func NewGrpcClient(config *GrpcClientConfig, l logger.Logger) *GrpcClient {
 	return client
 }
 
-func (c *GrpcClient) Start(_ context.Context) error {
+func (c *GrpcClient) Start(ctx context.Context) error {
 	c.log.Info(
 		"Start GrpcClient...",
 		"address", c.url,
@@ -65,6 +81,7 @@ func (c *GrpcClient) Start(_ context.Context) error {
 		return fmt.Errorf("start GrpcClient error: %w", connErr)
 	}
 
+	c.ctx = ctx
 	c.conn = conn
 	c.metricsServiceClient = myProto.NewMetricsServiceClient(c.conn)
 	c.log.Info("Start GrpcClient is successfull")
@@ -82,52 +99,160 @@ func (c *GrpcClient) SendMetric(_ context.Context, _ entity.Metrics) error {
 	return nil
 }
 
-func (c *GrpcClient) SendMetrics(ctx context.Context, ms []entity.Metrics) error {
+func (c *GrpcClient) SendMetrics(ctx context.Context, ms []entity.Metrics, idempotencyKey string) error {
 	if c.conn == nil {
 		err := fmt.Errorf("GrpcClient: %w", ErrClientNotStarted)
 		c.log.Error("Error in *GrpcClient.SendMetrics()", err)
 		return err
 	}
 
-	metrics := make([]*myProto.Metric, 0, len(ms))
-
-	for i := range ms {
-		pm := &myProto.Metric{
-			Id:    ms[i].ID,
-			Mtype: ms[i].MType,
-			Value: ms[i].Value,
-			Delta: ms[i].Delta,
-		}
-		metrics = append(metrics, pm)
+	if c.useStream {
+		return c.sendToStream(ctx, ms, idempotencyKey)
 	}
 
 	req := &myProto.UpdateMetricsRequest{
-		Metrics: metrics,
+		Metrics: metricsToProto(ms),
 	}
 
-	data, merr := proto.Marshal(req)
-	if merr != nil {
-		c.log.Error("Failed to marshal request", merr)
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshal request error: %w", err)
 	}
-	hashStr, herr := common.HashBytesToString(data, c.hashKey)
-	if herr != nil {
-		c.log.Error("Calculate hash error", herr)
+	hashStr, err := common.HashBytesToString(data, c.hashKey)
+	if err != nil {
+		return fmt.Errorf("calculate hash error: %w", err)
 	}
 
 	md := metadata.Pairs(
 		strings.ToLower(common.HeaderXRealIP), c.xRealIP,
+		strings.ToLower(common.HeaderXAgentID), c.agentID,
 		strings.ToLower(common.HeaderHashSHA256), hashStr,
+		strings.ToLower(common.HeaderIdempotencyKey), idempotencyKey,
 	)
 	ctxUpd := metadata.NewOutgoingContext(ctx, md)
 
-	_, err := c.metricsServiceClient.UpdateMetrics(ctxUpd, req, grpc.UseCompressor(gzip.Name))
+	_, err = c.metricsServiceClient.UpdateMetrics(ctxUpd, req, grpc.UseCompressor(gzip.Name))
 	if err != nil {
-		c.log.Error("UpdateMetric error", err)
+		return fmt.Errorf("update metrics error: %w", err)
 	}
 
+	c.log.Debug("Send metrics success", "url", c.url)
 	return nil
 }
 
+// sendToStream отправляет пакет метрик в поток StreamMetrics и ждёт его подтверждения.
+// Поток открывается при первой отправке и используется повторно;
+// при ошибке потока он закрывается и открывается заново при следующей отправке.
+// Ключ идемпотентности передаётся вызывающим и сохраняется для всех повторов пакета.
+func (c *GrpcClient) sendToStream(ctx context.Context, ms []entity.Metrics, idempotencyKey string) error {
+	c.streamMu.Lock()
+	defer c.streamMu.Unlock()
+
+	if c.stream == nil {
+		if err := c.openStream(); err != nil {
+			return err
+		}
+	}
+
+	c.sequence++
+	req := &myProto.StreamMetricsRequest{
+		Sequence:       c.sequence,
+		Metrics:        metricsToProto(ms),
+		IdempotencyKey: idempotencyKey,
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshal stream message error: %w", err)
+	}
+	hashStr, err := common.HashBytesToString(data, c.hashKey)
+	if err != nil {
+		return fmt.Errorf("calculate hash error: %w", err)
+	}
+	req.Hash = hashStr
+
+	type result struct {
+		resp *myProto.StreamMetricsResponse
+		err  error
+	}
+	// горутина работает только с локальной копией потока: поле c.stream очищается в closeStream
+	stream := c.stream
+	done := make(chan result, 1)
+	go func() {
+		if err := stream.Send(req); err != nil {
+			done <- result{err: fmt.Errorf("send stream message error: %w", err)}
+			return
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			err = fmt.Errorf("receive stream acknowledgement error: %w", err)
+		}
+		done <- result{resp: resp, err: err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		// подтверждение не получено, дальнейшие ответы потока нельзя сопоставить с сообщениями.
+		// Отмена контекста потока прерывает Send и Recv горутины, CloseSend вызывается после её завершения.
+		c.streamCancel()
+		<-done
+		c.closeStream()
+		return fmt.Errorf("wait stream acknowledgement error: %w", ctx.Err())
+	}
+	if res.err != nil {
+		c.closeStream()
+		return res.err
+	}
+	if res.resp.GetSequence() != req.GetSequence() {
+		c.closeStream()
+		return fmt.Errorf("unexpected stream acknowledgement %d for message %d",
+			res.resp.GetSequence(), req.GetSequence())
+	}
+	if res.resp.GetError() != "" {
+		return errors.New("stream message rejected: " + res.resp.GetError())
+	}
+
+	c.log.Debug("Send metrics to stream success", "sequence", req.GetSequence())
+	return nil
+}
+
+// openStream открывает поток StreamMetrics на время жизни клиента.
+func (c *GrpcClient) openStream() error {
+	ctx, cancel := context.WithCancel(c.ctx)
+	md := metadata.Pairs(
+		strings.ToLower(common.HeaderXRealIP), c.xRealIP,
+		strings.ToLower(common.HeaderXAgentID), c.agentID,
+	)
+
+	stream, err := c.metricsServiceClient.StreamMetrics(
+		metadata.NewOutgoingContext(ctx, md),
+		grpc.UseCompressor(gzip.Name))
+	if err != nil {
+		cancel()
+		return fmt.Errorf("open metrics stream error: %w", err)
+	}
+
+	c.log.Info("Metrics stream opened", "address", c.url)
+	c.stream = stream
+	c.streamCancel = cancel
+	c.sequence = 0
+	return nil
+}
+
+// closeStream закрывает открытый поток StreamMetrics.
+func (c *GrpcClient) closeStream() {
+	if c.stream == nil {
+		return
+	}
+	if err := c.stream.CloseSend(); err != nil {
+		c.log.Warn("Close metrics stream error", err)
+	}
+	c.streamCancel()
+	c.stream = nil
+	c.streamCancel = nil
+}
+
 func (c *GrpcClient) Close() error {
 	if c.conn == nil {
 		err := fmt.Errorf("GrpcClient: %w", ErrClientNotStarted)
@@ -135,9 +260,39 @@ func (c *GrpcClient) Close() error {
 		return err
 	}
 
+	c.streamMu.Lock()
+	c.closeStream()
+	c.streamMu.Unlock()
+
 	err := c.conn.Close()
 	if err != nil {
 		return fmt.Errorf("close *GrpcClient.Close() error: %w", err)
 	}
 	return nil
 }
+
+// metricsToProto преобразует метрики в сообщения protobuf.
+func metricsToProto(ms []entity.Metrics) []*myProto.Metric {
+	metrics := make([]*myProto.Metric, 0, len(ms))
+
+	for i := range ms {
+		pm := &myProto.Metric{
+			Id:     ms[i].ID,
+			Mtype:  ms[i].MType,
+			Value:  ms[i].Value,
+			Delta:  ms[i].Delta,
+			Labels: ms[i].Labels,
+		}
+		if h := ms[i].Histogram; h != nil {
+			pm.Histogram = &myProto.Histogram{
+				Bounds: h.Bounds,
+				Counts: h.Counts,
+				Sum:    h.Sum,
+				Count:  h.Count,
+			}
+		}
+		metrics = append(metrics, pm)
+	}
+
+	return metrics
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/agent/queue"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/common"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/testutil"
+	myProto "github.com/Mr-Filatik/go-metrics-collector/proto"
+)
+
+// testMetricsServer - сервер метрик, запоминающий ключи идемпотентности
+// и идентификаторы агентов вызовов. Первые fail вызовов завершаются ошибкой,
+// на первые silent сообщений потока сервер не отвечает.
+type testMetricsServer struct {
+	myProto.UnimplementedMetricsServiceServer
+	keys     []string
+	agentIDs []string
+	fail     int
+	silent   int
+	mu       sync.Mutex
+}
+
+func (s *testMetricsServer) UpdateMetrics(
+	ctx context.Context,
+	req *myProto.UpdateMetricsRequest,
+) (*myProto.UpdateMetricsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.keys = append(s.keys, strings.Join(md.Get(strings.ToLower(common.HeaderIdempotencyKey)), ","))
+	s.agentIDs = append(s.agentIDs, strings.Join(md.Get(strings.ToLower(common.HeaderXAgentID)), ","))
+	if len(s.keys) <= s.fail {
+		return nil, status.Error(codes.Unavailable, "storage is unavailable")
+	}
+	return &myProto.UpdateMetricsResponse{}, nil
+}
+
+func (s *testMetricsServer) StreamMetrics(
+	stream grpc.BidiStreamingServer[myProto.StreamMetricsRequest, myProto.StreamMetricsResponse],
+) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+
+		s.mu.Lock()
+		s.keys = append(s.keys, req.GetIdempotencyKey())
+		silent := len(s.keys) <= s.silent
+		resp := &myProto.StreamMetricsResponse{Sequence: req.GetSequence()}
+		if len(s.keys) <= s.fail {
+			resp.Error = "storage is unavailable"
+		}
+		s.mu.Unlock()
+
+		if silent {
+			continue
+		}
+
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *testMetricsServer) receivedKeys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.keys...)
+}
+
+// grpcPortOffset - на сколько gRPC-порт сервера больше HTTP-порта (см. common.ChangePortForGRPC).
+const grpcPortOffset = 10000
+
+// startTestGrpcServer запускает тестовый сервер метрик на адресе addr
+// ("127.0.0.1:0" - на свободном порту) и возвращает фактический адрес.
+func startTestGrpcServer(t *testing.T, addr string, srv *testMetricsServer) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+	gs := grpc.NewServer()
+	myProto.RegisterMetricsServiceServer(gs, srv)
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(gs.Stop)
+	return lis.Addr().String()
+}
+
+// reserveTestAddr возвращает свободный адрес, на котором пока никто не слушает.
+func reserveTestAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+	return addr
+}
+
+// startTestGrpcClient запускает клиент, который конструктор направляет на gRPC-адрес addr:
+// в конфигурации передаётся HTTP-адрес сервера, порт которого меньше на grpcPortOffset.
+func startTestGrpcClient(t *testing.T, addr string, config GrpcClientConfig) *GrpcClient {
+	t.Helper()
+	host, portStr, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+	require.Greater(t, port, grpcPortOffset, "test port must be above the gRPC port offset")
+	config.URL = net.JoinHostPort(host, strconv.Itoa(port-grpcPortOffset))
+
+	cl := NewGrpcClient(&config, &testutil.MockLogger{})
+	require.Equal(t, addr, cl.url)
+	require.NoError(t, cl.Start(context.Background()))
+	t.Cleanup(func() { _ = cl.Close() })
+	return cl
+}
+
+// pushTestBatch создаёт очередь отправки с одним пакетом метрик.
+func pushTestBatch(t *testing.T) *queue.DiskQueue {
+	t.Helper()
+	q, err := queue.New(t.TempDir(), 1<<20, 0, &testutil.MockLogger{})
+	require.NoError(t, err)
+	delta := int64(1)
+	require.NoError(t, q.Push([]entity.Metrics{{ID: "PollCount", MType: entity.Counter, Delta: &delta}}))
+	return q
+}
+
+func TestGrpcClient_QueueKeepsBatchWhileServerDown(t *testing.T) {
+	// сервер пока не запущен
+	addr := reserveTestAddr(t)
+	cl := startTestGrpcClient(t, addr, GrpcClientConfig{})
+	q := pushTestBatch(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	sent, err := q.Drain(ctx, cl.SendMetrics)
+	require.Error(t, err, "send to a down server must fail")
+	assert.Equal(t, 0, sent)
+	assert.Positive(t, q.Size(), "batch must stay in queue while server is down")
+
+	// сервер поднимается, но первый вызов завершается ошибкой
+	srv := &testMetricsServer{fail: 1}
+	startTestGrpcServer(t, addr, srv)
+
+	require.Eventually(t, func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		sent, err := q.Drain(ctx, cl.SendMetrics)
+		return err == nil && sent == 1
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Equal(t, int64(0), q.Size())
+
+	keys := srv.receivedKeys()
+	require.Len(t, keys, 2)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1], "replayed batch must reuse its idempotency key")
+}
+
+func TestGrpcClient_StreamReplayReusesKey(t *testing.T) {
+	srv := &testMetricsServer{fail: 1}
+	addr := startTestGrpcServer(t, "127.0.0.1:0", srv)
+	cl := startTestGrpcClient(t, addr, GrpcClientConfig{Stream: true})
+	q := pushTestBatch(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := q.Drain(ctx, cl.SendMetrics)
+	require.Error(t, err, "rejected stream message must fail")
+
+	sent, err := q.Drain(ctx, cl.SendMetrics)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	keys := srv.receivedKeys()
+	require.Len(t, keys, 2)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1], "replayed stream message must reuse its idempotency key")
+}
+
+func TestGrpcClient_SendsAgentID(t *testing.T) {
+	srv := &testMetricsServer{}
+	addr := startTestGrpcServer(t, "127.0.0.1:0", srv)
+	cl := startTestGrpcClient(t, addr, GrpcClientConfig{XRealIP: "10.0.0.1", AgentID: "agent-1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	delta := int64(1)
+	err := cl.SendMetrics(ctx, []entity.Metrics{{ID: "PollCount", MType: entity.Counter, Delta: &delta}}, "key1")
+	require.NoError(t, err)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	assert.Equal(t, []string{"agent-1"}, srv.agentIDs)
+}
+
+func TestGrpcClient_StreamCancelDuringSend(t *testing.T) {
+	srv := &testMetricsServer{silent: 1}
+	addr := startTestGrpcServer(t, "127.0.0.1:0", srv)
+	cl := startTestGrpcClient(t, addr, GrpcClientConfig{Stream: true})
+	delta := int64(1)
+	ms := []entity.Metrics{{ID: "PollCount", MType: entity.Counter, Delta: &delta}}
+
+	// подтверждение первого сообщения не приходит, отправка прерывается отменой контекста
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := cl.SendMetrics(ctx, ms, "key1")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// поток открывается заново, следующее сообщение подтверждается
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, cl.SendMetrics(ctx, ms, "key1"))
+	assert.Equal(t, []string{"key1", "key1"}, srv.receivedKeys())
+}
@@ -24,6 +24,7 @@ type RestyClient struct {
 	log         logger.Logger
 	url         string
 	xRealIP     string
+	agentID     string
 	hashKey     string
 }
 
@@ -34,6 +35,7 @@ type RestyClientConfig struct {
 	PublicKey *rsa.PublicKey
 	URL       string
 	XRealIP   string
+	AgentID   string // идентификатор агента (пусто - сервер использует XRealIP)
 	HashKey   string
 }
 
@@ -42,6 +44,7 @@ func NewRestyClient(config *RestyClientConfig, l logger.Logger) *RestyClient {
 	client := &RestyClient{
 		url:       config.URL + "/updates/",
 		xRealIP:   config.XRealIP,
+		agentID:   config.AgentID,
 		log:       l,
 		publicKey: config.PublicKey,
 		hashKey:   config.HashKey,
@@ -72,7 +75,7 @@ func (c *RestyClient) SendMetric(_ context.Context, m entity.Metrics) error {
 	return nil
 }
 
-func (c *RestyClient) SendMetrics(ctx context.Context, ms []entity.Metrics) error {
+func (c *RestyClient) SendMetrics(ctx context.Context, ms []entity.Metrics, idempotencyKey string) error {
 	if c.restyClient == nil {
 		err := fmt.Errorf("RestyClient: %w", ErrClientNotStarted)
 		c.log.Error("Error in *RestyClient.SendMetrics()", err)
@@ -97,6 +100,8 @@ func (c *RestyClient) SendMetrics(ctx context.Context, ms []entity.Metrics) erro
 				SetHeader(common.HeaderContentEncoding, common.HeaderEncodingValueGZIP).
 				SetHeader(common.HeaderAcceptEncoding, common.HeaderEncodingValueGZIP).
 				SetHeader(common.HeaderXRealIP, c.xRealIP).
+				SetHeader(common.HeaderXAgentID, c.agentID).
+				SetHeader(common.HeaderIdempotencyKey, idempotencyKey).
 				SetBody(dat).
 				SetContext(ctx).
 				Post(c.url)
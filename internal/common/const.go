@@ -9,6 +9,10 @@ const (
 	HeaderContentType                     = "Content-Type"     // тип данных запроса
 	HeaderContentTypeValueApplicationJSON = "application/json" // тип данных application/json
 	HeaderContentTypeValueTextHTML        = "text/html"        // тип данных text/html
+	// Тип данных текстового формата Prometheus 0.0.4.
+	HeaderContentTypeValuePrometheus = "text/plain; version=0.0.4; charset=utf-8"
+	// Тип данных потока Server-Sent Events.
+	HeaderContentTypeValueEventStream = "text/event-stream"
 
 	// Форматы сжатия.
 
@@ -18,7 +22,11 @@ const (
 
 	// Другое.
 
-	HeaderHashSHA256 = "HashSHA256"   // хэш-сумма контента запроса
-	HeaderXRealIP    = "X-Real-IP"    // IP сети клиента
-	HeaderXRequestID = "X-Request-Id" // ID запроса
+	HeaderHashSHA256               = "HashSHA256"      // хэш-сумма контента запроса
+	HeaderXRealIP                  = "X-Real-IP"       // IP сети клиента
+	HeaderXAgentID                 = "X-Agent-Id"      // идентификатор агента, отправившего метрики
+	HeaderXRequestID               = "X-Request-Id"    // ID запроса
+	HeaderIdempotencyKey           = "Idempotency-Key" // ключ идемпотентности пакета метрик
+	HeaderCacheControl             = "Cache-Control"   // правила кэширования ответа
+	HeaderCacheControlValueNoCache = "no-cache"        // ответ не кэшируется
 )
@@ -35,7 +35,7 @@ func DecompressBytes(data []byte) ([]byte, error) {
 		return nil, fmt.Errorf("gzip read data error: %w", readerErr)
 	}
 	var buf bytes.Buffer
-	_, copyErr := io.CopyN(&buf, reader, int64(buf.Len()))
+	_, copyErr := io.Copy(&buf, reader)
 	if copyErr != nil {
 		return nil, fmt.Errorf("gzip buffer copy error: %w", copyErr)
 	}
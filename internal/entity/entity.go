@@ -1,16 +1,65 @@
 // Пакет entity содержит общие сущности для проектов.
 package entity
 
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // Константы - типы метрик.
 const (
-	Gauge   string = "gauge"   // метрика gauge с заменяемым значением
-	Counter string = "counter" // метрика counter с накопительным значением
+	Gauge     string = "gauge"     // метрика gauge с заменяемым значением
+	Counter   string = "counter"   // метрика counter с накопительным значением
+	Histogram string = "histogram" // метрика histogram с распределением значений по корзинам
 )
 
 // Metrics описывает метрики для их хранения и обработки.
 type Metrics struct {
-	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
-	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
-	ID    string   `json:"id"`              // имя метрики
-	MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
+	Value     *float64          `json:"value,omitempty"`     // значение метрики в случае передачи gauge
+	Delta     *int64            `json:"delta,omitempty"`     // значение метрики в случае передачи counter
+	Labels    map[string]string `json:"labels,omitempty"`    // метки метрики (например, host)
+	Histogram *HistogramData    `json:"histogram,omitempty"` // значение метрики в случае передачи histogram
+	Sources   map[string]int64  `json:"sources,omitempty"`   // значения counter в разбивке по источникам (агентам)
+	UpdatedAt time.Time         `json:"-"`                   // время последнего изменения метрики на сервере (UTC)
+	ID        string            `json:"id"`                  // имя метрики
+	MType     string            `json:"type"`                // параметр, принимающий значение gauge, counter или histogram
+}
+
+// Key возвращает идентификатор метрики с учётом меток.
+// Метрики с одинаковым именем, но разными метками, считаются разными.
+// Для метрики без меток ключ совпадает с её именем.
+//
+// Формат ключа: name{key1="value1",key2="value2"}, метки отсортированы по ключу.
+func (m *Metrics) Key() string {
+	if len(m.Labels) == 0 {
+		return m.ID
+	}
+
+	keys := make([]string, 0, len(m.Labels))
+	for k := range m.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(m.ID)
+	sb.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(m.Labels[k]))
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// MetricPoint описывает значение метрики, зафиксированное сервером в определённый момент времени.
+type MetricPoint struct {
+	Time  time.Time `json:"time"`  // время получения значения сервером (UTC)
+	Value float64   `json:"value"` // значение gauge или накопленное значение counter
 }
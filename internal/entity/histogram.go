@@ -0,0 +1,127 @@
+package entity
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultHistogramBounds - границы корзин гистограммы по умолчанию (совпадают с корзинами Prometheus).
+var DefaultHistogramBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// ParseHistogramBounds разбирает границы корзин гистограммы из строки вида "0.1,0.5,1".
+// Для пустой строки возвращает DefaultHistogramBounds.
+//
+// Параметры:
+//   - s: границы корзин через запятую (строго по возрастанию)
+func ParseHistogramBounds(s string) ([]float64, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultHistogramBounds, nil
+	}
+
+	parts := strings.Split(s, ",")
+	bounds := make([]float64, 0, len(parts))
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, errors.New("invalid histogram bound: " + p)
+		}
+		if len(bounds) > 0 && v <= bounds[len(bounds)-1] {
+			return nil, errors.New("histogram bounds must be strictly increasing")
+		}
+		bounds = append(bounds, v)
+	}
+	return bounds, nil
+}
+
+// HistogramData описывает распределение наблюдаемых значений по корзинам.
+// Корзина i содержит наблюдения v, для которых Bounds[i-1] < v <= Bounds[i],
+// последняя корзина (Counts[len(Bounds)]) содержит значения больше всех границ.
+type HistogramData struct {
+	Bounds []float64 `json:"bounds"` // верхние границы корзин (строго по возрастанию)
+	Counts []int64   `json:"counts"` // количество наблюдений в каждой корзине (len(Bounds) + 1)
+	Sum    float64   `json:"sum"`    // сумма всех наблюдений
+	Count  int64     `json:"count"`  // общее количество наблюдений
+}
+
+// NewHistogram создаёт пустую гистограмму с указанными границами корзин.
+//
+// Параметры:
+//   - bounds: верхние границы корзин (строго по возрастанию)
+func NewHistogram(bounds []float64) *HistogramData {
+	return &HistogramData{
+		Bounds: slices.Clone(bounds),
+		Counts: make([]int64, len(bounds)+1),
+	}
+}
+
+// Observe добавляет одно наблюдение в гистограмму.
+// Возвращает false и не изменяет гистограмму, если значение равно NaN или бесконечности.
+//
+// Параметры:
+//   - v: наблюдаемое значение
+func (h *HistogramData) Observe(v float64) bool {
+	if !isFinite(v) {
+		return false
+	}
+	i, _ := slices.BinarySearch(h.Bounds, v)
+	h.Counts[i]++
+	h.Sum += v
+	h.Count++
+	return true
+}
+
+// IsValid проверяет согласованность гистограммы:
+// границы конечны и строго возрастают, количество корзин на одну больше количества границ,
+// значения корзин неотрицательны и в сумме дают Count, сумма наблюдений конечна.
+func (h *HistogramData) IsValid() bool {
+	if len(h.Counts) != len(h.Bounds)+1 || !isFinite(h.Sum) {
+		return false
+	}
+	for i, b := range h.Bounds {
+		if !isFinite(b) || (i > 0 && b <= h.Bounds[i-1]) {
+			return false
+		}
+	}
+	var total int64
+	for _, c := range h.Counts {
+		if c < 0 {
+			return false
+		}
+		total += c
+	}
+	return total == h.Count
+}
+
+// SameBounds проверяет, что гистограммы имеют одинаковые границы корзин.
+//
+// Параметры:
+//   - o: гистограмма для сравнения
+func (h *HistogramData) SameBounds(o *HistogramData) bool {
+	return slices.Equal(h.Bounds, o.Bounds)
+}
+
+// Merge возвращает новую гистограмму, объединяющую наблюдения обеих гистограмм.
+// Гистограммы должны иметь одинаковые границы корзин (см. SameBounds).
+//
+// Параметры:
+//   - o: добавляемая гистограмма
+func (h *HistogramData) Merge(o *HistogramData) *HistogramData {
+	merged := &HistogramData{
+		Bounds: slices.Clone(h.Bounds),
+		Counts: slices.Clone(h.Counts),
+		Sum:    h.Sum + o.Sum,
+		Count:  h.Count + o.Count,
+	}
+	for i := range merged.Counts {
+		merged.Counts[i] += o.Counts[i]
+	}
+	return merged
+}
+
+// isFinite проверяет, что значение не равно NaN и бесконечности.
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
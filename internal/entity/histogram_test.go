@@ -0,0 +1,80 @@
+package entity
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramObserve(t *testing.T) {
+	h := NewHistogram([]float64{1, 5})
+
+	for _, v := range []float64{0.5, 1, 3, 5, 10} {
+		h.Observe(v)
+	}
+
+	assert.Equal(t, []int64{2, 2, 1}, h.Counts)
+	assert.Equal(t, 19.5, h.Sum)
+	assert.Equal(t, int64(5), h.Count)
+	assert.True(t, h.IsValid())
+
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		assert.False(t, h.Observe(v))
+	}
+	assert.Equal(t, int64(5), h.Count, "invalid observations must be ignored")
+}
+
+func TestHistogramIsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		h        HistogramData
+		expected bool
+	}{
+		{name: "valid", h: HistogramData{Bounds: []float64{1}, Counts: []int64{1, 2}, Count: 3}, expected: true},
+		{name: "wrong counts length", h: HistogramData{Bounds: []float64{1}, Counts: []int64{1}, Count: 1}},
+		{name: "unsorted bounds", h: HistogramData{Bounds: []float64{2, 1}, Counts: []int64{0, 0, 0}}},
+		{name: "negative count", h: HistogramData{Bounds: []float64{1}, Counts: []int64{-1, 1}}},
+		{name: "count mismatch", h: HistogramData{Bounds: []float64{1}, Counts: []int64{1, 1}, Count: 5}},
+		{name: "NaN sum", h: HistogramData{Bounds: []float64{1}, Counts: []int64{1, 0}, Sum: math.NaN(), Count: 1}},
+		{name: "infinite bound", h: HistogramData{Bounds: []float64{1, math.Inf(1)}, Counts: []int64{0, 0, 0}}},
+		{name: "NaN bound", h: HistogramData{Bounds: []float64{math.NaN()}, Counts: []int64{0, 0}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.h.IsValid())
+		})
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a := &HistogramData{Bounds: []float64{1}, Counts: []int64{1, 0}, Sum: 0.5, Count: 1}
+	b := &HistogramData{Bounds: []float64{1}, Counts: []int64{2, 3}, Sum: 10, Count: 5}
+
+	require.True(t, a.SameBounds(b))
+	merged := a.Merge(b)
+
+	assert.Equal(t, []int64{3, 3}, merged.Counts)
+	assert.Equal(t, 10.5, merged.Sum)
+	assert.Equal(t, int64(6), merged.Count)
+	assert.Equal(t, []int64{1, 0}, a.Counts, "source histogram must not change")
+	assert.False(t, a.SameBounds(&HistogramData{Bounds: []float64{2}}))
+}
+
+func TestParseHistogramBounds(t *testing.T) {
+	bounds, err := ParseHistogramBounds("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultHistogramBounds, bounds)
+
+	bounds, err = ParseHistogramBounds("0.1, 0.5,1")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.1, 0.5, 1}, bounds)
+
+	_, err = ParseHistogramBounds("1,abc")
+	assert.Error(t, err)
+
+	_, err = ParseHistogramBounds("1,1")
+	assert.Error(t, err)
+}
@@ -5,19 +5,161 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/repository"
+	storage "github.com/Mr-Filatik/go-metrics-collector/internal/storage/file"
+	storageS3 "github.com/Mr-Filatik/go-metrics-collector/internal/storage/s3"
+	storageWAL "github.com/Mr-Filatik/go-metrics-collector/internal/storage/wal"
 )
 
+// Константы - схемы строки подключения к хранилищу в памяти.
+const (
+	Scheme     = "memory" // memory:// - метрики хранятся только в памяти
+	FileScheme = "file"   // file://путь - метрики хранятся в памяти и сохраняются в файл
+	WALScheme  = "wal"    // wal://путь - как file, но каждое изменение дописывается в журнал путь.wal
+
+	generationsParam = "generations" // параметр строки подключения: количество хранимых поколений снимка
+	formatParam      = "format"      // параметр строки подключения: формат записи снимка
+)
+
+// historyMaxPoints - максимальное количество точек истории одной метрики.
+// При превышении отбрасываются самые старые точки.
+const historyMaxPoints = 10000
+
 // MemoryRepository хранилище данных в оперативной памяти.
 type MemoryRepository struct {
-	log    logger.Logger    // логгер
-	dbConn string           // строка подключения
-	datas  []entity.Metrics // хранилище данных метрик
+	log       logger.Logger                   // логгер
+	history   map[string][]entity.MetricPoint // история значений метрик
+	dbConn    string                          // строка подключения
+	datas     []entity.Metrics                // хранилище данных метрик
+	mu        sync.RWMutex                    // защита метрик от конкурентного доступа
+	historyMu sync.RWMutex                    // защита истории от конкурентного доступа
+}
+
+var _ repository.HistoryRepository = (*MemoryRepository)(nil)
+
+func init() {
+	repository.Register(Scheme, func(dsn string, log logger.Logger) (*repository.Opened, error) {
+		return &repository.Opened{Repository: New(dsn, log)}, nil
+	})
+	repository.Register(FileScheme, func(dsn string, log logger.Logger) (*repository.Opened, error) {
+		opts, err := parseFileDSN(dsn, FileScheme)
+		if err != nil {
+			return nil, err
+		}
+		stor := storage.New(opts.path, log).SetGenerations(opts.generations).SetFormat(opts.format)
+		return &repository.Opened{Repository: New(dsn, log), Storage: stor}, nil
+	})
+	repository.Register(WALScheme, func(dsn string, log logger.Logger) (*repository.Opened, error) {
+		opts, err := parseFileDSN(dsn, WALScheme)
+		if err != nil {
+			return nil, err
+		}
+		stor, err := storageWAL.New(opts.path, log)
+		if err != nil {
+			return nil, err
+		}
+		stor.SetGenerations(opts.generations).SetFormat(opts.format)
+		closeStorage := func() {
+			if err := stor.Close(); err != nil {
+				log.Error("Close journal error", err)
+			}
+		}
+		return &repository.Opened{Repository: New(dsn, log), Storage: stor, Close: closeStorage}, nil
+	})
+	repository.Register(storageS3.Scheme, func(dsn string, log logger.Logger) (*repository.Opened, error) {
+		cfg, err := storageS3.ParseDSN(dsn, os.Getenv)
+		if err != nil {
+			return nil, err
+		}
+		stor, err := storageS3.New(cfg, log)
+		if err != nil {
+			return nil, err
+		}
+		return &repository.Opened{Repository: New(dsn, log), Storage: stor}, nil
+	})
 }
 
+// fileOptions описывает параметры файлового хранилища из строки подключения.
+type fileOptions struct {
+	path        string         // путь до файла снимка
+	format      storage.Format // формат записи снимка
+	generations int            // количество хранимых поколений снимка
+}
+
+// FileDSN собирает строку подключения к файловому хранилищу с экранированием пути,
+// чтобы символы '?' и '%' в пути не попадали в параметры.
+// Относительный путь начинается с "./", иначе его первый сегмент был бы разобран как хост.
+//
+// Параметры:
+//   - scheme: схема строки подключения (FileScheme или WALScheme)
+//   - path: путь до файла снимка
+//   - generations: количество хранимых поколений снимка
+//   - format: формат записи снимка
+func FileDSN(scheme string, path string, generations int, format string) string {
+	if path != "" && !filepath.IsAbs(path) {
+		path = "./" + path
+	}
+	query := url.Values{}
+	query.Set(generationsParam, strconv.Itoa(generations))
+	query.Set(formatParam, format)
+	u := url.URL{Scheme: scheme, Path: path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+// parseFileDSN разбирает строку подключения вида схема://путь[?generations=N&format=F].
+// По умолчанию хранится одно поколение снимка в формате JSON.
+//
+// Параметры:
+//   - dsn: строка подключения
+//   - scheme: схема строки подключения
+func parseFileDSN(dsn string, scheme string) (fileOptions, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return fileOptions{}, fmt.Errorf("parse %s dsn: %w", scheme, err)
+	}
+	// относительный путь без "./" разбирается как хост и продолжение пути
+	path := u.Host + u.Path
+	if u.Opaque != "" || path == "" {
+		return fileOptions{}, fmt.Errorf("expected %s://path", scheme)
+	}
+
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return fileOptions{}, fmt.Errorf("parse %s query: %w", scheme, err)
+	}
+	opts := fileOptions{path: path, generations: 1}
+	if v := query.Get(generationsParam); v != "" {
+		opts.generations, err = strconv.Atoi(v)
+		if err != nil || opts.generations < 1 {
+			return fileOptions{}, fmt.Errorf("invalid %s %q", generationsParam, v)
+		}
+	}
+	opts.format, err = storage.ParseFormat(query.Get(formatParam))
+	if err != nil {
+		return fileOptions{}, err
+	}
+	return opts, nil
+}
+
+// memoryTx - репозиторий внутри транзакции, работает с данными без повторного захвата блокировки.
+type memoryTx struct {
+	r       *MemoryRepository
+	removed []string // ключи удалённых метрик, история которых удаляется после фиксации
+}
+
+var _ repository.Repository = (*memoryTx)(nil)
+
 // New создаёт и инициализирует новый экзепляр *MemoryRepository.
 //
 // Параметры:
@@ -27,9 +169,10 @@ func New(dbConn string, l logger.Logger) *MemoryRepository {
 	l.Info("Create MemoryRepository")
 
 	return &MemoryRepository{
-		datas:  make([]entity.Metrics, 0),
-		log:    l,
-		dbConn: dbConn,
+		datas:   make([]entity.Metrics, 0),
+		history: make(map[string][]entity.MetricPoint),
+		log:     l,
+		dbConn:  dbConn,
 	}
 }
 
@@ -40,37 +183,55 @@ func (r *MemoryRepository) Ping(ctx context.Context) error {
 
 // GetAll возвращает все хранящиеся метрики или ошибку.
 func (r *MemoryRepository) GetAll(ctx context.Context) ([]entity.Metrics, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.getAll(), nil
+}
+
+func (r *MemoryRepository) getAll() []entity.Metrics {
 	if r.datas != nil {
 		r.log.Debug(
 			"Query all metrics from MemRepository",
 			"count", len(r.datas),
 		)
-		return r.datas, nil
+		return slices.Clone(r.datas)
 	}
 	r.log.Debug("Querying empty data in MemRepository")
-	return make([]entity.Metrics, 0), nil
+	return make([]entity.Metrics, 0)
 }
 
 // GetByID возвращает метрику по идентификатору или ошибку.
 //
 // Параметры:
-//   - id: идентификатор метрики
+//   - id: идентификатор метрики с учётом меток (entity.Metrics.Key)
 func (r *MemoryRepository) GetByID(ctx context.Context, id string) (entity.Metrics, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.getByID(id)
+}
+
+func (r *MemoryRepository) getByID(id string) (entity.Metrics, error) {
 	for _, v := range r.datas {
-		if v.ID == id {
+		if v.Key() == id {
 			r.log.Debug(
 				"Getting metric from MemRepository",
-				"id", v.ID,
+				"id", id,
 				"type", v.MType,
 				"value", v.Value,
 				"delta", v.Delta,
 			)
 
 			return entity.Metrics{
-				ID:    v.ID,
-				MType: v.MType,
-				Value: v.Value,
-				Delta: v.Delta,
+				ID:        v.ID,
+				MType:     v.MType,
+				Value:     v.Value,
+				Delta:     v.Delta,
+				Labels:    v.Labels,
+				Histogram: v.Histogram,
+				Sources:   v.Sources,
+				UpdatedAt: v.UpdatedAt,
 			}, nil
 		}
 	}
@@ -82,17 +243,24 @@ func (r *MemoryRepository) GetByID(ctx context.Context, id string) (entity.Metri
 // Параметры:
 //   - e: метрика
 func (r *MemoryRepository) Create(ctx context.Context, e entity.Metrics) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.create(e), nil
+}
+
+func (r *MemoryRepository) create(e entity.Metrics) string {
 	r.datas = append(r.datas, e)
 
 	r.log.Debug(
 		"Creating a new metric in MemRepository",
-		"id", e.ID,
+		"id", e.Key(),
 		"type", e.MType,
 		"value", e.Value,
 		"delta", e.Delta,
 	)
 
-	return e.ID, nil
+	return e.ID
 }
 
 // Update обновляет значение метрики или возвращает ошибку.
@@ -100,16 +268,27 @@ func (r *MemoryRepository) Create(ctx context.Context, e entity.Metrics) (string
 // Параметры:
 //   - e: метрика
 func (r *MemoryRepository) Update(ctx context.Context, e entity.Metrics) (float64, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.update(e)
+}
+
+func (r *MemoryRepository) update(e entity.Metrics) (float64, int64, error) {
+	key := e.Key()
 	for i, v := range r.datas {
-		if v.ID == e.ID {
+		if v.Key() == key {
 			item := &r.datas[i]
 			item.Value = e.Value
 			item.MType = e.MType
 			item.Delta = e.Delta
+			item.Histogram = e.Histogram
+			item.Sources = e.Sources
+			item.UpdatedAt = e.UpdatedAt
 
 			r.log.Debug(
 				"Updating metric data in MemRepository",
-				"id", item.ID,
+				"id", item.Key(),
 				"type", item.MType,
 				"value", item.Value,
 				"delta", item.Delta,
@@ -134,15 +313,162 @@ func (r *MemoryRepository) Update(ctx context.Context, e entity.Metrics) (float6
 // Параметры:
 //   - e: метрика
 func (r *MemoryRepository) Remove(ctx context.Context, e entity.Metrics) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, err := r.remove(e)
+	if err != nil {
+		return "", err
+	}
+	r.removeHistory(e.Key())
+	return id, nil
+}
+
+func (r *MemoryRepository) remove(e entity.Metrics) (string, error) {
+	key := e.Key()
 	for i, v := range r.datas {
-		if v.ID == e.ID {
-			r.log.Debug("Deleting a metric in MemRepository", "id", e.ID)
+		if v.Key() == key {
+			r.log.Debug("Deleting a metric in MemRepository", "id", key)
 
 			r.datas[i] = r.datas[len(r.datas)-1]
 			r.datas = r.datas[:len(r.datas)-1]
-
 			return e.ID, nil
 		}
 	}
 	return "", errors.New(repository.ErrorMetricNotFound)
 }
+
+// removeHistory удаляет историю значений метрики.
+func (r *MemoryRepository) removeHistory(key string) {
+	r.historyMu.Lock()
+	defer r.historyMu.Unlock()
+
+	delete(r.history, key)
+}
+
+// InTransaction выполняет fn, удерживая блокировку репозитория на всё время выполнения.
+// Если fn вернула ошибку, данные восстанавливаются из снимка, сделанного перед началом.
+// История удалённых в транзакции метрик удаляется только после её успешного завершения.
+//
+// Параметры:
+//   - fn: функция, выполняющая изменения через переданный ей репозиторий
+func (r *MemoryRepository) InTransaction(
+	ctx context.Context,
+	fn func(ctx context.Context, tx repository.Repository) error,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := slices.Clone(r.datas)
+	tx := &memoryTx{r: r}
+	if err := fn(ctx, tx); err != nil {
+		r.datas = snapshot
+		r.log.Debug("Rollback transaction in MemRepository", "error", err.Error())
+		return err
+	}
+	for _, key := range tx.removed {
+		r.removeHistory(key)
+	}
+	return nil
+}
+
+// AddPoint сохраняет значение метрики в историю.
+//
+// Параметры:
+//   - id: идентификатор метрики
+//   - p: значение метрики с временной меткой
+func (r *MemoryRepository) AddPoint(ctx context.Context, id string, p entity.MetricPoint) error {
+	r.historyMu.Lock()
+	defer r.historyMu.Unlock()
+
+	if r.history == nil {
+		r.history = make(map[string][]entity.MetricPoint)
+	}
+	points := append(r.history[id], p)
+	if len(points) > historyMaxPoints {
+		points = points[len(points)-historyMaxPoints:]
+	}
+	r.history[id] = points
+
+	r.log.Debug(
+		"Adding metric point to history in MemRepository",
+		"id", id,
+		"time", p.Time,
+		"value", p.Value,
+	)
+	return nil
+}
+
+// GetHistory возвращает историю значений метрики в интервале [from, to].
+//
+// Параметры:
+//   - id: идентификатор метрики
+//   - from: начало интервала
+//   - to: конец интервала
+func (r *MemoryRepository) GetHistory(
+	ctx context.Context,
+	id string,
+	from time.Time,
+	to time.Time,
+) ([]entity.MetricPoint, error) {
+	r.historyMu.RLock()
+	defer r.historyMu.RUnlock()
+
+	points := make([]entity.MetricPoint, 0)
+	for _, p := range r.history[id] {
+		if p.Time.Before(from) || p.Time.After(to) {
+			continue
+		}
+		points = append(points, p)
+	}
+
+	r.log.Debug(
+		"Query metric history from MemRepository",
+		"id", id,
+		"count", len(points),
+	)
+	return points, nil
+}
+
+// Ping проверяет доступность и готовность репозитория.
+func (t *memoryTx) Ping(ctx context.Context) error {
+	return nil
+}
+
+// GetAll возвращает все хранящиеся метрики или ошибку.
+func (t *memoryTx) GetAll(ctx context.Context) ([]entity.Metrics, error) {
+	return t.r.getAll(), nil
+}
+
+// GetByID возвращает метрику по идентификатору или ошибку.
+func (t *memoryTx) GetByID(ctx context.Context, id string) (entity.Metrics, error) {
+	return t.r.getByID(id)
+}
+
+// Create создаёт новую метрику или возвращает ошибку.
+func (t *memoryTx) Create(ctx context.Context, e entity.Metrics) (string, error) {
+	return t.r.create(e), nil
+}
+
+// Update обновляет значение метрики или возвращает ошибку.
+func (t *memoryTx) Update(ctx context.Context, e entity.Metrics) (float64, int64, error) {
+	return t.r.update(e)
+}
+
+// Remove удаляет метрику или возвращает ошибку.
+func (t *memoryTx) Remove(ctx context.Context, e entity.Metrics) (string, error) {
+	id, err := t.r.remove(e)
+	if err != nil {
+		return "", err
+	}
+	t.removed = append(t.removed, e.Key())
+	return id, nil
+}
+
+// InTransaction выполняет fn в рамках уже открытой транзакции.
+func (t *memoryTx) InTransaction(
+	ctx context.Context,
+	fn func(ctx context.Context, tx repository.Repository) error,
+) error {
+	return fn(ctx, t)
+}
@@ -2,10 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/repository"
+	storage "github.com/Mr-Filatik/go-metrics-collector/internal/storage/file"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -195,5 +199,200 @@ func TestRemove_NonExistent(t *testing.T) {
 	assert.Len(t, repo.datas, 1)
 }
 
+func TestLabeledIdentity(t *testing.T) {
+	repo := New("", &testutil.MockLogger{})
+	ctx := context.Background()
+
+	hostA := entity.Metrics{ID: "Alloc", MType: "gauge", Value: floatPtr(1), Labels: map[string]string{"host": "a"}}
+	hostB := entity.Metrics{ID: "Alloc", MType: "gauge", Value: floatPtr(2), Labels: map[string]string{"host": "b"}}
+	_, err := repo.Create(ctx, hostA)
+	require.NoError(t, err)
+	_, err = repo.Create(ctx, hostB)
+	require.NoError(t, err)
+
+	hostA.Value = floatPtr(10)
+	_, _, err = repo.Update(ctx, hostA)
+	require.NoError(t, err)
+
+	result, err := repo.GetByID(ctx, hostB.Key())
+	require.NoError(t, err)
+	assert.Equal(t, floatPtr(2), result.Value)
+	assert.Equal(t, hostB.Labels, result.Labels)
+
+	result, err = repo.GetByID(ctx, hostA.Key())
+	require.NoError(t, err)
+	assert.Equal(t, floatPtr(10), result.Value)
+
+	_, err = repo.GetByID(ctx, "Alloc")
+	assert.Error(t, err)
+}
+
+func TestInTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("commit", func(t *testing.T) {
+		repo := New("", &testutil.MockLogger{})
+		err := repo.InTransaction(ctx, func(ctx context.Context, tx repository.Repository) error {
+			_, err := tx.Create(ctx, entity.Metrics{ID: "metric1", MType: "counter", Delta: intPtr(1)})
+			if err != nil {
+				return err
+			}
+			_, _, err = tx.Update(ctx, entity.Metrics{ID: "metric1", MType: "counter", Delta: intPtr(3)})
+			return err
+		})
+		require.NoError(t, err)
+
+		result, err := repo.GetByID(ctx, "metric1")
+		require.NoError(t, err)
+		assert.Equal(t, intPtr(3), result.Delta)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		repo := New("", &testutil.MockLogger{})
+		_, err := repo.Create(ctx, entity.Metrics{ID: "metric1", MType: "gauge", Value: floatPtr(1)})
+		require.NoError(t, err)
+
+		err = repo.InTransaction(ctx, func(ctx context.Context, tx repository.Repository) error {
+			_, _, uerr := tx.Update(ctx, entity.Metrics{ID: "metric1", MType: "gauge", Value: floatPtr(2)})
+			require.NoError(t, uerr)
+			_, cerr := tx.Create(ctx, entity.Metrics{ID: "metric2", MType: "gauge", Value: floatPtr(3)})
+			require.NoError(t, cerr)
+			_, _, uerr = tx.Update(ctx, entity.Metrics{ID: "unknown", MType: "gauge", Value: floatPtr(4)})
+			return uerr
+		})
+		require.Error(t, err)
+
+		all, err := repo.GetAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+		assert.Equal(t, floatPtr(1), all[0].Value)
+	})
+}
+
+func TestHistory(t *testing.T) {
+	repo := New("", &testutil.MockLogger{})
+	ctx := context.Background()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		p := entity.MetricPoint{Time: start.Add(time.Duration(i) * time.Minute), Value: float64(i)}
+		require.NoError(t, repo.AddPoint(ctx, "metric1", p))
+	}
+	require.NoError(t, repo.AddPoint(ctx, "metric2", entity.MetricPoint{Time: start, Value: 100}))
+
+	points, err := repo.GetHistory(ctx, "metric1", start.Add(time.Minute), start.Add(3*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, points, 3)
+	assert.Equal(t, 1.0, points[0].Value)
+	assert.Equal(t, 3.0, points[2].Value)
+
+	points, err = repo.GetHistory(ctx, "unknown", start, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, points)
+}
+
+func TestHistory_Limit(t *testing.T) {
+	repo := New("", &testutil.MockLogger{})
+	ctx := context.Background()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range historyMaxPoints + 10 {
+		p := entity.MetricPoint{Time: start.Add(time.Duration(i) * time.Second), Value: float64(i)}
+		require.NoError(t, repo.AddPoint(ctx, "metric1", p))
+	}
+
+	points, err := repo.GetHistory(ctx, "metric1", start, start.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, points, historyMaxPoints)
+	assert.Equal(t, 10.0, points[0].Value)
+}
+
+func TestHistory_RemovedWithMetric(t *testing.T) {
+	repo := New("", &testutil.MockLogger{})
+	ctx := context.Background()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := repo.Create(ctx, entity.Metrics{ID: "metric1", MType: entity.Gauge, Value: floatPtr(1)})
+	require.NoError(t, err)
+	require.NoError(t, repo.AddPoint(ctx, "metric1", entity.MetricPoint{Time: start, Value: 1}))
+
+	_, err = repo.Remove(ctx, entity.Metrics{ID: "metric1", MType: entity.Gauge})
+	require.NoError(t, err)
+
+	points, err := repo.GetHistory(ctx, "metric1", start, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, points)
+}
+
+func TestHistory_KeptOnRollback(t *testing.T) {
+	repo := New("", &testutil.MockLogger{})
+	ctx := context.Background()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	metric := entity.Metrics{ID: "metric1", MType: entity.Gauge, Value: floatPtr(1)}
+	_, err := repo.Create(ctx, metric)
+	require.NoError(t, err)
+	require.NoError(t, repo.AddPoint(ctx, "metric1", entity.MetricPoint{Time: start, Value: 1}))
+
+	err = repo.InTransaction(ctx, func(ctx context.Context, tx repository.Repository) error {
+		_, rerr := tx.Remove(ctx, metric)
+		require.NoError(t, rerr)
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+
+	points, err := repo.GetHistory(ctx, "metric1", start, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, points, 1, "rolled back remove must keep history")
+
+	err = repo.InTransaction(ctx, func(ctx context.Context, tx repository.Repository) error {
+		_, rerr := tx.Remove(ctx, metric)
+		return rerr
+	})
+	require.NoError(t, err)
+
+	points, err = repo.GetHistory(ctx, "metric1", start, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, points, "committed remove must delete history")
+}
+
 func floatPtr(f float64) *float64 { return &f }
 func intPtr(i int64) *int64       { return &i }
+
+func TestParseFileDSN(t *testing.T) {
+	opts, err := parseFileDSN("file:///var/lib/metrics.json", FileScheme)
+	require.NoError(t, err)
+	assert.Equal(t, fileOptions{path: "/var/lib/metrics.json", format: storage.FormatJSON, generations: 1}, opts)
+
+	opts, err = parseFileDSN("wal://metrics.bin?generations=3&format=proto-gzip", WALScheme)
+	require.NoError(t, err)
+	assert.Equal(t, fileOptions{path: "metrics.bin", format: storage.FormatProtoGzip, generations: 3}, opts)
+
+	_, err = parseFileDSN("file://", FileScheme)
+	assert.Error(t, err)
+	_, err = parseFileDSN("file://metrics.json?generations=0", FileScheme)
+	assert.Error(t, err)
+	_, err = parseFileDSN("file://metrics.json?format=xml", FileScheme)
+	assert.Error(t, err)
+}
+
+func TestFileDSN_RoundTrip(t *testing.T) {
+	paths := []string{
+		"/var/lib/metrics.json",
+		"/var/lib/what?generations=5.json",
+		"/var/lib/100%.json",
+		"metrics.json",
+		"data/a?b%2F.json",
+		"../metrics.json",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			dsn := FileDSN(WALScheme, path, 2, "proto")
+			opts, err := parseFileDSN(dsn, WALScheme)
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Clean(path), filepath.Clean(opts.path))
+			assert.Equal(t, 2, opts.generations)
+			assert.Equal(t, storage.FormatProto, opts.format)
+		})
+	}
+}
@@ -0,0 +1,319 @@
+// Пакет migrations предоставляет версионные миграции схемы базы данных Postgres.
+//
+// Миграции хранятся во встроенных файлах sql/<версия>_<имя>.up.sql и sql/<версия>_<имя>.down.sql,
+// применённые версии записываются в таблицу schema_migrations. Миграции выполняются
+// под advisory-блокировкой, поэтому одновременно запущенные серверы не применяют их дважды.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// files содержит SQL-файлы миграций.
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+// sqlDir - каталог SQL-файлов миграций.
+const sqlDir = "sql"
+
+// lockID - ключ advisory-блокировки, под которой выполняются миграции.
+const lockID int64 = 0x6d6574726963736d
+
+// Запросы к таблице применённых миграций.
+const (
+	createTableQuery = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`
+	selectAppliedQuery = "SELECT version, applied_at FROM schema_migrations"
+	insertAppliedQuery = "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)"
+	deleteAppliedQuery = "DELETE FROM schema_migrations WHERE version = $1"
+)
+
+// fileNameRegexp - формат имени файла миграции: <версия>_<имя>.<up|down>.sql.
+var fileNameRegexp = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// ErrInvalidMigrations - ошибка, набор файлов миграций некорректен.
+var ErrInvalidMigrations = errors.New("invalid migrations")
+
+// Migration описывает одну миграцию схемы.
+type Migration struct {
+	Name    string // имя миграции
+	Up      string // SQL применения миграции
+	Down    string // SQL отката миграции
+	Version int64  // версия миграции
+}
+
+// Status описывает состояние миграции в базе данных.
+type Status struct {
+	AppliedAt time.Time // время применения (нулевое, если миграция не применена)
+	Name      string    // имя миграции
+	Version   int64     // версия миграции
+	Applied   bool      // применена ли миграция
+}
+
+// Migrator применяет и откатывает миграции схемы.
+type Migrator struct {
+	pool       *pgxpool.Pool // пул подключений к базе данных
+	log        logger.Logger // логгер
+	migrations []Migration   // миграции по возрастанию версии
+}
+
+// New создаёт и инициализирует новый экзепляр *Migrator со встроенными миграциями.
+//
+// Параметры:
+//   - pool: пул подключений к базе данных
+//   - log: логгер
+func New(pool *pgxpool.Pool, log logger.Logger) (*Migrator, error) {
+	ms, err := load(files, sqlDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		pool:       pool,
+		log:        log,
+		migrations: ms,
+	}, nil
+}
+
+// Up применяет все неприменённые миграции по возрастанию версии.
+// Возвращает количество применённых миграций.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		m.warnUnknown(applied)
+
+		for _, mg := range m.migrations {
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			if err := m.run(ctx, conn, mg, true); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down откатывает последние применённые миграции по убыванию версии.
+// Возвращает количество откаченных миграций.
+//
+// Параметры:
+//   - steps: количество откатываемых миграций
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if steps < 1 {
+		return 0, fmt.Errorf("invalid number of steps %d", steps)
+	}
+
+	count := 0
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, v := range versions[:min(steps, len(versions))] {
+			mg, ok := m.find(v)
+			if !ok {
+				return fmt.Errorf("%w: applied version %d is unknown", ErrInvalidMigrations, v)
+			}
+			if err := m.run(ctx, conn, mg, false); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status возвращает состояние всех известных миграций по возрастанию версии.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var res []Status
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		m.warnUnknown(applied)
+
+		res = make([]Status, 0, len(m.migrations))
+		for _, mg := range m.migrations {
+			at, ok := applied[mg.Version]
+			res = append(res, Status{Version: mg.Version, Name: mg.Name, AppliedAt: at, Applied: ok})
+		}
+		return nil
+	})
+	return res, err
+}
+
+// withLock выполняет fn на выделенном подключении под advisory-блокировкой.
+// Блокировка принадлежит сессии, поэтому все запросы выполняются на одном подключении.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection error: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("acquire migration lock error: %w", err)
+	}
+	defer func() {
+		// ctx может быть уже отменён, а блокировка должна быть снята до возврата подключения в пул
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockID); err != nil {
+			m.log.Error("Release migration lock error", err)
+			_ = conn.Conn().Close(context.Background())
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, createTableQuery); err != nil {
+		return fmt.Errorf("create schema_migrations error: %w", err)
+	}
+	return fn(conn)
+}
+
+// run применяет (up) или откатывает миграцию в одной транзакции с записью в schema_migrations.
+func (m *Migrator) run(ctx context.Context, conn *pgxpool.Conn, mg Migration, up bool) error {
+	query, record, args, action := mg.Down, deleteAppliedQuery, []any{mg.Version}, "down"
+	if up {
+		query, record, args, action = mg.Up, insertAppliedQuery, []any{mg.Version, mg.Name}, "up"
+	}
+
+	err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return fmt.Errorf("exec migration error: %w", err)
+		}
+		if _, err := tx.Exec(ctx, record, args...); err != nil {
+			return fmt.Errorf("record migration error: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s %s: %w", mg.Version, mg.Name, action, err)
+	}
+
+	m.log.Info("Migration is success", "version", mg.Version, "name", mg.Name, "direction", action)
+	return nil
+}
+
+// find возвращает миграцию по версии.
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, mg := range m.migrations {
+		if mg.Version == version {
+			return mg, true
+		}
+	}
+	return Migration{}, false
+}
+
+// warnUnknown предупреждает о применённых версиях, которых нет среди встроенных миграций
+// (база данных обновлена более новой версией сервера).
+func (m *Migrator) warnUnknown(applied map[int64]time.Time) {
+	for v := range applied {
+		if _, ok := m.find(v); !ok {
+			m.log.Warn("Unknown migration is applied", ErrInvalidMigrations, "version", v)
+		}
+	}
+}
+
+// appliedVersions возвращает применённые версии и время их применения.
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, selectAppliedQuery)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations error: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			v  int64
+			at time.Time
+		)
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations error: %w", err)
+		}
+		applied[v] = at.UTC()
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read schema_migrations error: %w", err)
+	}
+	return applied, nil
+}
+
+// load читает миграции из каталога dir и проверяет, что у каждой версии есть файлы up и down с одним именем.
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations error: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		parts := fileNameRegexp.FindStringSubmatch(e.Name())
+		if e.IsDir() || parts == nil {
+			return nil, fmt.Errorf("%w: unexpected file %q", ErrInvalidMigrations, e.Name())
+		}
+		version, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("%w: invalid version in %q", ErrInvalidMigrations, e.Name())
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration error: %w", err)
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = mg
+		}
+		if mg.Name != parts[2] {
+			return nil, fmt.Errorf("%w: version %d has different names", ErrInvalidMigrations, version)
+		}
+		if parts[3] == "up" {
+			mg.Up = string(data)
+		} else {
+			mg.Down = string(data)
+		}
+	}
+
+	res := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" || mg.Down == "" {
+			return nil, fmt.Errorf("%w: version %d needs up and down files", ErrInvalidMigrations, mg.Version)
+		}
+		res = append(res, *mg)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
@@ -0,0 +1,76 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Embedded(t *testing.T) {
+	ms, err := load(files, sqlDir)
+	require.NoError(t, err)
+	require.NotEmpty(t, ms)
+
+	for i, mg := range ms {
+		assert.Equal(t, int64(i+1), mg.Version, "versions must be sequential")
+		assert.NotEmpty(t, mg.Name)
+		assert.NotEmpty(t, mg.Up)
+		assert.NotEmpty(t, mg.Down)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := func(s string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(s)}
+	}
+
+	t.Run("sorted by version", func(t *testing.T) {
+		ms, err := load(fstest.MapFS{
+			"sql/0010_second.up.sql":   file("UP 10"),
+			"sql/0010_second.down.sql": file("DOWN 10"),
+			"sql/0002_first.up.sql":    file("UP 2"),
+			"sql/0002_first.down.sql":  file("DOWN 2"),
+		}, "sql")
+		require.NoError(t, err)
+		assert.Equal(t, []Migration{
+			{Version: 2, Name: "first", Up: "UP 2", Down: "DOWN 2"},
+			{Version: 10, Name: "second", Up: "UP 10", Down: "DOWN 10"},
+		}, ms)
+	})
+
+	tests := []struct {
+		fsys fstest.MapFS
+		name string
+	}{
+		{
+			name: "missing down",
+			fsys: fstest.MapFS{"sql/0001_init.up.sql": file("UP")},
+		},
+		{
+			name: "different names",
+			fsys: fstest.MapFS{
+				"sql/0001_init.up.sql":    file("UP"),
+				"sql/0001_other.down.sql": file("DOWN"),
+			},
+		},
+		{
+			name: "unexpected file",
+			fsys: fstest.MapFS{"sql/init.sql": file("UP")},
+		},
+		{
+			name: "zero version",
+			fsys: fstest.MapFS{
+				"sql/0000_init.up.sql":   file("UP"),
+				"sql/0000_init.down.sql": file("DOWN"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(tt.fsys, "sql")
+			assert.ErrorIs(t, err, ErrInvalidMigrations)
+		})
+	}
+}
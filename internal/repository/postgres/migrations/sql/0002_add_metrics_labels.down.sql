@@ -0,0 +1,3 @@
+ALTER TABLE metrics DROP COLUMN IF EXISTS histogram;
+ALTER TABLE metrics DROP COLUMN IF EXISTS labels;
+ALTER TABLE metrics DROP COLUMN IF EXISTS name;
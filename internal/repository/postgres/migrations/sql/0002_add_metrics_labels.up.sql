@@ -0,0 +1,4 @@
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS name TEXT;
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}'::jsonb;
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS histogram JSONB;
+UPDATE metrics SET name = id WHERE name IS NULL;
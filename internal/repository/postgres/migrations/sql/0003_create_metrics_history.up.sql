@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS metrics_history (
+    id TEXT NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS metrics_history_id_created_at_idx
+    ON metrics_history (id, created_at);
@@ -0,0 +1,82 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	logger "github.com/Mr-Filatik/go-metrics-collector/internal/logger/zap/sugar"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/repository"
+	repositoryPostgres "github.com/Mr-Filatik/go-metrics-collector/internal/repository/postgres"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/service"
+)
+
+// benchDSNEnv - переменная окружения со строкой подключения к тестовой базе данных.
+const benchDSNEnv = "TEST_DATABASE_DSN"
+
+// perMetricRepository скрывает пакетную запись репозитория,
+// чтобы сервис применял набор метрик по одной (GetByID, затем Create или Update).
+type perMetricRepository struct {
+	repository.Repository
+}
+
+// BenchmarkCreateOrUpdateBatch сравнивает применение набора метрик одним запросом (bulk)
+// с применением метрик по одной (per-metric). Набор от агента (bulk-source) дополнительно
+// накапливает разбивку counter по источникам.
+//
+//	TEST_DATABASE_DSN=postgres://... go test -run=^$ -bench=CreateOrUpdateBatch ./internal/repository/postgres/
+func BenchmarkCreateOrUpdateBatch(b *testing.B) {
+	dsn := os.Getenv(benchDSNEnv)
+	if dsn == "" {
+		b.Skipf("%s is not set", benchDSNEnv)
+	}
+
+	log := logger.New(logger.LevelError)
+	repo, err := repositoryPostgres.New(dsn, log)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer repo.Close()
+
+	paths := []struct {
+		repo   repository.Repository
+		name   string
+		source string
+	}{
+		{name: "per-metric", repo: perMetricRepository{repo}},
+		{name: "bulk", repo: repo},
+		{name: "bulk-source", repo: repo, source: "agent-1"},
+	}
+	for _, size := range []int{10, 100, 1000} {
+		for _, p := range paths {
+			b.Run(fmt.Sprintf("%s/%d", p.name, size), func(b *testing.B) {
+				s := service.New(p.repo, nil, 0, log)
+				batch := benchBatch(fmt.Sprintf("bench_%s_%d", p.name, size), size)
+				ctx := service.WithSource(context.Background(), p.source)
+
+				b.ResetTimer()
+				for range b.N {
+					if _, err := s.CreateOrUpdateBatch(ctx, batch); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// benchBatch возвращает набор из size метрик, поровну gauge и counter.
+func benchBatch(prefix string, size int) []entity.Metrics {
+	value, delta := 1.5, int64(1)
+	batch := make([]entity.Metrics, 0, size)
+	for i := range size {
+		m := entity.Metrics{ID: fmt.Sprintf("%s_%d", prefix, i), MType: entity.Gauge, Value: &value}
+		if i%2 == 1 {
+			m = entity.Metrics{ID: m.ID, MType: entity.Counter, Delta: &delta}
+		}
+		batch = append(batch, m)
+	}
+	return batch
+}
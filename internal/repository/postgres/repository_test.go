@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUpsertColumns(t *testing.T) {
+	value, delta := 1.5, int64(2)
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cols, err := newUpsertColumns([]entity.Metrics{
+		{ID: "Alloc", MType: entity.Gauge, Value: &value, Labels: map[string]string{"host": "a"}, UpdatedAt: at},
+		{ID: "PollCount", MType: entity.Counter, Delta: &delta, Sources: map[string]int64{"agent-1": 2}, UpdatedAt: at},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{`Alloc{host="a"}`, "PollCount"}, cols.ids)
+	assert.Equal(t, []string{"Alloc", "PollCount"}, cols.names)
+	assert.Equal(t, []string{entity.Gauge, entity.Counter}, cols.types)
+	assert.Equal(t, []*float64{&value, nil}, cols.values)
+	assert.Equal(t, []*int64{nil, &delta}, cols.deltas)
+	assert.Equal(t, []string{`{"host":"a"}`, `{}`}, cols.labels)
+	require.Len(t, cols.sources, 2)
+	assert.Nil(t, cols.sources[0])
+	assert.JSONEq(t, `{"agent-1":2}`, *cols.sources[1])
+	assert.Equal(t, []time.Time{at, at}, cols.updatedAt)
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/storage"
+)
+
+const (
+	schemeSeparator = "://"      // отделяет схему строки подключения от остальной части
+	keywordScheme   = "postgres" // схема строки подключения вида ключ=значение (host=... dbname=...)
+)
+
+// ErrUnknownScheme - ошибка, для схемы строки подключения не зарегистрирован репозиторий.
+var ErrUnknownScheme = errors.New("unknown repository scheme")
+
+// Opened описывает репозиторий, открытый по строке подключения.
+type Opened struct {
+	Repository Repository      // репозиторий
+	Storage    storage.Storage // хранилище для сохранения данных репозитория (nil, если репозиторий хранит их сам)
+	Close      func()          // освобождает ресурсы репозитория
+}
+
+// Factory открывает репозиторий по строке подключения.
+type Factory func(dsn string, log logger.Logger) (*Opened, error)
+
+var (
+	factories   = map[string]Factory{} // зарегистрированные репозитории по схеме
+	factoriesMu sync.RWMutex           // защищает factories
+)
+
+// Register регистрирует репозиторий для схемы строки подключения (например, postgres для postgres://...).
+// Вызывается из init пакета репозитория. Повторная регистрация схемы приводит к панике.
+//
+// Параметры:
+//   - scheme: схема строки подключения
+//   - f: функция открытия репозитория
+func Register(scheme string, f Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if f == nil {
+		panic("repository: Register factory is nil")
+	}
+	if _, ok := factories[scheme]; ok {
+		panic("repository: Register called twice for scheme " + scheme)
+	}
+	factories[scheme] = f
+}
+
+// Open открывает репозиторий, зарегистрированный для схемы строки подключения.
+//
+// Параметры:
+//   - dsn: строка подключения вида схема://... или ключ=значение для postgres
+//   - log: логгер
+func Open(dsn string, log logger.Logger) (*Opened, error) {
+	scheme := Scheme(dsn)
+
+	factoriesMu.RLock()
+	f, ok := factories[scheme]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q (registered: %s)", ErrUnknownScheme, scheme, strings.Join(Schemes(), ", "))
+	}
+
+	opened, err := f(dsn, log)
+	if err != nil {
+		return nil, fmt.Errorf("open %s repository: %w", scheme, err)
+	}
+	if opened.Close == nil {
+		opened.Close = func() {}
+	}
+	return opened, nil
+}
+
+// Scheme возвращает схему строки подключения (пустую строку, если схемы нет).
+// Строка без схемы вида ключ=значение (host=... user=... dbname=...) считается строкой подключения к postgres.
+//
+// Параметры:
+//   - dsn: строка подключения
+func Scheme(dsn string) string {
+	scheme, _, ok := strings.Cut(dsn, schemeSeparator)
+	if !ok {
+		if strings.Contains(dsn, "=") {
+			return keywordScheme
+		}
+		return ""
+	}
+	return scheme
+}
+
+// Schemes возвращает отсортированный список зарегистрированных схем.
+func Schemes() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	schemes := make([]string, 0, len(factories))
+	for s := range factories {
+		schemes = append(schemes, s)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
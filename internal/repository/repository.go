@@ -4,13 +4,15 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
 )
 
 // Константы - общие ошибки для репозиториев.
 const (
-	ErrorMetricNotFound = "metric not found" // ошибка, метрики не существует
+	ErrorMetricNotFound     = "metric not found"     // ошибка, метрики не существует
+	ErrorMetricTypeMismatch = "metric type mismatch" // ошибка, метрика уже хранится с другим типом
 )
 
 type Repository interface {
@@ -20,4 +22,30 @@ type Repository interface {
 	Create(ctx context.Context, e entity.Metrics) (string, error)
 	Update(ctx context.Context, e entity.Metrics) (float64, int64, error)
 	Remove(ctx context.Context, e entity.Metrics) (string, error)
+
+	// InTransaction выполняет fn атомарно: изменения, сделанные через tx, применяются
+	// все вместе, если fn вернула nil, и отменяются, если fn вернула ошибку.
+	// Внутри fn следует обращаться к данным только через tx.
+	InTransaction(ctx context.Context, fn func(ctx context.Context, tx Repository) error) error
+}
+
+// HistoryRepository описывает репозиторий, который помимо последнего значения
+// хранит историю всех принятых значений метрик.
+type HistoryRepository interface {
+	// AddPoint сохраняет значение метрики с временной меткой сервера.
+	AddPoint(ctx context.Context, id string, p entity.MetricPoint) error
+
+	// GetHistory возвращает значения метрики в интервале [from, to], упорядоченные по времени.
+	GetHistory(ctx context.Context, id string, from time.Time, to time.Time) ([]entity.MetricPoint, error)
+}
+
+// BulkRepository описывает репозиторий, который применяет набор метрик gauge и counter
+// одним запросом вместо чтения и записи каждой метрики по отдельности.
+type BulkRepository interface {
+	// Upsert атомарно создаёт или обновляет метрики набора: значение gauge заменяется,
+	// значение counter и его разбивка по источникам прибавляются к хранящимся.
+	// Ключи метрик в наборе не повторяются.
+	// Возвращает итоговые значения метрик в порядке набора или ошибку ErrorMetricTypeMismatch,
+	// если метрика уже хранится с другим типом.
+	Upsert(ctx context.Context, es []entity.Metrics) ([]entity.Metrics, error)
 }
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
+)
+
+// migrationFiles содержит SQL-файлы миграций схемы.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationsDir - каталог SQL-файлов миграций.
+const migrationsDir = "migrations"
+
+// migrationNameRegexp - формат имени файла миграции: <версия>_<имя>.sql.
+var migrationNameRegexp = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.sql$`)
+
+// ErrInvalidMigrations - ошибка, набор файлов миграций некорректен.
+var ErrInvalidMigrations = errors.New("invalid migrations")
+
+// migration описывает одну миграцию схемы.
+type migration struct {
+	name    string // имя миграции
+	query   string // SQL миграции
+	version int64  // версия миграции
+}
+
+// migrate применяет неприменённые миграции по возрастанию версии. Каждая миграция выполняется
+// в своей транзакции вместе с записью в schema_migrations. Транзакции записи в SQLite
+// выполняются строго по одной, поэтому одновременно запущенные серверы не применят миграцию дважды.
+func migrate(ctx context.Context, db *sql.DB, log logger.Logger) error {
+	ms, err := loadMigrations(migrationFiles, migrationsDir)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at INTEGER NOT NULL DEFAULT (unixepoch())
+	)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations error: %w", err)
+	}
+
+	for _, m := range ms {
+		applied, err := applyMigration(ctx, db, m)
+		if err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+		}
+		if applied {
+			log.Info("Migration is success", "version", m.version, "name", m.name)
+		}
+	}
+	return nil
+}
+
+// applyMigration применяет миграцию, если она ещё не применена. Возвращает true, если миграция применена.
+func applyMigration(ctx context.Context, db *sql.DB, m migration) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin transaction error: %w", err)
+	}
+	defer func() {
+		// после Commit откат ничего не делает
+		_ = tx.Rollback()
+	}()
+
+	var count int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE version = ?", m.version).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("query schema_migrations error: %w", err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, m.query); err != nil {
+		return false, fmt.Errorf("exec migration error: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.version, m.name)
+	if err != nil {
+		return false, fmt.Errorf("record migration error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit migration error: %w", err)
+	}
+	return true, nil
+}
+
+// loadMigrations читает миграции из каталога dir и сортирует их по версии.
+func loadMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations error: %w", err)
+	}
+
+	ms := make([]migration, 0, len(entries))
+	versions := make(map[int64]struct{}, len(entries))
+	for _, e := range entries {
+		parts := migrationNameRegexp.FindStringSubmatch(e.Name())
+		if e.IsDir() || parts == nil {
+			return nil, fmt.Errorf("%w: unexpected file %q", ErrInvalidMigrations, e.Name())
+		}
+		version, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("%w: invalid version in %q", ErrInvalidMigrations, e.Name())
+		}
+		if _, ok := versions[version]; ok {
+			return nil, fmt.Errorf("%w: duplicate version %d", ErrInvalidMigrations, version)
+		}
+		versions[version] = struct{}{}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration error: %w", err)
+		}
+		ms = append(ms, migration{version: version, name: parts[2], query: string(data)})
+	}
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].version < ms[j].version })
+	return ms, nil
+}
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS metrics (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    mtype TEXT NOT NULL,
+    value REAL,
+    delta INTEGER,
+    labels TEXT NOT NULL DEFAULT '{}',
+    histogram TEXT,
+    updated_at INTEGER NOT NULL
+);
@@ -0,0 +1,482 @@
+// Пакет repository предоставляет конкретную реализацию репозитория
+// для доступа к sqlite-хранилищу (файл базы данных SQLite на локальном диске).
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/repository"
+
+	// Регистрирует драйвер sqlite (реализация SQLite на чистом Go, без cgo).
+	_ "modernc.org/sqlite"
+)
+
+// Scheme - схема строки подключения к SQLite: sqlite:///абсолютный/путь.db или sqlite://относительный/путь.db.
+const Scheme = "sqlite"
+
+// connParams - параметры подключения: журнал WAL, ожидание блокировки вместо ошибки SQLITE_BUSY
+// и захват блокировки записи в начале транзакции (транзакции читают и затем изменяют метрики).
+const connParams = "_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)&_txlock=immediate"
+
+var (
+	ErrConnectionStart = errors.New("start connection error")
+	ErrQueryRun        = errors.New("query run error")
+	ErrScanData        = errors.New("scan data error")
+)
+
+// querier - общий интерфейс подключения и транзакции database/sql.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// SQLiteRepository хранилище данных в базе данных SQLite.
+type SQLiteRepository struct {
+	log    logger.Logger // логгер
+	conn   *sql.DB       // пул подключений к базе данных
+	db     querier       // исполнитель запросов: пул или открытая транзакция
+	dbConn string        // строка подключения к базе данных
+	inTx   bool          // выполняются ли запросы внутри транзакции
+}
+
+var _ repository.HistoryRepository = (*SQLiteRepository)(nil)
+
+func init() {
+	repository.Register(Scheme, func(dsn string, log logger.Logger) (*repository.Opened, error) {
+		repo, err := New(dsn, log)
+		if err != nil {
+			return nil, err
+		}
+		return &repository.Opened{Repository: repo, Close: repo.Close}, nil
+	})
+}
+
+// New создаёт и инициализирует новый экзепляр *SQLiteRepository.
+// Схема базы данных приводится к актуальной версии до возврата репозитория.
+//
+// Параметры:
+//   - dbConn: строка подключения к базе данных (sqlite://путь)
+//   - l: логгер
+func New(dbConn string, l logger.Logger) (*SQLiteRepository, error) {
+	path, ok := strings.CutPrefix(dbConn, Scheme+"://")
+	if !ok || path == "" {
+		l.Error("Error parsing connection string", fmt.Errorf("expected %s://path", Scheme))
+		return nil, ErrConnectionStart
+	}
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	conn, err := sql.Open("sqlite", path+sep+connParams)
+	if err != nil {
+		l.Error("Error opening database", err)
+		return nil, ErrConnectionStart
+	}
+
+	ctx := context.Background()
+	if err := conn.PingContext(ctx); err != nil {
+		l.Error("Error during ping", err)
+		_ = conn.Close()
+		return nil, ErrConnectionStart
+	}
+	if err := migrate(ctx, conn, l); err != nil {
+		l.Error("Error during migration", err)
+		_ = conn.Close()
+		return nil, ErrQueryRun
+	}
+
+	l.Info("Create SQLiteRepository")
+
+	return &SQLiteRepository{
+		log:    l,
+		dbConn: dbConn,
+		conn:   conn,
+		db:     conn,
+	}, nil
+}
+
+// Ping проверяет доступность и готовность репозитория.
+func (r *SQLiteRepository) Ping(ctx context.Context) error {
+	err := r.conn.PingContext(ctx)
+	if err != nil {
+		r.log.Error("Error during ping", err)
+		return ErrQueryRun
+	}
+
+	r.log.Info("Successful ping")
+	return nil
+}
+
+// GetAll возвращает все хранящиеся метрики или ошибку.
+func (r *SQLiteRepository) GetAll(ctx context.Context) ([]entity.Metrics, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT name, mtype, value, delta, labels, histogram, sources, updated_at FROM metrics")
+	if err != nil {
+		r.log.Error("Error during query execution", err)
+		return nil, ErrQueryRun
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	metrics := make([]entity.Metrics, 0)
+	for rows.Next() {
+		m, err := scanMetric(rows)
+		if err != nil {
+			r.log.Error("Error scanning row", err)
+			return nil, ErrScanData
+		}
+		metrics = append(metrics, m)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.Error("Error reading rows", err)
+		return nil, ErrQueryRun
+	}
+
+	r.log.Debug(
+		"Query all metrics from SQLiteRepository",
+		"count", len(metrics),
+	)
+	return metrics, nil
+}
+
+// GetByID возвращает метрику по идентификатору или ошибку.
+//
+// Параметры:
+//   - id: идентификатор метрики с учётом меток (entity.Metrics.Key)
+func (r *SQLiteRepository) GetByID(ctx context.Context, id string) (entity.Metrics, error) {
+	row := r.db.QueryRowContext(ctx,
+		"SELECT name, mtype, value, delta, labels, histogram, sources, updated_at FROM metrics WHERE id = ?", id)
+
+	m, err := scanMetric(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			r.log.Debug("Metric not found in SQLiteRepository", "id", id)
+			return entity.Metrics{}, errors.New(repository.ErrorMetricNotFound)
+		}
+		r.log.Error("Error during query execution", err)
+		return entity.Metrics{}, ErrQueryRun
+	}
+
+	r.log.Debug(
+		"Getting metric from SQLiteRepository",
+		"id", id,
+		"type", m.MType,
+		"value", m.Value,
+		"delta", m.Delta,
+	)
+	return m, nil
+}
+
+// Create создаёт новую метрику или возвращает ошибку.
+//
+// Параметры:
+//   - e: метрика
+func (r *SQLiteRepository) Create(ctx context.Context, e entity.Metrics) (string, error) {
+	cols, err := encodeJSONColumns(e)
+	if err != nil {
+		r.log.Error("Error encoding metric", err)
+		return "", errors.New("insert error")
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO metrics (id, name, mtype, value, delta, labels, histogram, sources, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Key(), e.ID, e.MType, e.Value, e.Delta, cols.labels, cols.histogram, cols.sources,
+		updatedAtOrNow(e.UpdatedAt).UnixNano())
+	if err != nil {
+		r.log.Error("Error during insert execution", err)
+		return "", errors.New("insert error")
+	}
+
+	r.log.Debug(
+		"Creating a new metric in SQLiteRepository",
+		"id", e.Key(),
+		"type", e.MType,
+		"value", e.Value,
+		"delta", e.Delta,
+	)
+	return e.ID, nil
+}
+
+// Update обновляет значение метрики или возвращает ошибку.
+//
+// Параметры:
+//   - e: метрика
+func (r *SQLiteRepository) Update(ctx context.Context, e entity.Metrics) (float64, int64, error) {
+	cols, err := encodeJSONColumns(e)
+	if err != nil {
+		r.log.Error("Error encoding metric", err)
+		return 0, 0, errors.New("update error")
+	}
+
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE metrics SET mtype = ?, value = ?, delta = ?, histogram = ?, sources = ?, updated_at = ? WHERE id = ?",
+		e.MType, e.Value, e.Delta, cols.histogram, cols.sources, updatedAtOrNow(e.UpdatedAt).UnixNano(), e.Key())
+	if err != nil {
+		r.log.Error("Error during update execution", err)
+		return 0, 0, errors.New("update error")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return 0, 0, errors.New(repository.ErrorMetricNotFound)
+	}
+
+	r.log.Debug(
+		"Updating metric data in SQLiteRepository",
+		"id", e.Key(),
+		"type", e.MType,
+		"value", e.Value,
+		"delta", e.Delta,
+	)
+	value := float64(0)
+	if e.Value != nil {
+		value = *e.Value
+	}
+	delta := int64(0)
+	if e.Delta != nil {
+		delta = *e.Delta
+	}
+	return value, delta, nil
+}
+
+// Remove удаляет метрику или возвращает ошибку.
+//
+// Параметры:
+//   - e: метрика
+func (r *SQLiteRepository) Remove(ctx context.Context, e entity.Metrics) (string, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM metrics WHERE id = ?", e.Key())
+	if err != nil {
+		r.log.Error("Error during delete execution", err)
+		return "", errors.New("delete error")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return "", errors.New(repository.ErrorMetricNotFound)
+	}
+	// история метрики удаляется вместе с ней
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM metrics_history WHERE id = ?", e.Key()); err != nil {
+		r.log.Error("Error during history delete execution", err)
+		return "", errors.New("delete error")
+	}
+
+	r.log.Debug(
+		"Deleting a metric in SQLiteRepository",
+		"id", e.Key(),
+	)
+	return e.ID, nil
+}
+
+// InTransaction выполняет fn в одной транзакции базы данных.
+// Транзакция фиксируется, если fn вернула nil, и откатывается в противном случае.
+//
+// Параметры:
+//   - fn: функция, выполняющая изменения через переданный ей репозиторий
+func (r *SQLiteRepository) InTransaction(
+	ctx context.Context,
+	fn func(ctx context.Context, tx repository.Repository) error,
+) error {
+	if r.inTx {
+		return fn(ctx, r)
+	}
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		r.log.Error("Error during transaction begin", err)
+		return errors.New("update error")
+	}
+	defer func() {
+		// после Commit откат ничего не делает
+		_ = tx.Rollback()
+	}()
+
+	txRepo := &SQLiteRepository{
+		log:    r.log,
+		conn:   r.conn,
+		db:     tx,
+		dbConn: r.dbConn,
+		inTx:   true,
+	}
+	if err := fn(ctx, txRepo); err != nil {
+		r.log.Debug("Rollback transaction in SQLiteRepository", "error", err.Error())
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.log.Error("Error during transaction commit", err)
+		return errors.New("update error")
+	}
+	return nil
+}
+
+// AddPoint сохраняет значение метрики в историю.
+//
+// Параметры:
+//   - id: идентификатор метрики
+//   - p: значение метрики с временной меткой
+func (r *SQLiteRepository) AddPoint(ctx context.Context, id string, p entity.MetricPoint) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO metrics_history (id, value, created_at) VALUES (?, ?, ?)", id, p.Value, p.Time.UnixNano())
+	if err != nil {
+		r.log.Error("Error during history insert execution", err)
+		return errors.New("insert error")
+	}
+
+	r.log.Debug(
+		"Adding metric point to history in SQLiteRepository",
+		"id", id,
+		"time", p.Time,
+		"value", p.Value,
+	)
+	return nil
+}
+
+// GetHistory возвращает историю значений метрики в интервале [from, to].
+//
+// Параметры:
+//   - id: идентификатор метрики
+//   - from: начало интервала
+//   - to: конец интервала
+func (r *SQLiteRepository) GetHistory(
+	ctx context.Context,
+	id string,
+	from time.Time,
+	to time.Time,
+) ([]entity.MetricPoint, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT created_at, value FROM metrics_history
+		WHERE id = ? AND created_at BETWEEN ? AND ?
+		ORDER BY created_at`, id, from.UnixNano(), to.UnixNano())
+	if err != nil {
+		r.log.Error("Error during query execution", err)
+		return nil, ErrQueryRun
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	points := make([]entity.MetricPoint, 0)
+	for rows.Next() {
+		var (
+			p  entity.MetricPoint
+			at int64
+		)
+		if err := rows.Scan(&at, &p.Value); err != nil {
+			r.log.Error("Error scanning row", err)
+			return nil, ErrScanData
+		}
+		p.Time = time.Unix(0, at).UTC()
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.Error("Error reading rows", err)
+		return nil, ErrQueryRun
+	}
+
+	r.log.Debug(
+		"Query metric history from SQLiteRepository",
+		"id", id,
+		"count", len(points),
+	)
+	return points, nil
+}
+
+// Close закрывает подключения к базе данных.
+func (r *SQLiteRepository) Close() {
+	if err := r.conn.Close(); err != nil {
+		r.log.Error("Error closing database", err)
+	}
+}
+
+// scanner - общий интерфейс *sql.Row и *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanMetric читает метрику из строки результата запроса.
+func scanMetric(row scanner) (entity.Metrics, error) {
+	var (
+		m         entity.Metrics
+		labels    string
+		histogram sql.NullString
+		sources   sql.NullString
+		updatedAt int64
+	)
+	if err := row.Scan(&m.ID, &m.MType, &m.Value, &m.Delta, &labels, &histogram, &sources, &updatedAt); err != nil {
+		return entity.Metrics{}, fmt.Errorf("scan metric error: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(labels), &m.Labels); err != nil {
+		return entity.Metrics{}, fmt.Errorf("decode labels error: %w", err)
+	}
+	if len(m.Labels) == 0 {
+		m.Labels = nil
+	}
+	if histogram.Valid {
+		m.Histogram = &entity.HistogramData{}
+		if err := json.Unmarshal([]byte(histogram.String), m.Histogram); err != nil {
+			return entity.Metrics{}, fmt.Errorf("decode histogram error: %w", err)
+		}
+	}
+	if sources.Valid {
+		if err := json.Unmarshal([]byte(sources.String), &m.Sources); err != nil {
+			return entity.Metrics{}, fmt.Errorf("decode sources error: %w", err)
+		}
+	}
+	m.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	return m, nil
+}
+
+// jsonColumns - метки, гистограмма и разбивка по источникам метрики, закодированные в JSON.
+type jsonColumns struct {
+	labels    string         // метки метрики
+	histogram sql.NullString // гистограмма (NULL, если её нет)
+	sources   sql.NullString // разбивка counter по источникам (NULL, если её нет)
+}
+
+// encodeJSONColumns кодирует метки, гистограмму и разбивку по источникам метрики
+// для хранения в текстовых колонках. Отсутствующие гистограмма и разбивка кодируются в NULL.
+func encodeJSONColumns(e entity.Metrics) (jsonColumns, error) {
+	labels := e.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	l, err := json.Marshal(labels)
+	if err != nil {
+		return jsonColumns{}, fmt.Errorf("encode labels error: %w", err)
+	}
+	cols := jsonColumns{labels: string(l)}
+
+	if e.Histogram != nil {
+		h, err := json.Marshal(e.Histogram)
+		if err != nil {
+			return jsonColumns{}, fmt.Errorf("encode histogram error: %w", err)
+		}
+		cols.histogram = sql.NullString{String: string(h), Valid: true}
+	}
+	if e.Sources != nil {
+		src, err := json.Marshal(e.Sources)
+		if err != nil {
+			return jsonColumns{}, fmt.Errorf("encode sources error: %w", err)
+		}
+		cols.sources = sql.NullString{String: string(src), Valid: true}
+	}
+	return cols, nil
+}
+
+// updatedAtOrNow заменяет отсутствующее время изменения метрики текущим,
+// так как колонка updated_at не допускает NULL.
+func updatedAtOrNow(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now().UTC()
+	}
+	return t
+}
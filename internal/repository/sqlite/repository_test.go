@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/repository"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRepository создаёт репозиторий в файле во временном каталоге теста.
+func newTestRepository(t *testing.T) (*SQLiteRepository, string) {
+	t.Helper()
+	dsn := Scheme + "://" + filepath.Join(t.TempDir(), "metrics.db")
+	repo, err := New(dsn, &testutil.MockLogger{})
+	require.NoError(t, err)
+	t.Cleanup(repo.Close)
+	return repo, dsn
+}
+
+func TestNew(t *testing.T) {
+	repo, dsn := newTestRepository(t)
+	ctx := context.Background()
+
+	var mode string
+	require.NoError(t, repo.conn.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "wal", mode)
+
+	var versions int
+	require.NoError(t, repo.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations").Scan(&versions))
+	ms, err := loadMigrations(migrationFiles, migrationsDir)
+	require.NoError(t, err)
+	assert.Equal(t, len(ms), versions)
+
+	// повторное открытие не применяет миграции заново
+	_, err = repo.Create(ctx, entity.Metrics{ID: "metric1", MType: entity.Gauge, Value: floatPtr(1)})
+	require.NoError(t, err)
+	repo.Close()
+
+	reopened, err := New(dsn, &testutil.MockLogger{})
+	require.NoError(t, err)
+	defer reopened.Close()
+	all, err := reopened.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+
+	for _, dsn := range []string{"", "sqlite://", "postgres://localhost/db"} {
+		_, err := New(dsn, &testutil.MockLogger{})
+		assert.ErrorIs(t, err, ErrConnectionStart, dsn)
+	}
+}
+
+func TestCRUD(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	_, err := repo.GetByID(ctx, "metric1")
+	assert.EqualError(t, err, repository.ErrorMetricNotFound)
+
+	labeled := entity.Metrics{ID: "metric1", MType: entity.Gauge, Value: floatPtr(1.5),
+		Labels: map[string]string{"host": "a"}, UpdatedAt: at}
+	_, err = repo.Create(ctx, labeled)
+	require.NoError(t, err)
+	_, err = repo.Create(ctx, entity.Metrics{ID: "metric1", MType: entity.Counter, Delta: intPtr(10), UpdatedAt: at})
+	require.NoError(t, err)
+
+	got, err := repo.GetByID(ctx, labeled.Key())
+	require.NoError(t, err)
+	assert.Equal(t, labeled, got)
+
+	value, delta, err := repo.Update(ctx,
+		entity.Metrics{ID: "metric1", MType: entity.Counter, Delta: intPtr(15), UpdatedAt: at.Add(time.Minute)})
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, value)
+	assert.Equal(t, int64(15), delta)
+
+	got, err = repo.GetByID(ctx, "metric1")
+	require.NoError(t, err)
+	assert.Equal(t,
+		entity.Metrics{ID: "metric1", MType: entity.Counter, Delta: intPtr(15), UpdatedAt: at.Add(time.Minute)}, got)
+
+	_, _, err = repo.Update(ctx, entity.Metrics{ID: "unknown", MType: entity.Gauge, Value: floatPtr(1)})
+	assert.EqualError(t, err, repository.ErrorMetricNotFound)
+
+	_, err = repo.Remove(ctx, labeled)
+	require.NoError(t, err)
+	_, err = repo.Remove(ctx, labeled)
+	assert.EqualError(t, err, repository.ErrorMetricNotFound)
+
+	all, err := repo.GetAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, "metric1", all[0].Key())
+}
+
+func TestHistogram(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	h := entity.NewHistogram([]float64{1, 5})
+	h.Observe(0.5)
+	h.Observe(3)
+	_, err := repo.Create(ctx, entity.Metrics{ID: "latency", MType: entity.Histogram, Histogram: h})
+	require.NoError(t, err)
+
+	got, err := repo.GetByID(ctx, "latency")
+	require.NoError(t, err)
+	assert.Equal(t, h, got.Histogram)
+	assert.Nil(t, got.Value)
+	assert.Nil(t, got.Delta)
+}
+
+func TestSources(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	delta := int64(3)
+	_, err := repo.Create(ctx, entity.Metrics{
+		ID: "PollCount", MType: entity.Counter, Delta: &delta, Sources: map[string]int64{"agent-1": 3},
+	})
+	require.NoError(t, err)
+
+	delta = 5
+	_, _, err = repo.Update(ctx, entity.Metrics{
+		ID: "PollCount", MType: entity.Counter, Delta: &delta, Sources: map[string]int64{"agent-1": 3, "agent-2": 2},
+	})
+	require.NoError(t, err)
+
+	got, err := repo.GetByID(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"agent-1": 3, "agent-2": 2}, got.Sources)
+}
+
+func TestInTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("commit", func(t *testing.T) {
+		repo, _ := newTestRepository(t)
+		err := repo.InTransaction(ctx, func(ctx context.Context, tx repository.Repository) error {
+			_, err := tx.Create(ctx, entity.Metrics{ID: "metric1", MType: entity.Counter, Delta: intPtr(1)})
+			if err != nil {
+				return err
+			}
+			_, _, err = tx.Update(ctx, entity.Metrics{ID: "metric1", MType: entity.Counter, Delta: intPtr(3)})
+			return err
+		})
+		require.NoError(t, err)
+
+		result, err := repo.GetByID(ctx, "metric1")
+		require.NoError(t, err)
+		assert.Equal(t, intPtr(3), result.Delta)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		repo, _ := newTestRepository(t)
+		_, err := repo.Create(ctx, entity.Metrics{ID: "metric1", MType: entity.Gauge, Value: floatPtr(1)})
+		require.NoError(t, err)
+
+		err = repo.InTransaction(ctx, func(ctx context.Context, tx repository.Repository) error {
+			_, _, uerr := tx.Update(ctx, entity.Metrics{ID: "metric1", MType: entity.Gauge, Value: floatPtr(2)})
+			require.NoError(t, uerr)
+			_, cerr := tx.Create(ctx, entity.Metrics{ID: "metric2", MType: entity.Gauge, Value: floatPtr(3)})
+			require.NoError(t, cerr)
+			_, _, uerr = tx.Update(ctx, entity.Metrics{ID: "unknown", MType: entity.Gauge, Value: floatPtr(4)})
+			return uerr
+		})
+		require.Error(t, err)
+
+		all, err := repo.GetAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+		assert.Equal(t, floatPtr(1), all[0].Value)
+	})
+}
+
+func TestHistory(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		p := entity.MetricPoint{Time: start.Add(time.Duration(i) * time.Minute), Value: float64(i)}
+		require.NoError(t, repo.AddPoint(ctx, "metric1", p))
+	}
+	require.NoError(t, repo.AddPoint(ctx, "metric2", entity.MetricPoint{Time: start, Value: 100}))
+
+	points, err := repo.GetHistory(ctx, "metric1", start.Add(time.Minute), start.Add(3*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, points, 3)
+	assert.Equal(t, entity.MetricPoint{Time: start.Add(time.Minute), Value: 1}, points[0])
+	assert.Equal(t, 3.0, points[2].Value)
+
+	points, err = repo.GetHistory(ctx, "unknown", start, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, points)
+
+	_, err = repo.Create(ctx, entity.Metrics{ID: "metric1", MType: entity.Gauge, Value: floatPtr(1)})
+	require.NoError(t, err)
+	_, err = repo.Remove(ctx, entity.Metrics{ID: "metric1", MType: entity.Gauge})
+	require.NoError(t, err)
+	points, err = repo.GetHistory(ctx, "metric1", start, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, points, "history must be removed with the metric")
+}
+
+func TestLoadMigrations(t *testing.T) {
+	file := &fstest.MapFile{Data: []byte("SELECT 1;")}
+
+	ms, err := loadMigrations(fstest.MapFS{
+		"m/0002_second.sql": file,
+		"m/0001_first.sql":  file,
+	}, "m")
+	require.NoError(t, err)
+	require.Len(t, ms, 2)
+	assert.Equal(t, "first", ms[0].name)
+	assert.Equal(t, int64(2), ms[1].version)
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"duplicate version": {"m/0001_first.sql": file, "m/0001_other.sql": file},
+		"unexpected file":   {"m/first.sql": file},
+		"zero version":      {"m/0000_first.sql": file},
+	} {
+		_, err := loadMigrations(fsys, "m")
+		assert.ErrorIs(t, err, ErrInvalidMigrations, name)
+	}
+}
+
+func floatPtr(f float64) *float64 { return &f }
+func intPtr(i int64) *int64       { return &i }
@@ -0,0 +1,259 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/service"
+)
+
+// State - состояние правила.
+type State string
+
+// Константы - состояния правила.
+const (
+	StateInactive State = "inactive" // условие не выполняется
+	StatePending  State = "pending"  // условие выполняется меньше заданного времени
+	StateFiring   State = "firing"   // условие выполняется заданное время, оповещение отправлено
+	StateResolved State = "resolved" // условие перестало выполняться после срабатывания
+)
+
+// Source описывает источник значений метрик (service.Service).
+type Source interface {
+	Get(ctx context.Context, id string, t string) (entity.Metrics, error)
+}
+
+// sample - значение метрики в момент вычисления правила.
+type sample struct {
+	at    time.Time // время вычисления
+	value float64   // значение
+}
+
+// ruleState - состояние вычисления одного правила.
+type ruleState struct {
+	since   time.Time // начало выполнения условия (для pending и firing)
+	value   *float64  // значение, по которому правило вычислено последний раз
+	samples []sample  // значения в пределах окна (для rate)
+	key     string    // идентификатор метрики с учётом меток
+	rule    Rule      // правило
+	state   State     // текущее состояние
+}
+
+// Engine периодически вычисляет правила оповещений и отправляет оповещения
+// о срабатывании (firing) и восстановлении (resolved) в приёмники.
+type Engine struct {
+	source   Source           // источник значений метрик
+	log      logger.Logger    // логгер
+	now      func() time.Time // источник текущего времени
+	rules    []*ruleState     // правила и их состояния
+	sinks    []Sink           // приёмники оповещений
+	interval time.Duration    // интервал вычисления правил
+}
+
+// New создаёт и инициализирует новый экзепляр *Engine.
+//
+// Параметры:
+//   - conf: проверенный файл правил (LoadConfig)
+//   - source: источник значений метрик
+//   - sinks: приёмники оповещений
+//   - log: логгер
+func New(conf *Config, source Source, sinks []Sink, log logger.Logger) *Engine {
+	rules := make([]*ruleState, 0, len(conf.Rules))
+	for _, r := range conf.Rules {
+		rules = append(rules, &ruleState{
+			rule:  r,
+			key:   (&entity.Metrics{ID: r.Metric, Labels: r.Labels}).Key(),
+			state: StateInactive,
+		})
+	}
+
+	return &Engine{
+		source:   source,
+		log:      log,
+		now:      time.Now,
+		rules:    rules,
+		sinks:    sinks,
+		interval: time.Duration(conf.Interval),
+	}
+}
+
+// Run вычисляет правила с заданным интервалом до отмены ctx.
+func (e *Engine) Run(ctx context.Context) {
+	e.log.Info("Alerting started", "rules", len(e.rules), "sinks", len(e.sinks), "interval", e.interval)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		e.evaluate(ctx)
+		select {
+		case <-ctx.Done():
+			e.log.Info("Alerting stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// evaluate вычисляет все правила и отправляет оповещения о смене их состояния.
+func (e *Engine) evaluate(ctx context.Context) {
+	now := e.now()
+	for _, rs := range e.rules {
+		active, err := e.check(ctx, rs, now)
+		if err != nil {
+			e.log.Warn("Evaluate alert rule error", err, "rule", rs.rule.Name)
+			continue
+		}
+		if changed := rs.transition(active, now); changed {
+			e.notify(ctx, rs, now)
+		}
+	}
+}
+
+// check вычисляет условие правила.
+func (e *Engine) check(ctx context.Context, rs *ruleState, now time.Time) (bool, error) {
+	m, err := e.source.Get(ctx, rs.key, rs.rule.MetricType)
+	notFound := err != nil && err.Error() == service.MetricNotFound
+	if err != nil && !notFound {
+		return false, fmt.Errorf("get metric error: %w", err)
+	}
+
+	rs.value = nil
+	if !notFound {
+		v, ok := metricValue(m)
+		if !ok {
+			return false, errors.New("metric has no value")
+		}
+		rs.value = &v
+	}
+
+	switch rs.rule.Kind {
+	case KindAbsence:
+		return notFound, nil
+	case KindThreshold:
+		if rs.value == nil {
+			return false, nil
+		}
+		active, _ := compare(rs.rule.Op, *rs.value, rs.rule.Threshold)
+		return active, nil
+	case KindRate:
+		if rs.value == nil {
+			rs.samples = nil
+			return false, nil
+		}
+		rate, ok := rs.rate(now, *rs.value)
+		if !ok {
+			return false, nil
+		}
+		active, _ := compare(rs.rule.Op, rate, rs.rule.Threshold)
+		return active, nil
+	default:
+		return false, fmt.Errorf("unknown rule kind %q", rs.rule.Kind)
+	}
+}
+
+// rate добавляет значение в окно правила и возвращает скорость изменения значения в секунду.
+// Возвращает false, пока история значений короче окна.
+func (rs *ruleState) rate(now time.Time, value float64) (float64, bool) {
+	rs.samples = append(rs.samples, sample{at: now, value: value})
+
+	// в окне остаётся одно значение не моложе начала окна - от него считается скорость
+	start := now.Add(-time.Duration(rs.rule.Window))
+	for len(rs.samples) > 1 && !rs.samples[1].at.After(start) {
+		rs.samples = rs.samples[1:]
+	}
+
+	first := rs.samples[0]
+	if first.at.After(start) {
+		return 0, false
+	}
+	return (value - first.value) / now.Sub(first.at).Seconds(), true
+}
+
+// transition переводит правило в следующее состояние. Возвращает true, если нужно отправить оповещение.
+//
+// Переходы: inactive -> pending -> firing -> resolved -> inactive; pending -> inactive, если условие
+// перестало выполняться раньше заданного времени; resolved -> pending, если условие снова выполняется.
+func (rs *ruleState) transition(active bool, now time.Time) bool {
+	if !active {
+		switch rs.state {
+		case StateFiring:
+			rs.state = StateResolved
+			return true
+		case StatePending, StateResolved:
+			rs.state = StateInactive
+		case StateInactive:
+		}
+		return false
+	}
+
+	if rs.state != StatePending && rs.state != StateFiring {
+		rs.state = StatePending
+		rs.since = now
+	}
+	if rs.state == StatePending && now.Sub(rs.since) >= time.Duration(rs.rule.For) {
+		rs.state = StateFiring
+		return true
+	}
+	return false
+}
+
+// notify отправляет оповещение о состоянии правила во все приёмники.
+func (e *Engine) notify(ctx context.Context, rs *ruleState, now time.Time) {
+	n := Notification{
+		Labels:  rs.rule.Labels,
+		Value:   rs.value,
+		Since:   rs.since,
+		At:      now,
+		Rule:    rs.rule.Name,
+		Kind:    rs.rule.Kind,
+		Metric:  rs.rule.Metric,
+		State:   rs.state,
+		Summary: rs.summary(),
+	}
+
+	for _, s := range e.sinks {
+		if err := s.Notify(ctx, n); err != nil {
+			e.log.Warn("Deliver alert error", err, "rule", n.Rule, "sink", s.Name())
+		}
+	}
+}
+
+// summary возвращает краткое описание правила и его значения.
+func (rs *ruleState) summary() string {
+	r := rs.rule
+	var cond string
+	switch r.Kind {
+	case KindAbsence:
+		cond = rs.key + " is absent"
+	case KindRate:
+		cond = fmt.Sprintf("rate(%s[%s]) %s %g", rs.key, time.Duration(r.Window), r.Op, r.Threshold)
+	default:
+		cond = fmt.Sprintf("%s %s %g", rs.key, r.Op, r.Threshold)
+	}
+	if r.For > 0 {
+		cond += fmt.Sprintf(" for %s", time.Duration(r.For))
+	}
+	if rs.value != nil {
+		cond += fmt.Sprintf(" (value %g)", *rs.value)
+	}
+	return fmt.Sprintf("%s: %s", rs.state, cond)
+}
+
+// metricValue возвращает числовое значение метрики: gauge - значение, counter - накопленное значение,
+// histogram - количество наблюдений.
+func metricValue(m entity.Metrics) (float64, bool) {
+	switch {
+	case m.MType == entity.Gauge && m.Value != nil:
+		return *m.Value, true
+	case m.MType == entity.Counter && m.Delta != nil:
+		return float64(*m.Delta), true
+	case m.MType == entity.Histogram && m.Histogram != nil:
+		return float64(m.Histogram.Count), true
+	default:
+		return 0, false
+	}
+}
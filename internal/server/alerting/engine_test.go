@@ -0,0 +1,198 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/service"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockSource - источник значений метрик для тестов.
+type mockSource map[string]entity.Metrics
+
+func (s mockSource) Get(ctx context.Context, id string, t string) (entity.Metrics, error) {
+	m, ok := s[id]
+	if !ok {
+		return entity.Metrics{}, errors.New(service.MetricNotFound)
+	}
+	if m.MType != t {
+		return entity.Metrics{}, errors.New(service.MetricUncorrect)
+	}
+	return m, nil
+}
+
+func (s mockSource) setGauge(id string, v float64) {
+	s[id] = entity.Metrics{ID: id, MType: entity.Gauge, Value: &v}
+}
+
+func (s mockSource) setCounter(id string, d int64) {
+	s[id] = entity.Metrics{ID: id, MType: entity.Counter, Delta: &d}
+}
+
+// recordSink - приёмник оповещений для тестов.
+type recordSink struct {
+	notifications []Notification
+}
+
+func (s *recordSink) Name() string { return "record" }
+
+func (s *recordSink) Notify(ctx context.Context, n Notification) error {
+	s.notifications = append(s.notifications, n)
+	return nil
+}
+
+// newTestEngine создаёт движок с ручным управлением временем.
+func newTestEngine(rules []Rule, source Source) (*Engine, *recordSink, *time.Time) {
+	sink := &recordSink{}
+	engine := New(&Config{Rules: rules, Interval: Duration(time.Second)}, source, []Sink{sink}, &testutil.MockLogger{})
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+	return engine, sink, &now
+}
+
+func states(ns []Notification) []State {
+	res := make([]State, 0, len(ns))
+	for _, n := range ns {
+		res = append(res, n.State)
+	}
+	return res
+}
+
+func TestEngine_Threshold(t *testing.T) {
+	source := mockSource{}
+	engine, sink, now := newTestEngine([]Rule{{
+		Name:       "HighCPU",
+		Kind:       KindThreshold,
+		Metric:     "CPUutilization1",
+		MetricType: entity.Gauge,
+		Op:         ">",
+		Threshold:  90,
+		For:        Duration(5 * time.Minute),
+	}}, source)
+	rs := engine.rules[0]
+	ctx := context.Background()
+
+	steps := []struct {
+		value    float64
+		advance  time.Duration
+		expected State
+	}{
+		{value: 50, expected: StateInactive},
+		{value: 95, advance: time.Minute, expected: StatePending},
+		{value: 50, advance: time.Minute, expected: StateInactive}, // кратковременный всплеск не срабатывает
+		{value: 95, advance: time.Minute, expected: StatePending},
+		{value: 97, advance: 4 * time.Minute, expected: StatePending},
+		{value: 99, advance: time.Minute, expected: StateFiring},
+		{value: 99, advance: time.Minute, expected: StateFiring},
+		{value: 10, advance: time.Minute, expected: StateResolved},
+		{value: 10, advance: time.Minute, expected: StateInactive},
+	}
+	for i, step := range steps {
+		*now = now.Add(step.advance)
+		source.setGauge("CPUutilization1", step.value)
+		engine.evaluate(ctx)
+		require.Equal(t, step.expected, rs.state, "step %d", i)
+	}
+
+	require.Equal(t, []State{StateFiring, StateResolved}, states(sink.notifications))
+	firing := sink.notifications[0]
+	assert.Equal(t, "HighCPU", firing.Rule)
+	assert.Equal(t, 99.0, *firing.Value)
+	assert.Equal(t, 5*time.Minute, firing.At.Sub(firing.Since))
+	assert.Equal(t, "firing: CPUutilization1 > 90 for 5m0s (value 99)", firing.Summary)
+}
+
+func TestEngine_Absence(t *testing.T) {
+	source := mockSource{}
+	engine, sink, now := newTestEngine([]Rule{{
+		Name:       "NoAlloc",
+		Kind:       KindAbsence,
+		Metric:     "Alloc",
+		MetricType: entity.Gauge,
+	}}, source)
+	ctx := context.Background()
+
+	engine.evaluate(ctx)
+	require.Equal(t, []State{StateFiring}, states(sink.notifications))
+	assert.Nil(t, sink.notifications[0].Value)
+
+	*now = now.Add(time.Minute)
+	source.setGauge("Alloc", 1)
+	engine.evaluate(ctx)
+	require.Equal(t, []State{StateFiring, StateResolved}, states(sink.notifications))
+}
+
+func TestEngine_RateStopsIncreasing(t *testing.T) {
+	source := mockSource{}
+	engine, sink, now := newTestEngine([]Rule{{
+		Name:       "PollCountStalled",
+		Kind:       KindRate,
+		Metric:     "PollCount",
+		MetricType: entity.Counter,
+		Op:         "<=",
+		Threshold:  0,
+		Window:     Duration(time.Minute),
+	}}, source)
+	rs := engine.rules[0]
+	ctx := context.Background()
+
+	// счётчик растёт, затем перестаёт расти
+	for i, count := range []int64{0, 10, 20, 30, 30, 30, 30, 40} {
+		*now = now.Add(30 * time.Second)
+		source.setCounter("PollCount", count)
+		engine.evaluate(ctx)
+
+		// скорость считается за последнюю минуту: нулевой она становится на шаге 5
+		expected := StateInactive
+		switch i {
+		case 5, 6:
+			expected = StateFiring
+		case 7:
+			expected = StateResolved
+		}
+		require.Equal(t, expected, rs.state, "step %d", i)
+	}
+	require.Equal(t, []State{StateFiring, StateResolved}, states(sink.notifications))
+}
+
+func TestEngine_SourceError(t *testing.T) {
+	source := mockSource{}
+	source.setCounter("Alloc", 1)
+	engine, sink, _ := newTestEngine([]Rule{{
+		Name:       "WrongType",
+		Kind:       KindThreshold,
+		Metric:     "Alloc",
+		MetricType: entity.Gauge,
+		Op:         ">",
+	}}, source)
+
+	engine.evaluate(context.Background())
+	assert.Equal(t, StateInactive, engine.rules[0].state)
+	assert.Empty(t, sink.notifications)
+}
+
+func TestEngine_Labels(t *testing.T) {
+	labels := map[string]string{"core": "0"}
+	key := (&entity.Metrics{ID: "CPUutilization", Labels: labels}).Key()
+	v := 100.0
+	source := mockSource{key: {ID: "CPUutilization", MType: entity.Gauge, Value: &v, Labels: labels}}
+	engine, sink, _ := newTestEngine([]Rule{{
+		Name:       "Core0",
+		Kind:       KindThreshold,
+		Metric:     "CPUutilization",
+		MetricType: entity.Gauge,
+		Labels:     labels,
+		Op:         ">=",
+		Threshold:  100,
+	}}, source)
+
+	engine.evaluate(context.Background())
+	require.Len(t, sink.notifications, 1)
+	assert.Equal(t, labels, sink.notifications[0].Labels)
+}
@@ -0,0 +1,168 @@
+// Пакет alerting предоставляет подсистему оповещений: правила над значениями метрик,
+// их периодическое вычисление и доставку оповещений в приёмники.
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Константы - виды правил.
+const (
+	KindThreshold = "threshold" // значение метрики сравнивается с порогом
+	KindAbsence   = "absence"   // метрика отсутствует на сервере
+	KindRate      = "rate"      // скорость изменения значения метрики (в секунду) сравнивается с порогом
+)
+
+// Константы - значения по умолчанию.
+const (
+	defaultInterval = 15 * time.Second // интервал вычисления правил
+	defaultWindow   = time.Minute      // окно расчёта скорости изменения
+)
+
+// ErrInvalidRules - ошибка, файл правил некорректен.
+var ErrInvalidRules = errors.New("invalid alert rules")
+
+// Duration - длительность, задаваемая в файле правил строкой в формате time.ParseDuration (например, 5m).
+type Duration time.Duration
+
+// UnmarshalJSON разбирает длительность из строки JSON.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("parse duration error: %w", err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Config описывает файл правил оповещений.
+type Config struct {
+	Sinks    []SinkConfig `json:"sinks"`    // приёмники оповещений (по умолчанию только лог)
+	Rules    []Rule       `json:"rules"`    // правила
+	Interval Duration     `json:"interval"` // интервал вычисления правил (по умолчанию 15s)
+}
+
+// Rule описывает правило оповещения над одной метрикой (с учётом меток).
+type Rule struct {
+	Labels     map[string]string `json:"labels"`      // метки метрики
+	Name       string            `json:"name"`        // уникальное имя правила
+	Kind       string            `json:"kind"`        // вид правила: threshold, absence или rate
+	Metric     string            `json:"metric"`      // имя метрики
+	MetricType string            `json:"metric_type"` // тип метрики
+	Op         string            `json:"op"`          // оператор сравнения: >, >=, <, <=, == или != (кроме absence)
+	Threshold  float64           `json:"threshold"`   // порог (кроме absence)
+	Window     Duration          `json:"window"`      // окно расчёта скорости изменения (rate, по умолчанию 1m)
+	For        Duration          `json:"for"`         // сколько условие должно выполняться до срабатывания
+}
+
+// LoadConfig загружает и проверяет файл правил оповещений.
+//
+// Параметры:
+//   - path: путь до файла правил
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read alert rules error: %w", err)
+	}
+
+	var conf Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&conf); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRules, err)
+	}
+
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// validate проверяет правила и заполняет значения по умолчанию.
+func (c *Config) validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("%w: negative interval", ErrInvalidRules)
+	}
+	if c.Interval == 0 {
+		c.Interval = Duration(defaultInterval)
+	}
+
+	names := make(map[string]struct{}, len(c.Rules))
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("%w: rule %d has no name", ErrInvalidRules, i)
+		}
+		if _, ok := names[r.Name]; ok {
+			return fmt.Errorf("%w: duplicate rule %q", ErrInvalidRules, r.Name)
+		}
+		names[r.Name] = struct{}{}
+
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("%w: rule %q: %w", ErrInvalidRules, r.Name, err)
+		}
+	}
+
+	for i, s := range c.Sinks {
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("%w: sink %d: %w", ErrInvalidRules, i, err)
+		}
+	}
+	return nil
+}
+
+// validate проверяет правило и заполняет значения по умолчанию.
+func (r *Rule) validate() error {
+	if r.Metric == "" || r.MetricType == "" {
+		return errors.New("metric and metric_type are required")
+	}
+	if r.For < 0 || r.Window < 0 {
+		return errors.New("negative duration")
+	}
+
+	switch r.Kind {
+	case KindAbsence:
+		return nil
+	case KindThreshold:
+	case KindRate:
+		if r.Window == 0 {
+			r.Window = Duration(defaultWindow)
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", r.Kind)
+	}
+
+	if _, ok := compare(r.Op, 0, 0); !ok {
+		return fmt.Errorf("unknown op %q", r.Op)
+	}
+	return nil
+}
+
+// compare сравнивает значение с порогом. Возвращает false вторым значением, если оператор неизвестен.
+func compare(op string, value float64, threshold float64) (bool, bool) {
+	switch op {
+	case ">":
+		return value > threshold, true
+	case ">=":
+		return value >= threshold, true
+	case "<":
+		return value < threshold, true
+	case "<=":
+		return value <= threshold, true
+	case "==":
+		return value == threshold, true
+	case "!=":
+		return value != threshold, true
+	default:
+		return false, false
+	}
+}
@@ -0,0 +1,79 @@
+package alerting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRules(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeRules(t, `{
+		"interval": "30s",
+		"sinks": [{"type": "log"}, {"type": "webhook", "url": "http://localhost:9000/alerts"}],
+		"rules": [
+			{"name": "HighCPU", "kind": "threshold", "metric": "CPUutilization1", "metric_type": "gauge",
+			 "op": ">", "threshold": 90, "for": "5m"},
+			{"name": "PollCountStalled", "kind": "rate", "metric": "PollCount", "metric_type": "counter",
+			 "op": "<=", "threshold": 0},
+			{"name": "NoAlloc", "kind": "absence", "metric": "Alloc", "metric_type": "gauge",
+			 "labels": {"host": "a"}}
+		]
+	}`)
+
+	conf, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, Duration(30*time.Second), conf.Interval)
+	require.Len(t, conf.Rules, 3)
+	assert.Equal(t, Duration(5*time.Minute), conf.Rules[0].For)
+	assert.Equal(t, Duration(defaultWindow), conf.Rules[1].Window)
+	assert.Equal(t, map[string]string{"host": "a"}, conf.Rules[2].Labels)
+	require.Len(t, conf.Sinks, 2)
+	assert.Equal(t, SinkWebhook, conf.Sinks[1].Type)
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	conf, err := LoadConfig(writeRules(t, `{"rules": []}`))
+	require.NoError(t, err)
+	assert.Equal(t, Duration(defaultInterval), conf.Interval)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "unknown field", content: `{"rulez": []}`},
+		{name: "bad duration", content: `{"interval": "soon"}`},
+		{name: "no name", content: `{"rules": [{"kind": "absence", "metric": "A", "metric_type": "gauge"}]}`},
+		{name: "duplicate name", content: `{"rules": [
+			{"name": "a", "kind": "absence", "metric": "A", "metric_type": "gauge"},
+			{"name": "a", "kind": "absence", "metric": "B", "metric_type": "gauge"}]}`},
+		{name: "unknown kind", content: `{"rules": [{"name": "a", "kind": "x", "metric": "A", "metric_type": "gauge"}]}`},
+		{name: "unknown op", content: `{"rules": [
+			{"name": "a", "kind": "threshold", "metric": "A", "metric_type": "gauge", "op": "=>"}]}`},
+		{name: "no metric", content: `{"rules": [{"name": "a", "kind": "absence"}]}`},
+		{name: "unknown sink", content: `{"sinks": [{"type": "email"}]}`},
+		{name: "bad webhook url", content: `{"sinks": [{"type": "webhook", "url": "localhost"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeRules(t, tt.content))
+			assert.ErrorIs(t, err, ErrInvalidRules)
+		})
+	}
+
+	_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
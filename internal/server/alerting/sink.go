@@ -0,0 +1,187 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/common"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
+)
+
+// Константы - виды приёмников оповещений.
+const (
+	SinkLog     = "log"     // запись оповещений в лог сервера
+	SinkWebhook = "webhook" // отправка оповещений запросом POST в формате JSON
+)
+
+// defaultWebhookTimeout - время ожидания ответа webhook по умолчанию.
+const defaultWebhookTimeout = 5 * time.Second
+
+// Notification описывает оповещение о смене состояния правила.
+type Notification struct {
+	Labels  map[string]string `json:"labels,omitempty"` // метки метрики
+	Value   *float64          `json:"value,omitempty"`  // значение, по которому вычислено правило (нет для absence)
+	Since   time.Time         `json:"since"`            // начало выполнения условия правила
+	At      time.Time         `json:"at"`               // время смены состояния
+	Rule    string            `json:"rule"`             // имя правила
+	Kind    string            `json:"kind"`             // вид правила
+	Metric  string            `json:"metric"`           // имя метрики
+	State   State             `json:"state"`            // новое состояние: firing или resolved
+	Summary string            `json:"summary"`          // краткое описание
+}
+
+// Sink описывает приёмник оповещений.
+type Sink interface {
+	// Name возвращает имя приёмника для логирования.
+	Name() string
+	// Notify доставляет оповещение.
+	Notify(ctx context.Context, n Notification) error
+}
+
+// SinkConfig описывает приёмник оповещений в файле правил.
+type SinkConfig struct {
+	Headers map[string]string `json:"headers"` // дополнительные заголовки запроса (webhook)
+	Type    string            `json:"type"`    // вид приёмника: log или webhook
+	URL     string            `json:"url"`     // адрес webhook
+	Timeout Duration          `json:"timeout"` // время ожидания ответа webhook (по умолчанию 5s)
+}
+
+// validate проверяет описание приёмника.
+func (c SinkConfig) validate() error {
+	switch c.Type {
+	case SinkLog:
+		return nil
+	case SinkWebhook:
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook url %q", c.URL)
+		}
+		if c.Timeout < 0 {
+			return errors.New("negative timeout")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown type %q", c.Type)
+	}
+}
+
+// NewSinks создаёт приёмники оповещений по их описаниям.
+// Если приёмники не описаны, оповещения записываются в лог.
+//
+// Параметры:
+//   - confs: описания приёмников
+//   - log: логгер
+func NewSinks(confs []SinkConfig, log logger.Logger) []Sink {
+	if len(confs) == 0 {
+		return []Sink{NewLogSink(log)}
+	}
+
+	sinks := make([]Sink, 0, len(confs))
+	for _, c := range confs {
+		switch c.Type {
+		case SinkLog:
+			sinks = append(sinks, NewLogSink(log))
+		case SinkWebhook:
+			timeout := time.Duration(c.Timeout)
+			if timeout == 0 {
+				timeout = defaultWebhookTimeout
+			}
+			sinks = append(sinks, NewWebhookSink(c.URL, c.Headers, timeout))
+		}
+	}
+	return sinks
+}
+
+// LogSink записывает оповещения в лог сервера.
+type LogSink struct {
+	log logger.Logger // логгер
+}
+
+// NewLogSink создаёт приёмник, записывающий оповещения в лог.
+//
+// Параметры:
+//   - log: логгер
+func NewLogSink(log logger.Logger) *LogSink {
+	return &LogSink{log: log}
+}
+
+// Name возвращает имя приёмника.
+func (s *LogSink) Name() string {
+	return SinkLog
+}
+
+// Notify записывает оповещение в лог.
+func (s *LogSink) Notify(ctx context.Context, n Notification) error {
+	s.log.Info(
+		"Alert "+string(n.State),
+		"rule", n.Rule,
+		"metric", n.Metric,
+		"labels", n.Labels,
+		"value", n.Value,
+		"since", n.Since,
+		"summary", n.Summary,
+	)
+	return nil
+}
+
+// WebhookSink отправляет оповещения запросом POST с телом в формате JSON.
+type WebhookSink struct {
+	client  *http.Client      // HTTP-клиент
+	headers map[string]string // дополнительные заголовки запроса
+	url     string            // адрес webhook
+}
+
+// NewWebhookSink создаёт приёмник, отправляющий оповещения на webhook.
+//
+// Параметры:
+//   - url: адрес webhook
+//   - headers: дополнительные заголовки запроса
+//   - timeout: время ожидания ответа
+func NewWebhookSink(url string, headers map[string]string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		client:  &http.Client{Timeout: timeout},
+		headers: headers,
+		url:     url,
+	}
+}
+
+// Name возвращает имя приёмника.
+func (s *WebhookSink) Name() string {
+	return SinkWebhook + " " + s.url
+}
+
+// Notify отправляет оповещение. Ответ с кодом не из диапазона 2xx считается ошибкой.
+func (s *WebhookSink) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("marshal notification error: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create webhook request error: %w", err)
+	}
+	req.Header.Set(common.HeaderContentType, common.HeaderContentTypeValueApplicationJSON)
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send webhook request error: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
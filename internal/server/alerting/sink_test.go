@@ -0,0 +1,64 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSink(t *testing.T) {
+	var received Notification
+	var token string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		token = r.Header.Get("Authorization")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	sink := NewWebhookSink(ts.URL, map[string]string{"Authorization": "Bearer token"}, time.Second)
+	value := 95.0
+	err := sink.Notify(context.Background(), Notification{
+		Rule:   "HighCPU",
+		Metric: "CPUutilization1",
+		State:  StateFiring,
+		Value:  &value,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer token", token)
+	assert.Equal(t, "HighCPU", received.Rule)
+	assert.Equal(t, StateFiring, received.State)
+	assert.Equal(t, 95.0, *received.Value)
+}
+
+func TestWebhookSink_ErrorStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	sink := NewWebhookSink(ts.URL, nil, time.Second)
+	err := sink.Notify(context.Background(), Notification{Rule: "HighCPU", State: StateFiring})
+	assert.Error(t, err)
+}
+
+func TestNewSinks(t *testing.T) {
+	log := &testutil.MockLogger{}
+
+	sinks := NewSinks(nil, log)
+	require.Len(t, sinks, 1)
+	assert.Equal(t, SinkLog, sinks[0].Name())
+
+	sinks = NewSinks([]SinkConfig{{Type: SinkLog}, {Type: SinkWebhook, URL: "http://localhost/alerts"}}, log)
+	require.Len(t, sinks, 2)
+	assert.Equal(t, "webhook http://localhost/alerts", sinks[1].Name())
+}
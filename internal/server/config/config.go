@@ -2,6 +2,8 @@
 // Конфигурация включает такие параметры как: адрес сервера, настройки хранилища, путь до хранилища и т.п.
 package config
 
+import "os"
+
 // Костанты - значения по умолчанию.
 const (
 	defaultServerAddress   string = "localhost:8080"          // aдрес сервера
@@ -9,24 +11,42 @@ const (
 	defaultStoreInterval   int64  = 300                       // интервал сохранения данных в хранилище (в секундах)
 	defaultFileStoragePath string = "../../temp_metrics.json" // путь до файла хранилища (относительный)
 	// Флаг, указывающий загружать ли данные из хранилища при старте приложения.
-	defaultRestore          bool   = false
-	defaultConnectionString string = ""    // строка подключения к базе данных
-	defaultCryptoKeyPath    string = ""    // путь до приватного ключа
-	defaultTrustedSubnet    string = ""    // разрешённые подсети
-	defaultGrpcEnabled      bool   = false // включать ли поддержку gRPC
+	defaultRestore           bool   = false
+	defaultConnectionString  string = ""     // строка подключения к базе данных
+	defaultCryptoKeyPath     string = ""     // путь до приватного ключа
+	defaultTrustedSubnet     string = ""     // разрешённые подсети
+	defaultGrpcEnabled       bool   = false  // включать ли поддержку gRPC
+	defaultHistoryEnabled    bool   = false  // сохранять ли историю значений метрик
+	defaultHistogramBuckets  string = ""     // границы корзин гистограмм через запятую (пусто - по умолчанию)
+	defaultIdempotencyWindow int64  = 300    // время хранения ключей идемпотентности (в секундах, 0 - отключено)
+	defaultAlertRulesPath    string = ""     // путь до файла правил оповещений (пусто - отключено)
+	defaultRetention         int64  = 0      // время хранения неизменяемых метрик (в секундах, 0 - без ограничения)
+	defaultRetentionRules    string = ""     // время хранения метрик по шаблону имени (шаблон=секунды через запятую)
+	defaultStoreWAL          bool   = false  // вести ли журнал изменений для хранилища в памяти
+	defaultStoreGenerations  int64  = 3      // количество хранимых поколений файла снимка, включая текущее
+	defaultStoreFormat       string = "json" // формат файла снимка: json, proto или proto-gzip
 )
 
 // Config - структура, содержащая основные параметры приложения.
 type Config struct {
-	ServerAddress    string // Адрес сервера
-	HashKey          string // Ключ хэширования
-	CryptoKeyPath    string // Путь до приватного ключа
-	FileStoragePath  string // Путь до файла хранилища (относительный)
-	ConnectionString string // Строка подключения к базе данных
-	TrustedSubnet    string // Разрешённые подсети
-	StoreInterval    int64  // Интервал сохранения данных в хранилище (в секундах)
-	Restore          bool   // Флаг, указывающий загружать ли данные из хранилища при старте приложения
-	GrpcEnabled      bool   // Bключать ли поддержку gRPC
+	ServerAddress     string // Адрес сервера
+	HashKey           string // Ключ хэширования
+	CryptoKeyPath     string // Путь до приватного ключа
+	FileStoragePath   string // Путь до файла хранилища (относительный)
+	ConnectionString  string // Строка подключения к хранилищу (postgres, sqlite, memory, file, wal, s3)
+	TrustedSubnet     string // Разрешённые подсети
+	StoreInterval     int64  // Интервал сохранения данных в хранилище (в секундах)
+	Restore           bool   // Флаг, указывающий загружать ли данные из хранилища при старте приложения
+	GrpcEnabled       bool   // Bключать ли поддержку gRPC
+	HistoryEnabled    bool   // Сохранять ли историю значений метрик
+	HistogramBuckets  string // Границы корзин гистограмм через запятую (пусто - по умолчанию)
+	IdempotencyWindow int64  // Время хранения ключей идемпотентности (в секундах, 0 - отключено)
+	AlertRulesPath    string // Путь до файла правил оповещений (пусто - отключено)
+	Retention         int64  // Время хранения неизменяемых метрик (в секундах, 0 - без ограничения)
+	RetentionRules    string // Время хранения метрик по шаблону имени (шаблон=секунды через запятую)
+	StoreWAL          bool   // Вести ли журнал изменений для хранилища в памяти
+	StoreGenerations  int64  // Количество хранимых поколений файла снимка, включая текущее
+	StoreFormat       string // Формат файла снимка: json, proto или proto-gzip
 }
 
 // Initialize создаёт и иницализирует объект *Config.
@@ -35,8 +55,17 @@ type Config struct {
 //   - значения из флагов командной строки;
 //   - значения из переменных окружения.
 func Initialize() *Config {
+	return InitializeFromArgs(os.Args[1:])
+}
+
+// InitializeFromArgs создаёт и иницализирует объект *Config так же, как Initialize,
+// но читает флаги из указанных аргументов (например, из аргументов подкоманды).
+//
+// Параметры:
+//   - args: аргументы командной строки без имени приложения
+func InitializeFromArgs(args []string) *Config {
 	envsConf := getEnvsConfigFromOS()
-	flagsConf, _ := getFlagsConfigFromOS()
+	flagsConf, _ := getFlagsConfigFromOS(args)
 
 	var path string
 	if flagsConf.configPathIsValue {
@@ -55,15 +84,24 @@ func Initialize() *Config {
 
 func createAndOverrideConfig(fileConf *configJSONs, flagsConf *configFlags, envsConf *configEnvs) *Config {
 	config := &Config{
-		ServerAddress:    defaultServerAddress,
-		HashKey:          defaultHashKey,
-		CryptoKeyPath:    defaultCryptoKeyPath,
-		StoreInterval:    defaultStoreInterval,
-		FileStoragePath:  defaultFileStoragePath,
-		TrustedSubnet:    defaultTrustedSubnet,
-		ConnectionString: defaultConnectionString,
-		Restore:          defaultRestore,
-		GrpcEnabled:      defaultGrpcEnabled,
+		ServerAddress:     defaultServerAddress,
+		HashKey:           defaultHashKey,
+		CryptoKeyPath:     defaultCryptoKeyPath,
+		StoreInterval:     defaultStoreInterval,
+		FileStoragePath:   defaultFileStoragePath,
+		TrustedSubnet:     defaultTrustedSubnet,
+		ConnectionString:  defaultConnectionString,
+		Restore:           defaultRestore,
+		GrpcEnabled:       defaultGrpcEnabled,
+		HistoryEnabled:    defaultHistoryEnabled,
+		HistogramBuckets:  defaultHistogramBuckets,
+		IdempotencyWindow: defaultIdempotencyWindow,
+		AlertRulesPath:    defaultAlertRulesPath,
+		Retention:         defaultRetention,
+		RetentionRules:    defaultRetentionRules,
+		StoreWAL:          defaultStoreWAL,
+		StoreGenerations:  defaultStoreGenerations,
+		StoreFormat:       defaultStoreFormat,
 	}
 
 	config.overrideConfigFromJSONs(fileConf)
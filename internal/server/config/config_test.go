@@ -52,35 +52,62 @@ func TestGetEnvsConfig(t *testing.T) {
 		{
 			name: "full values",
 			env: map[string]string{
-				"CONFIG":            "/config.json",
-				"CRYPTO_KEY":        "/keys/public.pem",
-				"DATABASE_DSN":      "my database",
-				"KEY":               "myhashkey",
-				"ADDRESS":           "example.com:8080",
-				"FILE_STORAGE_PATH": "my storage",
-				"TRUSTED_SUBNET":    "mylocalhost",
-				"STORE_INTERVAL":    "15",
-				"RESTORE":           "true",
+				"CONFIG":             "/config.json",
+				"CRYPTO_KEY":         "/keys/public.pem",
+				"DATABASE_DSN":       "my database",
+				"KEY":                "myhashkey",
+				"ADDRESS":            "example.com:8080",
+				"FILE_STORAGE_PATH":  "my storage",
+				"TRUSTED_SUBNET":     "mylocalhost",
+				"STORE_INTERVAL":     "15",
+				"RESTORE":            "true",
+				"HISTORY_ENABLED":    "true",
+				"HISTOGRAM_BUCKETS":  "0.1,1",
+				"IDEMPOTENCY_WINDOW": "60",
+				"ALERT_RULES":        "/etc/rules.json",
+				"RETENTION":          "3600",
+				"RETENTION_RULES":    "tmp_*=60",
+				"STORE_WAL":          "true",
+				"STORE_GENERATIONS":  "5",
+				"STORE_FORMAT":       "proto",
 			},
 			expected: configEnvs{
-				configPath:           "/config.json",
-				configPathIsValue:    true,
-				connString:           "my database",
-				connStringIsValue:    true,
-				cryptoKeyPath:        "/keys/public.pem",
-				cryptoKeyPathIsValue: true,
-				hashKey:              "myhashkey",
-				hashKeyIsValue:       true,
-				serverAddress:        "example.com:8080",
-				serverAddressIsValue: true,
-				storagePath:          "my storage",
-				storagePathIsValue:   true,
-				trustedSubnet:        "mylocalhost",
-				trustedSubnetIsValue: true,
-				storeInterval:        15,
-				storeIntervalIsValue: true,
-				restore:              true,
-				restoreIsValue:       true,
+				configPath:               "/config.json",
+				configPathIsValue:        true,
+				connString:               "my database",
+				connStringIsValue:        true,
+				cryptoKeyPath:            "/keys/public.pem",
+				cryptoKeyPathIsValue:     true,
+				hashKey:                  "myhashkey",
+				hashKeyIsValue:           true,
+				serverAddress:            "example.com:8080",
+				serverAddressIsValue:     true,
+				storagePath:              "my storage",
+				storagePathIsValue:       true,
+				trustedSubnet:            "mylocalhost",
+				trustedSubnetIsValue:     true,
+				storeInterval:            15,
+				storeIntervalIsValue:     true,
+				restore:                  true,
+				restoreIsValue:           true,
+				historyEnabled:           true,
+				historyEnabledIsValue:    true,
+				histogramBuckets:         "0.1,1",
+				histogramBucketsIsValue:  true,
+				idempotencyWindow:        60,
+				idempotencyWindowIsValue: true,
+				alertRulesPath:           "/etc/rules.json",
+				alertRulesPathIsValue:    true,
+				retention:                int64(3600),
+				retentionIsValue:         true,
+				retentionRules:           "tmp_*=60",
+				retentionRulesIsValue:    true,
+				storeWAL:                 true,
+				storeWALIsValue:          true,
+				storeGenerations:         5,
+				storeGenerationsIsValue:  true,
+				storeFormat:              "proto",
+				storeFormatIsValue:       true,
 			},
 		},
 		{
@@ -170,6 +197,33 @@ func TestGetEnvsConfig(t *testing.T) {
 
 			assert.Equal(t, tt.expected.restore, config.restore)
 			assert.Equal(t, tt.expected.restoreIsValue, config.restoreIsValue)
+
+			assert.Equal(t, tt.expected.historyEnabled, config.historyEnabled)
+			assert.Equal(t, tt.expected.historyEnabledIsValue, config.historyEnabledIsValue)
+
+			assert.Equal(t, tt.expected.histogramBuckets, config.histogramBuckets)
+			assert.Equal(t, tt.expected.histogramBucketsIsValue, config.histogramBucketsIsValue)
+
+			assert.Equal(t, tt.expected.idempotencyWindow, config.idempotencyWindow)
+			assert.Equal(t, tt.expected.idempotencyWindowIsValue, config.idempotencyWindowIsValue)
+
+			assert.Equal(t, tt.expected.alertRulesPath, config.alertRulesPath)
+			assert.Equal(t, tt.expected.alertRulesPathIsValue, config.alertRulesPathIsValue)
+
+			assert.Equal(t, tt.expected.retention, config.retention)
+			assert.Equal(t, tt.expected.retentionIsValue, config.retentionIsValue)
+
+			assert.Equal(t, tt.expected.retentionRules, config.retentionRules)
+			assert.Equal(t, tt.expected.retentionRulesIsValue, config.retentionRulesIsValue)
+
+			assert.Equal(t, tt.expected.storeWAL, config.storeWAL)
+			assert.Equal(t, tt.expected.storeWALIsValue, config.storeWALIsValue)
+
+			assert.Equal(t, tt.expected.storeGenerations, config.storeGenerations)
+			assert.Equal(t, tt.expected.storeGenerationsIsValue, config.storeGenerationsIsValue)
+
+			assert.Equal(t, tt.expected.storeFormat, config.storeFormat)
+			assert.Equal(t, tt.expected.storeFormatIsValue, config.storeFormatIsValue)
 		})
 	}
 }
@@ -191,27 +245,54 @@ func TestGetFlagsConfig(t *testing.T) {
 				"-f", "my storage",
 				"-t", "mylocalhost",
 				"-i", "15",
+				"-history",
+				"-histogram-buckets", "0.1,1",
+				"-idempotency-window", "60",
+				"-alert-rules", "/etc/rules.json",
+				"-retention", "600",
+				"-retention-rules", "cpu_*=0",
+				"-wal",
+				"-store-generations", "7",
+				"-store-format", "proto-gzip",
 				"-r", "true",
 			},
 			expected: configFlags{
-				configPath:           "/config.json",
-				configPathIsValue:    true,
-				connString:           "my database",
-				connStringIsValue:    true,
-				cryptoKeyPath:        "/keys/public.pem",
-				cryptoKeyPathIsValue: true,
-				hashKey:              "myhashkey",
-				hashKeyIsValue:       true,
-				serverAddress:        "example.com:8080",
-				serverAddressIsValue: true,
-				storagePath:          "my storage",
-				storagePathIsValue:   true,
-				trustedSubnet:        "mylocalhost",
-				trustedSubnetIsValue: true,
-				storeInterval:        15,
-				storeIntervalIsValue: true,
-				restore:              true,
-				restoreIsValue:       true,
+				configPath:               "/config.json",
+				configPathIsValue:        true,
+				connString:               "my database",
+				connStringIsValue:        true,
+				cryptoKeyPath:            "/keys/public.pem",
+				cryptoKeyPathIsValue:     true,
+				hashKey:                  "myhashkey",
+				hashKeyIsValue:           true,
+				serverAddress:            "example.com:8080",
+				serverAddressIsValue:     true,
+				storagePath:              "my storage",
+				storagePathIsValue:       true,
+				trustedSubnet:            "mylocalhost",
+				trustedSubnetIsValue:     true,
+				storeInterval:            15,
+				storeIntervalIsValue:     true,
+				restore:                  true,
+				restoreIsValue:           true,
+				historyEnabled:           true,
+				historyEnabledIsValue:    true,
+				histogramBuckets:         "0.1,1",
+				histogramBucketsIsValue:  true,
+				idempotencyWindow:        60,
+				idempotencyWindowIsValue: true,
+				alertRulesPath:           "/etc/rules.json",
+				alertRulesPathIsValue:    true,
+				retention:                int64(600),
+				retentionIsValue:         true,
+				retentionRules:           "cpu_*=0",
+				retentionRulesIsValue:    true,
+				storeWAL:                 true,
+				storeWALIsValue:          true,
+				storeGenerations:         7,
+				storeGenerationsIsValue:  true,
+				storeFormat:              "proto-gzip",
+				storeFormatIsValue:       true,
 			},
 		},
 		{
@@ -300,6 +381,33 @@ func TestGetFlagsConfig(t *testing.T) {
 
 			assert.Equal(t, tt.expected.restore, config.restore)
 			assert.Equal(t, tt.expected.restoreIsValue, config.restoreIsValue)
+
+			assert.Equal(t, tt.expected.historyEnabled, config.historyEnabled)
+			assert.Equal(t, tt.expected.historyEnabledIsValue, config.historyEnabledIsValue)
+
+			assert.Equal(t, tt.expected.histogramBuckets, config.histogramBuckets)
+			assert.Equal(t, tt.expected.histogramBucketsIsValue, config.histogramBucketsIsValue)
+
+			assert.Equal(t, tt.expected.idempotencyWindow, config.idempotencyWindow)
+			assert.Equal(t, tt.expected.idempotencyWindowIsValue, config.idempotencyWindowIsValue)
+
+			assert.Equal(t, tt.expected.alertRulesPath, config.alertRulesPath)
+			assert.Equal(t, tt.expected.alertRulesPathIsValue, config.alertRulesPathIsValue)
+
+			assert.Equal(t, tt.expected.retention, config.retention)
+			assert.Equal(t, tt.expected.retentionIsValue, config.retentionIsValue)
+
+			assert.Equal(t, tt.expected.retentionRules, config.retentionRules)
+			assert.Equal(t, tt.expected.retentionRulesIsValue, config.retentionRulesIsValue)
+
+			assert.Equal(t, tt.expected.storeWAL, config.storeWAL)
+			assert.Equal(t, tt.expected.storeWALIsValue, config.storeWALIsValue)
+
+			assert.Equal(t, tt.expected.storeGenerations, config.storeGenerations)
+			assert.Equal(t, tt.expected.storeGenerationsIsValue, config.storeGenerationsIsValue)
+
+			assert.Equal(t, tt.expected.storeFormat, config.storeFormat)
+			assert.Equal(t, tt.expected.storeFormatIsValue, config.storeFormatIsValue)
 		})
 	}
 }
@@ -319,23 +427,50 @@ func TestGetJSONConfig(t *testing.T) {
 				"trusted_subnet": "mylocalhost",
 				"store_interval": 12,
 				"database_dsn": "my database",
-				"restore": true
+				"restore": true,
+				"history_enabled": true,
+				"histogram_buckets": "0.1,1",
+				"idempotency_window": 60,
+				"alert_rules": "/etc/rules.json",
+				"retention": 86400,
+				"retention_rules": "Test*=120",
+				"store_wal": true,
+				"store_generations": 4,
+				"store_format": "proto"
 			}`,
 			expected: configJSONs{
-				ServerAddress:        "localhost:8080",
-				serverAddressIsValue: true,
-				CryptoKeyPath:        "/keys/public.pem",
-				cryptoKeyPathIsValue: true,
-				StoragePath:          "/path/to/file.db",
-				storagePathIsValue:   true,
-				TrustedSubnet:        "mylocalhost",
-				trustedSubnetIsValue: true,
-				StoreInterval:        12,
-				storeIntervalIsValue: true,
-				ConnString:           "my database",
-				connStringIsValue:    true,
-				Restore:              true,
-				restoreIsValue:       true,
+				ServerAddress:            "localhost:8080",
+				serverAddressIsValue:     true,
+				CryptoKeyPath:            "/keys/public.pem",
+				cryptoKeyPathIsValue:     true,
+				StoragePath:              "/path/to/file.db",
+				storagePathIsValue:       true,
+				TrustedSubnet:            "mylocalhost",
+				trustedSubnetIsValue:     true,
+				StoreInterval:            12,
+				storeIntervalIsValue:     true,
+				ConnString:               "my database",
+				connStringIsValue:        true,
+				Restore:                  true,
+				restoreIsValue:           true,
+				HistoryEnabled:           true,
+				historyEnabledIsValue:    true,
+				HistogramBuckets:         "0.1,1",
+				histogramBucketsIsValue:  true,
+				IdempotencyWindow:        60,
+				idempotencyWindowIsValue: true,
+				AlertRulesPath:           "/etc/rules.json",
+				alertRulesPathIsValue:    true,
+				Retention:                int64(86400),
+				retentionIsValue:         true,
+				RetentionRules:           "Test*=120",
+				retentionRulesIsValue:    true,
+				StoreWAL:                 true,
+				storeWALIsValue:          true,
+				StoreGenerations:         4,
+				storeGenerationsIsValue:  true,
+				StoreFormat:              "proto",
+				storeFormatIsValue:       true,
 			},
 		},
 		{
@@ -407,6 +542,33 @@ func TestGetJSONConfig(t *testing.T) {
 
 			assert.Equal(t, tt.expected.Restore, config.Restore)
 			assert.Equal(t, tt.expected.restoreIsValue, config.restoreIsValue)
+
+			assert.Equal(t, tt.expected.HistoryEnabled, config.HistoryEnabled)
+			assert.Equal(t, tt.expected.historyEnabledIsValue, config.historyEnabledIsValue)
+
+			assert.Equal(t, tt.expected.HistogramBuckets, config.HistogramBuckets)
+			assert.Equal(t, tt.expected.histogramBucketsIsValue, config.histogramBucketsIsValue)
+
+			assert.Equal(t, tt.expected.IdempotencyWindow, config.IdempotencyWindow)
+			assert.Equal(t, tt.expected.idempotencyWindowIsValue, config.idempotencyWindowIsValue)
+
+			assert.Equal(t, tt.expected.AlertRulesPath, config.AlertRulesPath)
+			assert.Equal(t, tt.expected.alertRulesPathIsValue, config.alertRulesPathIsValue)
+
+			assert.Equal(t, tt.expected.Retention, config.Retention)
+			assert.Equal(t, tt.expected.retentionIsValue, config.retentionIsValue)
+
+			assert.Equal(t, tt.expected.RetentionRules, config.RetentionRules)
+			assert.Equal(t, tt.expected.retentionRulesIsValue, config.retentionRulesIsValue)
+
+			assert.Equal(t, tt.expected.StoreWAL, config.StoreWAL)
+			assert.Equal(t, tt.expected.storeWALIsValue, config.storeWALIsValue)
+
+			assert.Equal(t, tt.expected.StoreGenerations, config.StoreGenerations)
+			assert.Equal(t, tt.expected.storeGenerationsIsValue, config.storeGenerationsIsValue)
+
+			assert.Equal(t, tt.expected.StoreFormat, config.StoreFormat)
+			assert.Equal(t, tt.expected.storeFormatIsValue, config.storeFormatIsValue)
 		})
 	}
 }
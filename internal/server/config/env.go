@@ -7,26 +7,44 @@ import (
 
 // configEnvs - структура, содержащая основные переменные окружения для приложения.
 type configEnvs struct {
-	configPath           string // путь до JSON конфига
-	connString           string // строка подключения к базе данных
-	cryptoKeyPath        string // путь до публичного ключа
-	hashKey              string // ключ хэширования
-	serverAddress        string // адрес сервера
-	storagePath          string // путь до файла хранилища (относительный)
-	trustedSubnet        string // разрешённые подсети
-	storeInterval        int64  // интервал сохранения данных в хранилище (в секундах)
-	restore              bool   // флаг, указывающий загружать ли данные из хранилища при старте приложения
-	grpcEnabled          bool   // включать ли поддержку gRPC
-	configPathIsValue    bool
-	connStringIsValue    bool
-	cryptoKeyPathIsValue bool
-	hashKeyIsValue       bool
-	serverAddressIsValue bool
-	storagePathIsValue   bool
-	trustedSubnetIsValue bool
-	storeIntervalIsValue bool
-	restoreIsValue       bool
-	grpcEnabledIsValue   bool
+	configPath               string // путь до JSON конфига
+	connString               string // строка подключения к базе данных
+	cryptoKeyPath            string // путь до публичного ключа
+	hashKey                  string // ключ хэширования
+	serverAddress            string // адрес сервера
+	storagePath              string // путь до файла хранилища (относительный)
+	trustedSubnet            string // разрешённые подсети
+	storeInterval            int64  // интервал сохранения данных в хранилище (в секундах)
+	restore                  bool   // флаг, указывающий загружать ли данные из хранилища при старте приложения
+	grpcEnabled              bool   // включать ли поддержку gRPC
+	historyEnabled           bool   // сохранять ли историю значений метрик
+	histogramBuckets         string // границы корзин гистограмм через запятую (пусто - по умолчанию)
+	idempotencyWindow        int64  // время хранения ключей идемпотентности (в секундах, 0 - отключено)
+	alertRulesPath           string // путь до файла правил оповещений (пусто - отключено)
+	retention                int64  // время хранения неизменяемых метрик (в секундах, 0 - без ограничения)
+	retentionRules           string // время хранения метрик по шаблону имени (шаблон=секунды через запятую)
+	storeWAL                 bool   // вести ли журнал изменений для хранилища в памяти
+	storeGenerations         int64  // количество хранимых поколений файла снимка, включая текущее
+	storeFormat              string // формат файла снимка: json, proto или proto-gzip
+	configPathIsValue        bool
+	connStringIsValue        bool
+	cryptoKeyPathIsValue     bool
+	hashKeyIsValue           bool
+	serverAddressIsValue     bool
+	storagePathIsValue       bool
+	trustedSubnetIsValue     bool
+	storeIntervalIsValue     bool
+	restoreIsValue           bool
+	grpcEnabledIsValue       bool
+	historyEnabledIsValue    bool
+	histogramBucketsIsValue  bool
+	idempotencyWindowIsValue bool
+	alertRulesPathIsValue    bool
+	retentionIsValue         bool
+	retentionRulesIsValue    bool
+	storeWALIsValue          bool
+	storeGenerationsIsValue  bool
+	storeFormatIsValue       bool
 }
 
 // envReader — интерфейс для чтения переменных окружения.
@@ -102,6 +120,70 @@ func getEnvsConfig(getenv envReader) *configEnvs {
 		}
 	}
 
+	envHistoryEnabled, ok := getenv("HISTORY_ENABLED")
+	if ok && envHistoryEnabled != "" {
+		if val, err := strconv.ParseBool(envHistoryEnabled); err == nil {
+			config.historyEnabled = val
+			config.historyEnabledIsValue = true
+		}
+	}
+
+	envHistogramBuckets, ok := getenv("HISTOGRAM_BUCKETS")
+	if ok && envHistogramBuckets != "" {
+		config.histogramBuckets = envHistogramBuckets
+		config.histogramBucketsIsValue = true
+	}
+
+	envIdempotencyWindow, ok := getenv("IDEMPOTENCY_WINDOW")
+	if ok && envIdempotencyWindow != "" {
+		if val, err := strconv.ParseInt(envIdempotencyWindow, 10, 64); err == nil {
+			config.idempotencyWindow = val
+			config.idempotencyWindowIsValue = true
+		}
+	}
+
+	envAlertRulesPath, ok := getenv("ALERT_RULES")
+	if ok && envAlertRulesPath != "" {
+		config.alertRulesPath = envAlertRulesPath
+		config.alertRulesPathIsValue = true
+	}
+
+	envRetention, ok := getenv("RETENTION")
+	if ok && envRetention != "" {
+		if val, err := strconv.ParseInt(envRetention, 10, 64); err == nil {
+			config.retention = val
+			config.retentionIsValue = true
+		}
+	}
+
+	envRetentionRules, ok := getenv("RETENTION_RULES")
+	if ok && envRetentionRules != "" {
+		config.retentionRules = envRetentionRules
+		config.retentionRulesIsValue = true
+	}
+
+	envStoreWAL, ok := getenv("STORE_WAL")
+	if ok && envStoreWAL != "" {
+		if val, err := strconv.ParseBool(envStoreWAL); err == nil {
+			config.storeWAL = val
+			config.storeWALIsValue = true
+		}
+	}
+
+	envStoreGenerations, ok := getenv("STORE_GENERATIONS")
+	if ok && envStoreGenerations != "" {
+		if val, err := strconv.ParseInt(envStoreGenerations, 10, 64); err == nil {
+			config.storeGenerations = val
+			config.storeGenerationsIsValue = true
+		}
+	}
+
+	envStoreFormat, ok := getenv("STORE_FORMAT")
+	if ok && envStoreFormat != "" {
+		config.storeFormat = envStoreFormat
+		config.storeFormatIsValue = true
+	}
+
 	return config
 }
 
@@ -148,4 +230,31 @@ func (c *Config) overrideConfigFromEnvs(conf *configEnvs) {
 	if conf.grpcEnabledIsValue {
 		c.GrpcEnabled = conf.grpcEnabled
 	}
+	if conf.historyEnabledIsValue {
+		c.HistoryEnabled = conf.historyEnabled
+	}
+	if conf.histogramBucketsIsValue {
+		c.HistogramBuckets = conf.histogramBuckets
+	}
+	if conf.idempotencyWindowIsValue {
+		c.IdempotencyWindow = conf.idempotencyWindow
+	}
+	if conf.alertRulesPathIsValue {
+		c.AlertRulesPath = conf.alertRulesPath
+	}
+	if conf.retentionIsValue {
+		c.Retention = conf.retention
+	}
+	if conf.retentionRulesIsValue {
+		c.RetentionRules = conf.retentionRules
+	}
+	if conf.storeWALIsValue {
+		c.StoreWAL = conf.storeWAL
+	}
+	if conf.storeGenerationsIsValue {
+		c.StoreGenerations = conf.storeGenerations
+	}
+	if conf.storeFormatIsValue {
+		c.StoreFormat = conf.storeFormat
+	}
 }
@@ -8,26 +8,44 @@ import (
 
 // configFlags - структура, содержащая основные флаги приложения.
 type configFlags struct {
-	configPath           string // путь до JSON конфига
-	connString           string // строка подключения к базе данных
-	cryptoKeyPath        string // путь до публичного ключа
-	hashKey              string // ключ хэширования
-	serverAddress        string // адрес сервера
-	storagePath          string // путь до файла хранилища (относительный)
-	trustedSubnet        string // разрешённые подсети
-	storeInterval        int64  // интервал сохранения данных в хранилище (в секундах)
-	restore              bool   // флаг, указывающий загружать ли данные из хранилища при старте приложения
-	grpcEnabled          bool   // включать ли поддержку gRPC
-	configPathIsValue    bool
-	connStringIsValue    bool
-	cryptoKeyPathIsValue bool
-	hashKeyIsValue       bool
-	serverAddressIsValue bool
-	storagePathIsValue   bool
-	trustedSubnetIsValue bool
-	storeIntervalIsValue bool
-	restoreIsValue       bool
-	grpcEnabledIsValue   bool
+	configPath               string // путь до JSON конфига
+	connString               string // строка подключения к базе данных
+	cryptoKeyPath            string // путь до публичного ключа
+	hashKey                  string // ключ хэширования
+	serverAddress            string // адрес сервера
+	storagePath              string // путь до файла хранилища (относительный)
+	trustedSubnet            string // разрешённые подсети
+	storeInterval            int64  // интервал сохранения данных в хранилище (в секундах)
+	restore                  bool   // флаг, указывающий загружать ли данные из хранилища при старте приложения
+	grpcEnabled              bool   // включать ли поддержку gRPC
+	historyEnabled           bool   // сохранять ли историю значений метрик
+	histogramBuckets         string // границы корзин гистограмм через запятую (пусто - по умолчанию)
+	idempotencyWindow        int64  // время хранения ключей идемпотентности (в секундах, 0 - отключено)
+	alertRulesPath           string // путь до файла правил оповещений (пусто - отключено)
+	retention                int64  // время хранения неизменяемых метрик (в секундах, 0 - без ограничения)
+	retentionRules           string // время хранения метрик по шаблону имени (шаблон=секунды через запятую)
+	storeWAL                 bool   // вести ли журнал изменений для хранилища в памяти
+	storeGenerations         int64  // количество хранимых поколений файла снимка, включая текущее
+	storeFormat              string // формат файла снимка: json, proto или proto-gzip
+	configPathIsValue        bool
+	connStringIsValue        bool
+	cryptoKeyPathIsValue     bool
+	hashKeyIsValue           bool
+	serverAddressIsValue     bool
+	storagePathIsValue       bool
+	trustedSubnetIsValue     bool
+	storeIntervalIsValue     bool
+	restoreIsValue           bool
+	grpcEnabledIsValue       bool
+	historyEnabledIsValue    bool
+	histogramBucketsIsValue  bool
+	idempotencyWindowIsValue bool
+	alertRulesPathIsValue    bool
+	retentionIsValue         bool
+	retentionRulesIsValue    bool
+	storeWALIsValue          bool
+	storeGenerationsIsValue  bool
+	storeFormatIsValue       bool
 }
 
 // getFlagsConfig получает конфиг из указанных аргументов.
@@ -45,6 +63,15 @@ func getFlagsConfig(fs *flag.FlagSet, args []string) (*configFlags, error) {
 	argI := fs.Int64("i", 0, "Interval in seconds to save data")
 	argR := fs.Bool("r", false, "Loading data when the application starts")
 	argG := fs.Bool("g", false, "gRPC enabled")
+	argHistory := fs.Bool("history", false, "Store the history of metric values")
+	argHistogramBuckets := fs.String("histogram-buckets", "", "Comma-separated histogram bucket bounds")
+	argIdempotencyWindow := fs.Int64("idempotency-window", 0, "Idempotency key window in seconds")
+	argAlertRulesPath := fs.String("alert-rules", "", "Alert rules file path")
+	argRetention := fs.Int64("retention", 0, "Retention of not updated metrics in seconds")
+	argRetentionRules := fs.String("retention-rules", "", "Comma-separated per-name retention rules (pattern=seconds)")
+	argStoreWAL := fs.Bool("wal", false, "Write an append-only journal of metric changes next to the file storage")
+	argStoreGenerations := fs.Int64("store-generations", 0, "Snapshot file generations to keep")
+	argStoreFormat := fs.String("store-format", "", "Snapshot file format: json, proto or proto-gzip")
 
 	if err := fs.Parse(args); err != nil {
 		return nil, fmt.Errorf("parse argument %w", err)
@@ -94,14 +121,53 @@ func getFlagsConfig(fs *flag.FlagSet, args []string) (*configFlags, error) {
 		config.grpcEnabled = *argG
 		config.grpcEnabledIsValue = true
 	}
+	if argHistory != nil && *argHistory {
+		config.historyEnabled = *argHistory
+		config.historyEnabledIsValue = true
+	}
+	if argHistogramBuckets != nil && *argHistogramBuckets != "" {
+		config.histogramBuckets = *argHistogramBuckets
+		config.histogramBucketsIsValue = true
+	}
+	if argIdempotencyWindow != nil && *argIdempotencyWindow != 0 {
+		config.idempotencyWindow = *argIdempotencyWindow
+		config.idempotencyWindowIsValue = true
+	}
+	if argAlertRulesPath != nil && *argAlertRulesPath != "" {
+		config.alertRulesPath = *argAlertRulesPath
+		config.alertRulesPathIsValue = true
+	}
+	if argRetention != nil && *argRetention != 0 {
+		config.retention = *argRetention
+		config.retentionIsValue = true
+	}
+	if argRetentionRules != nil && *argRetentionRules != "" {
+		config.retentionRules = *argRetentionRules
+		config.retentionRulesIsValue = true
+	}
+	if argStoreWAL != nil && *argStoreWAL {
+		config.storeWAL = *argStoreWAL
+		config.storeWALIsValue = true
+	}
+	if argStoreGenerations != nil && *argStoreGenerations != 0 {
+		config.storeGenerations = *argStoreGenerations
+		config.storeGenerationsIsValue = true
+	}
+	if argStoreFormat != nil && *argStoreFormat != "" {
+		config.storeFormat = *argStoreFormat
+		config.storeFormatIsValue = true
+	}
 
 	return config, nil
 }
 
 // getFlagsConfigFromOS получает значения флагов из аргументов запуска приложения в ОС.
-func getFlagsConfigFromOS() (*configFlags, error) {
+//
+// Параметры:
+//   - args: аргументы командной строки без имени приложения
+func getFlagsConfigFromOS(args []string) (*configFlags, error) {
 	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-	config, err := getFlagsConfig(fs, os.Args[1:])
+	config, err := getFlagsConfig(fs, args)
 	if err != nil {
 		return nil, fmt.Errorf("get flag config %w", err)
 	}
@@ -143,4 +209,31 @@ func (c *Config) overrideConfigFromFlags(conf *configFlags) {
 	if conf.grpcEnabledIsValue {
 		c.GrpcEnabled = conf.grpcEnabled
 	}
+	if conf.historyEnabledIsValue {
+		c.HistoryEnabled = conf.historyEnabled
+	}
+	if conf.histogramBucketsIsValue {
+		c.HistogramBuckets = conf.histogramBuckets
+	}
+	if conf.idempotencyWindowIsValue {
+		c.IdempotencyWindow = conf.idempotencyWindow
+	}
+	if conf.alertRulesPathIsValue {
+		c.AlertRulesPath = conf.alertRulesPath
+	}
+	if conf.retentionIsValue {
+		c.Retention = conf.retention
+	}
+	if conf.retentionRulesIsValue {
+		c.RetentionRules = conf.retentionRules
+	}
+	if conf.storeWALIsValue {
+		c.StoreWAL = conf.storeWAL
+	}
+	if conf.storeGenerationsIsValue {
+		c.StoreGenerations = conf.storeGenerations
+	}
+	if conf.storeFormatIsValue {
+		c.StoreFormat = conf.storeFormat
+	}
 }
@@ -10,20 +10,38 @@ import (
 
 // configJSONs - структура, содержащая основные настройки в JSON для приложения.
 type configJSONs struct {
-	ConnString           string `json:"database_dsn,omitempty"`
-	CryptoKeyPath        string `json:"crypto_key,omitempty"`
-	ServerAddress        string `json:"address,omitempty"`
-	StoragePath          string `json:"store_file,omitempty"`
-	TrustedSubnet        string `json:"trusted_subnet,omitempty"`
-	StoreInterval        int64  `json:"store_interval,omitempty"`
-	Restore              bool   `json:"restore,omitempty"`
-	connStringIsValue    bool   `json:"-"`
-	cryptoKeyPathIsValue bool   `json:"-"`
-	serverAddressIsValue bool   `json:"-"`
-	storagePathIsValue   bool   `json:"-"`
-	trustedSubnetIsValue bool   `json:"-"`
-	storeIntervalIsValue bool   `json:"-"`
-	restoreIsValue       bool   `json:"-"`
+	ConnString               string `json:"database_dsn,omitempty"`
+	CryptoKeyPath            string `json:"crypto_key,omitempty"`
+	ServerAddress            string `json:"address,omitempty"`
+	StoragePath              string `json:"store_file,omitempty"`
+	TrustedSubnet            string `json:"trusted_subnet,omitempty"`
+	StoreInterval            int64  `json:"store_interval,omitempty"`
+	Restore                  bool   `json:"restore,omitempty"`
+	HistoryEnabled           bool   `json:"history_enabled,omitempty"`
+	HistogramBuckets         string `json:"histogram_buckets,omitempty"`
+	IdempotencyWindow        int64  `json:"idempotency_window,omitempty"`
+	AlertRulesPath           string `json:"alert_rules,omitempty"`
+	Retention                int64  `json:"retention,omitempty"`
+	RetentionRules           string `json:"retention_rules,omitempty"`
+	StoreWAL                 bool   `json:"store_wal,omitempty"`
+	StoreGenerations         int64  `json:"store_generations,omitempty"`
+	StoreFormat              string `json:"store_format,omitempty"`
+	connStringIsValue        bool   `json:"-"`
+	cryptoKeyPathIsValue     bool   `json:"-"`
+	serverAddressIsValue     bool   `json:"-"`
+	storagePathIsValue       bool   `json:"-"`
+	trustedSubnetIsValue     bool   `json:"-"`
+	storeIntervalIsValue     bool   `json:"-"`
+	restoreIsValue           bool   `json:"-"`
+	historyEnabledIsValue    bool   `json:"-"`
+	histogramBucketsIsValue  bool   `json:"-"`
+	idempotencyWindowIsValue bool   `json:"-"`
+	alertRulesPathIsValue    bool   `json:"-"`
+	retentionIsValue         bool   `json:"-"`
+	retentionRulesIsValue    bool   `json:"-"`
+	storeWALIsValue          bool   `json:"-"`
+	storeGenerationsIsValue  bool   `json:"-"`
+	storeFormatIsValue       bool   `json:"-"`
 }
 
 // getJSONConfig получает конфиг из универсального io.Reader.
@@ -68,6 +86,42 @@ func getJSONConfig(r io.Reader) (*configJSONs, error) {
 		config.Restore = c.Restore
 		config.restoreIsValue = true
 	}
+	if c.HistoryEnabled {
+		config.HistoryEnabled = c.HistoryEnabled
+		config.historyEnabledIsValue = true
+	}
+	if c.HistogramBuckets != "" {
+		config.HistogramBuckets = c.HistogramBuckets
+		config.histogramBucketsIsValue = true
+	}
+	if c.IdempotencyWindow != 0 {
+		config.IdempotencyWindow = c.IdempotencyWindow
+		config.idempotencyWindowIsValue = true
+	}
+	if c.AlertRulesPath != "" {
+		config.AlertRulesPath = c.AlertRulesPath
+		config.alertRulesPathIsValue = true
+	}
+	if c.Retention != 0 {
+		config.Retention = c.Retention
+		config.retentionIsValue = true
+	}
+	if c.RetentionRules != "" {
+		config.RetentionRules = c.RetentionRules
+		config.retentionRulesIsValue = true
+	}
+	if c.StoreWAL {
+		config.StoreWAL = c.StoreWAL
+		config.storeWALIsValue = true
+	}
+	if c.StoreGenerations != 0 {
+		config.StoreGenerations = c.StoreGenerations
+		config.storeGenerationsIsValue = true
+	}
+	if c.StoreFormat != "" {
+		config.StoreFormat = c.StoreFormat
+		config.storeFormatIsValue = true
+	}
 
 	return config, nil
 }
@@ -116,4 +170,31 @@ func (c *Config) overrideConfigFromJSONs(conf *configJSONs) {
 	if conf.restoreIsValue {
 		c.Restore = conf.Restore
 	}
+	if conf.historyEnabledIsValue {
+		c.HistoryEnabled = conf.HistoryEnabled
+	}
+	if conf.histogramBucketsIsValue {
+		c.HistogramBuckets = conf.HistogramBuckets
+	}
+	if conf.idempotencyWindowIsValue {
+		c.IdempotencyWindow = conf.IdempotencyWindow
+	}
+	if conf.alertRulesPathIsValue {
+		c.AlertRulesPath = conf.AlertRulesPath
+	}
+	if conf.retentionIsValue {
+		c.Retention = conf.Retention
+	}
+	if conf.retentionRulesIsValue {
+		c.RetentionRules = conf.RetentionRules
+	}
+	if conf.storeWALIsValue {
+		c.StoreWAL = conf.StoreWAL
+	}
+	if conf.storeGenerationsIsValue {
+		c.StoreGenerations = conf.StoreGenerations
+	}
+	if conf.storeFormatIsValue {
+		c.StoreFormat = conf.StoreFormat
+	}
 }
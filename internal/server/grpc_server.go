@@ -3,16 +3,29 @@ package server
 import (
 	"context"
 	"crypto/rsa"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"io"
 	"net"
 
 	"github.com/Mr-Filatik/go-metrics-collector/internal/common"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/server/idempotency"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/server/interceptor"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/service"
 	"github.com/Mr-Filatik/go-metrics-collector/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Константы - размеры страницы списка метрик.
+const (
+	defaultListPageSize int32 = 100  // размер страницы по умолчанию
+	maxListPageSize     int32 = 1000 // максимальный размер страницы
 )
 
 // GrpcServer представляет gRPC-сервер приложения.
@@ -22,7 +35,8 @@ type GrpcServer struct {
 	serv    *grpc.Server
 	service *service.Service // сервис с основной логикой
 	// conveyor    *middleware.Conveyor // конвейер для middleware
-	log           logger.Logger // логгер
+	log           logger.Logger      // логгер
+	idempotency   *idempotency.Store // хранилище ключей идемпотентности
 	address       string
 	trustedSubnet string
 	hashKey       string
@@ -33,6 +47,7 @@ var _ Server = (*GrpcServer)(nil)
 type GrpcServerConfig struct {
 	PrivateRsaKey *rsa.PrivateKey
 	Service       *service.Service
+	Idempotency   *idempotency.Store
 	Address       string
 	HashKey       string
 	TrustedSubnet string
@@ -52,6 +67,7 @@ func NewGrpcServer(ctx context.Context, conf *GrpcServerConfig, log logger.Logge
 		trustedSubnet: conf.TrustedSubnet,
 		address:       conf.Address,
 		hashKey:       conf.HashKey,
+		idempotency:   conf.Idempotency,
 	}
 
 	if adr, err := common.ChangePortForGRPC(conf.Address); err == nil {
@@ -72,17 +88,8 @@ func (s *GrpcServer) Start(ctx context.Context) error {
 	if err != nil {
 		s.log.Error("Error listen in gRPC server", err)
 	}
-	conv := interceptor.New(s.trustedSubnet, s.hashKey, s.log)
-
-	var opts []grpc.ServerOption
-	opts = append(opts, grpc.ChainUnaryInterceptor(
-		conv.LoggingInterceptor,
-		conv.TrustingInterceptor,
-		conv.HashingInterceptor,
-	))
-	grpcServ := grpc.NewServer(opts...)
+	grpcServ := s.newServer()
 	s.serv = grpcServ
-	proto.RegisterMetricsServiceServer(grpcServ, s)
 	go func() {
 		if err := grpcServ.Serve(lis); err != nil {
 			s.log.Error("Error in GrpcServer", err)
@@ -93,6 +100,27 @@ func (s *GrpcServer) Start(ctx context.Context) error {
 	return nil
 }
 
+// newServer создаёт gRPC-сервер с цепочками interceptors и зарегистрированным сервисом.
+func (s *GrpcServer) newServer() *grpc.Server {
+	conv := interceptor.New(s.trustedSubnet, s.hashKey, s.idempotency, s.log)
+
+	var opts []grpc.ServerOption
+	opts = append(opts, grpc.ChainUnaryInterceptor(
+		conv.LoggingInterceptor,
+		conv.TrustingInterceptor,
+		conv.HashingInterceptor,
+		conv.IdempotencyInterceptor,
+	))
+	opts = append(opts, grpc.ChainStreamInterceptor(
+		conv.LoggingStreamInterceptor,
+		conv.TrustingStreamInterceptor,
+		conv.HashingStreamInterceptor,
+	))
+	grpcServ := grpc.NewServer(opts...)
+	proto.RegisterMetricsServiceServer(grpcServ, s)
+	return grpcServ
+}
+
 func (s *GrpcServer) Shutdown(ctx context.Context) error {
 	s.log.Info("GRPCServer shutdown starting...")
 	s.serv.GracefulStop()
@@ -115,36 +143,240 @@ func (s *GrpcServer) Close() error {
 func (s *GrpcServer) UpdateMetrics(
 	ctx context.Context,
 	req *proto.UpdateMetricsRequest) (*proto.UpdateMetricsResponse, error) {
-	metr := getMetricsFromProto(req)
+	if err := s.applyMetrics(ctx, req.GetMetrics()); err != nil {
+		return nil, err
+	}
+
+	return &proto.UpdateMetricsResponse{}, nil
+}
 
-	for _, m := range metr {
-		_, err := s.service.CreateOrUpdate(ctx, m)
+// StreamMetrics принимает пакеты метрик в одном долгоживущем потоке.
+// Каждый пакет применяется атомарно и подтверждается ответом с его номером;
+// ошибка применения пакета возвращается в подтверждении и не закрывает поток.
+//
+// Параметры:
+//   - stream: двунаправленный поток пакетов и подтверждений.
+func (s *GrpcServer) StreamMetrics(
+	stream grpc.BidiStreamingServer[proto.StreamMetricsRequest, proto.StreamMetricsResponse]) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
 		if err != nil {
-			if err.Error() == service.MetricNotFound || err.Error() == service.MetricUncorrect {
-				return nil, errors.New("uncorrect request data")
-			}
-			return nil, errors.New("unespected error")
+			return err //nolint:wrapcheck // статус gRPC из interceptors должен передаваться без изменений
 		}
+
+		resp := &proto.StreamMetricsResponse{Sequence: req.GetSequence()}
+		if err := s.applyStreamMessage(stream.Context(), req); err != nil {
+			resp.Error = err.Error()
+		}
+		if err := stream.Send(resp); err != nil {
+			return fmt.Errorf("send stream acknowledgement error: %w", err)
+		}
+	}
+}
+
+// applyStreamMessage применяет пакет из потока с учётом его ключа идемпотентности.
+func (s *GrpcServer) applyStreamMessage(ctx context.Context, req *proto.StreamMetricsRequest) error {
+	key := req.GetIdempotencyKey()
+	if s.idempotency == nil || key == "" {
+		return s.applyMetrics(ctx, req.GetMetrics())
 	}
+	scope := idempotency.Scope(idempotency.ProtocolGRPC, proto.MetricsService_StreamMetrics_FullMethodName)
 
-	return &proto.UpdateMetricsResponse{}, nil
+	state, _ := s.idempotency.Begin(key, scope)
+	switch state {
+	case idempotency.StateInProgress:
+		return errors.New("request with this idempotency key is in progress")
+	case idempotency.StateDone:
+		s.log.Info("Duplicate request acknowledged", "key", key)
+		return nil
+	case idempotency.StateNew:
+		// ключ встречен впервые, пакет применяется ниже
+	}
+
+	if err := s.applyMetrics(ctx, req.GetMetrics()); err != nil {
+		s.idempotency.Release(key)
+		return err
+	}
+	s.idempotency.Commit(key, scope, nil)
+	return nil
+}
+
+// applyMetrics атомарно применяет пакет метрик, учитывая источник вызова в разбивке counter.
+func (s *GrpcServer) applyMetrics(ctx context.Context, protoMetrics []*proto.Metric) error {
+	metr := getMetricsFromProto(protoMetrics)
+
+	_, err := s.service.CreateOrUpdateBatch(withMetadataSource(ctx), metr)
+	if err != nil {
+		if err.Error() == service.MetricNotFound || err.Error() == service.MetricUncorrect {
+			return errors.New("uncorrect request data")
+		}
+		return errors.New("unespected error")
+	}
+
+	return nil
 }
 
-func getMetricsFromProto(req *proto.UpdateMetricsRequest) []entity.Metrics {
-	protoMetrics := req.GetMetrics()
+// GetMetric возвращает одну метрику по имени, типу и меткам.
+//
+// Параметры:
+//   - ctx: контекст для отмены;
+//   - req: запрос.
+func (s *GrpcServer) GetMetric(
+	ctx context.Context,
+	req *proto.GetMetricRequest) (*proto.GetMetricResponse, error) {
+	key := (&entity.Metrics{ID: req.GetId(), Labels: req.GetLabels()}).Key()
+	m, err := s.service.Get(ctx, key, req.GetMtype())
+	if err != nil {
+		switch err.Error() {
+		case service.MetricNotFound:
+			return nil, status.Errorf(codes.NotFound, "metric not found")
+		case service.MetricUncorrect:
+			return nil, status.Errorf(codes.InvalidArgument, "uncorrect metric type")
+		default:
+			return nil, status.Errorf(codes.Internal, "unespected error")
+		}
+	}
+
+	return &proto.GetMetricResponse{Metric: getProtoFromMetric(m)}, nil
+}
+
+// ListMetrics возвращает страницу метрик, подходящих под фильтры, в порядке идентификаторов.
+// Токен следующей страницы содержит идентификатор последней метрики страницы.
+//
+// Параметры:
+//   - ctx: контекст для отмены;
+//   - req: запрос.
+func (s *GrpcServer) ListMetrics(
+	ctx context.Context,
+	req *proto.ListMetricsRequest) (*proto.ListMetricsResponse, error) {
+	size := req.GetPageSize()
+	switch {
+	case size < 0:
+		return nil, status.Errorf(codes.InvalidArgument, "uncorrect page size")
+	case size == 0:
+		size = defaultListPageSize
+	case size > maxListPageSize:
+		size = maxListPageSize
+	}
+	after, err := base64.RawURLEncoding.DecodeString(req.GetPageToken())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "uncorrect page token")
+	}
+
+	ms, err := s.service.List(ctx, service.MetricFilter{NamePrefix: req.GetNamePrefix(), MType: req.GetMtype()})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unespected error")
+	}
+
+	resp := &proto.ListMetricsResponse{}
+	for _, m := range ms {
+		if len(after) > 0 && m.Key() <= string(after) {
+			continue
+		}
+		if len(resp.Metrics) == int(size) {
+			last := resp.Metrics[len(resp.Metrics)-1]
+			lastKey := (&entity.Metrics{ID: last.GetId(), Labels: last.GetLabels()}).Key()
+			resp.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(lastKey))
+			break
+		}
+		resp.Metrics = append(resp.Metrics, getProtoFromMetric(m))
+	}
+
+	return resp, nil
+}
+
+// WatchMetrics передаёт изменения метрик, подходящих под фильтры, по мере их применения.
+// Заголовки ответа отправляются после оформления подписки: изменения,
+// применённые после их получения клиентом, гарантированно попадут в поток
+// (кроме пропущенных из-за медленного чтения, их количество передаётся в поле dropped;
+// если после пропуска изменений нет, оно передаётся отдельным ответом без метрики).
+//
+// Параметры:
+//   - req: запрос;
+//   - stream: поток изменений.
+func (s *GrpcServer) WatchMetrics(
+	req *proto.WatchMetricsRequest,
+	stream grpc.ServerStreamingServer[proto.WatchMetricsResponse]) error {
+	ctx := stream.Context()
+	filter := service.MetricFilter{NamePrefix: req.GetNamePrefix(), MType: req.GetMtype()}
+
+	events := s.service.Subscribe(ctx, filter)
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return fmt.Errorf("send stream header error: %w", err)
+	}
+
+	for e := range events {
+		resp := &proto.WatchMetricsResponse{Dropped: e.Dropped}
+		if e.Metric.ID != "" {
+			resp.Metric = getProtoFromMetric(e.Metric)
+		}
+		if err := stream.Send(resp); err != nil {
+			return fmt.Errorf("send metric change error: %w", err)
+		}
+	}
+	return nil
+}
+
+func getMetricsFromProto(protoMetrics []*proto.Metric) []entity.Metrics {
 	metrics := make([]entity.Metrics, 0, len(protoMetrics))
 
 	for i := range protoMetrics {
-		val := protoMetrics[i].GetValue()
-		del := protoMetrics[i].GetDelta()
 		pm := entity.Metrics{
-			ID:    protoMetrics[i].GetId(),
-			MType: protoMetrics[i].GetMtype(),
-			Value: &val,
-			Delta: &del,
+			ID:     protoMetrics[i].GetId(),
+			MType:  protoMetrics[i].GetMtype(),
+			Labels: protoMetrics[i].GetLabels(),
+		}
+		// незаполненные optional-поля остаются пустыми, чтобы некорректная метрика была отклонена
+		if protoMetrics[i].Value != nil {
+			val := protoMetrics[i].GetValue()
+			pm.Value = &val
+		}
+		if protoMetrics[i].Delta != nil {
+			del := protoMetrics[i].GetDelta()
+			pm.Delta = &del
+		}
+		if h := protoMetrics[i].GetHistogram(); h != nil {
+			pm.Histogram = &entity.HistogramData{
+				Bounds: h.GetBounds(),
+				Counts: h.GetCounts(),
+				Sum:    h.GetSum(),
+				Count:  h.GetCount(),
+			}
 		}
 		metrics = append(metrics, pm)
 	}
 
 	return metrics
 }
+
+// getProtoFromMetric преобразует метрику в proto, заполняя только значение её типа.
+func getProtoFromMetric(m entity.Metrics) *proto.Metric {
+	pm := &proto.Metric{
+		Id:     m.ID,
+		Mtype:  m.MType,
+		Labels: m.Labels,
+	}
+	switch m.MType {
+	case entity.Gauge:
+		pm.Value = m.Value
+	case entity.Counter:
+		pm.Delta = m.Delta
+		pm.Sources = m.Sources
+	case entity.Histogram:
+		if h := m.Histogram; h != nil {
+			pm.Histogram = &proto.Histogram{
+				Bounds: h.Bounds,
+				Counts: h.Counts,
+				Sum:    h.Sum,
+				Count:  h.Count,
+			}
+		}
+	default:
+		pm.Value = m.Value
+		pm.Delta = m.Delta
+	}
+	return pm
+}
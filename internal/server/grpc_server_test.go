@@ -0,0 +1,500 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/common"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	logger "github.com/Mr-Filatik/go-metrics-collector/internal/logger/zap/sugar"
+	repository "github.com/Mr-Filatik/go-metrics-collector/internal/repository/memory"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/server/idempotency"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/service"
+	"github.com/Mr-Filatik/go-metrics-collector/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+const testHashKey = "secret"
+
+// startTestGrpcServer запускает gRPC-сервер в памяти и возвращает клиент к нему.
+func startTestGrpcServer(t *testing.T, srv *GrpcServer) proto.MetricsServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	grpcServ := srv.newServer()
+	go func() {
+		_ = grpcServ.Serve(lis)
+	}()
+	t.Cleanup(grpcServ.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return proto.NewMetricsServiceClient(conn)
+}
+
+// signStreamMessage заполняет хэш сообщения потока так же, как это делает агент.
+func signStreamMessage(t *testing.T, req *proto.StreamMetricsRequest, key string) *proto.StreamMetricsRequest {
+	t.Helper()
+
+	data, err := protobuf.MarshalOptions{Deterministic: true}.Marshal(req)
+	require.NoError(t, err)
+	req.Hash, err = common.HashBytesToString(data, key)
+	require.NoError(t, err)
+	return req
+}
+
+func counterProto(name string, delta int64) *proto.Metric {
+	return &proto.Metric{Id: name, Mtype: entity.Counter, Delta: &delta, Labels: map[string]string{"a": "1", "b": "2"}}
+}
+
+func TestStreamMetrics(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	srvc := service.New(repository.New("", log), nil, 0, log)
+	client := startTestGrpcServer(t, &GrpcServer{
+		service:     srvc,
+		log:         log,
+		hashKey:     testHashKey,
+		idempotency: idempotency.New(time.Minute),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.StreamMetrics(ctx)
+	require.NoError(t, err)
+
+	messages := []*proto.StreamMetricsRequest{
+		{Sequence: 1, Metrics: []*proto.Metric{counterProto("PollCount", 2)}, IdempotencyKey: "k1"},
+		{Sequence: 2, Metrics: []*proto.Metric{counterProto("PollCount", 3)}, IdempotencyKey: "k2"},
+		// повтор пакета с тем же ключом подтверждается, но не применяется
+		{Sequence: 3, Metrics: []*proto.Metric{counterProto("PollCount", 3)}, IdempotencyKey: "k2"},
+		// пакет с конфликтом типа отклоняется в подтверждении, поток остаётся открытым
+		{Sequence: 4, Metrics: []*proto.Metric{{
+			Id:     "PollCount",
+			Mtype:  entity.Gauge,
+			Labels: map[string]string{"a": "1", "b": "2"},
+		}}},
+	}
+	for _, msg := range messages {
+		require.NoError(t, stream.Send(signStreamMessage(t, msg, testHashKey)))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, msg.GetSequence(), resp.GetSequence())
+		if msg.GetSequence() == 4 {
+			assert.NotEmpty(t, resp.GetError())
+		} else {
+			assert.Empty(t, resp.GetError())
+		}
+	}
+	require.NoError(t, stream.CloseSend())
+
+	key := (&entity.Metrics{ID: "PollCount", Labels: map[string]string{"a": "1", "b": "2"}}).Key()
+	m, err := srvc.Get(ctx, key, entity.Counter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), *m.Delta)
+}
+
+func TestStreamMetrics_BadHash(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	srvc := service.New(repository.New("", log), nil, 0, log)
+	client := startTestGrpcServer(t, &GrpcServer{service: srvc, log: log, hashKey: testHashKey})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.StreamMetrics(ctx)
+	require.NoError(t, err)
+
+	msg := &proto.StreamMetricsRequest{Sequence: 1, Metrics: []*proto.Metric{counterProto("PollCount", 1)}}
+	require.NoError(t, stream.Send(signStreamMessage(t, msg, "wrong key")))
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestStreamMetrics_TrustedSubnet(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	srvc := service.New(repository.New("", log), nil, 0, log)
+	client := startTestGrpcServer(t, &GrpcServer{
+		service:       srvc,
+		log:           log,
+		hashKey:       testHashKey,
+		trustedSubnet: "10.0.0.1",
+	})
+
+	tests := []struct {
+		name     string
+		realIP   string
+		expected codes.Code
+	}{
+		{name: "trusted", realIP: "10.0.0.1", expected: codes.OK},
+		{name: "not trusted", realIP: "10.0.0.2", expected: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-real-ip", tt.realIP)
+
+			stream, err := client.StreamMetrics(ctx)
+			require.NoError(t, err)
+			msg := &proto.StreamMetricsRequest{Sequence: 1, Metrics: []*proto.Metric{counterProto("PollCount", 1)}}
+			_ = stream.Send(signStreamMessage(t, msg, testHashKey))
+
+			_, err = stream.Recv()
+			assert.Equal(t, tt.expected, status.Code(err))
+		})
+	}
+}
+
+func TestGrpcUpdateMetrics_MissingValue(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	srvc := service.New(repository.New("", log), nil, 0, log)
+	client := startTestGrpcServer(t, &GrpcServer{service: srvc, log: log, hashKey: testHashKey})
+
+	tests := []struct {
+		metric *proto.Metric
+		name   string
+	}{
+		{name: "histogram without value", metric: &proto.Metric{Id: "Latency", Mtype: entity.Histogram}},
+		{
+			name:   "histogram with NaN",
+			metric: &proto.Metric{Id: "Latency", Mtype: entity.Histogram, Value: float64Ptr(math.NaN())},
+		},
+		{name: "gauge without value", metric: &proto.Metric{Id: "Alloc", Mtype: entity.Gauge}},
+		{name: "counter without delta", metric: &proto.Metric{Id: "PollCount", Mtype: entity.Counter}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &proto.UpdateMetricsRequest{Metrics: []*proto.Metric{tt.metric}}
+			_, err := client.UpdateMetrics(signedContext(t, context.Background(), req, testHashKey), req)
+			require.Error(t, err)
+
+			_, err = srvc.Get(context.Background(), tt.metric.GetId(), tt.metric.GetMtype())
+			assert.EqualError(t, err, service.MetricNotFound)
+		})
+	}
+}
+
+func TestGrpcUpdateMetrics_Sources(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	srvc := service.New(repository.New("", log), nil, 0, log)
+	client := startTestGrpcServer(t, &GrpcServer{service: srvc, log: log, hashKey: testHashKey})
+
+	updates := []struct {
+		key   string
+		value string
+		delta int64
+	}{
+		{key: "x-agent-id", value: "agent-1", delta: 1},
+		{key: "x-real-ip", value: "10.0.0.2", delta: 2},
+		{key: "x-agent-id", value: "agent-1", delta: 3},
+	}
+	for _, u := range updates {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), u.key, u.value)
+		req := &proto.UpdateMetricsRequest{Metrics: []*proto.Metric{
+			{Id: "PollCount", Mtype: entity.Counter, Delta: &u.delta, Sources: map[string]int64{"fake": 100}},
+		}}
+		_, err := client.UpdateMetrics(signedContext(t, ctx, req, testHashKey), req)
+		require.NoError(t, err)
+	}
+
+	get := &proto.GetMetricRequest{Id: "PollCount", Mtype: entity.Counter}
+	resp, err := client.GetMetric(signedContext(t, context.Background(), get, testHashKey), get)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), resp.GetMetric().GetDelta())
+	assert.Equal(t, map[string]int64{"agent-1": 4, "10.0.0.2": 2}, resp.GetMetric().GetSources())
+}
+
+// signedContext добавляет в метаданные хэш запроса так же, как это делает агент для унарных вызовов.
+func signedContext(t *testing.T, ctx context.Context, req protobuf.Message, key string) context.Context {
+	t.Helper()
+
+	data, err := protobuf.MarshalOptions{Deterministic: true}.Marshal(req)
+	require.NoError(t, err)
+	hash, err := common.HashBytesToString(data, key)
+	require.NoError(t, err)
+	return metadata.AppendToOutgoingContext(ctx, strings.ToLower(common.HeaderHashSHA256), hash)
+}
+
+// newReadTestServer создаёт сервис с набором метрик и запускает gRPC-сервер над ним.
+func newReadTestServer(t *testing.T, trustedSubnet string) (*service.Service, proto.MetricsServiceClient) {
+	t.Helper()
+
+	log := logger.New(logger.LevelInfo)
+	srvc := service.New(repository.New("", log), nil, 0, log)
+	for _, m := range []entity.Metrics{
+		{ID: "Alloc", MType: entity.Gauge, Value: float64Ptr(1.5)},
+		{ID: "Alloc", MType: entity.Gauge, Value: float64Ptr(2.5), Labels: map[string]string{"host": "b"}},
+		{ID: "CPUutilization", MType: entity.Gauge, Value: float64Ptr(10)},
+		{ID: "PollCount", MType: entity.Counter, Delta: int64Ptr(7)},
+		{ID: "PollErrors", MType: entity.Counter, Delta: int64Ptr(1)},
+	} {
+		_, err := srvc.CreateOrUpdate(context.Background(), m)
+		require.NoError(t, err)
+	}
+
+	client := startTestGrpcServer(t, &GrpcServer{
+		service:       srvc,
+		log:           log,
+		hashKey:       testHashKey,
+		trustedSubnet: trustedSubnet,
+	})
+	return srvc, client
+}
+
+func float64Ptr(v float64) *float64 { return &v }
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func TestGrpcGetMetric(t *testing.T) {
+	_, client := newReadTestServer(t, "")
+
+	tests := []struct {
+		req      *proto.GetMetricRequest
+		expected *proto.Metric
+		name     string
+		code     codes.Code
+	}{
+		{
+			name:     "gauge",
+			req:      &proto.GetMetricRequest{Id: "Alloc", Mtype: entity.Gauge},
+			expected: &proto.Metric{Id: "Alloc", Mtype: entity.Gauge, Value: float64Ptr(1.5)},
+			code:     codes.OK,
+		},
+		{
+			name: "gauge with labels",
+			req:  &proto.GetMetricRequest{Id: "Alloc", Mtype: entity.Gauge, Labels: map[string]string{"host": "b"}},
+			expected: &proto.Metric{
+				Id: "Alloc", Mtype: entity.Gauge, Value: float64Ptr(2.5), Labels: map[string]string{"host": "b"},
+			},
+			code: codes.OK,
+		},
+		{
+			name:     "counter",
+			req:      &proto.GetMetricRequest{Id: "PollCount", Mtype: entity.Counter},
+			expected: &proto.Metric{Id: "PollCount", Mtype: entity.Counter, Delta: int64Ptr(7)},
+			code:     codes.OK,
+		},
+		{
+			name: "not found",
+			req:  &proto.GetMetricRequest{Id: "Unknown", Mtype: entity.Gauge},
+			code: codes.NotFound,
+		},
+		{
+			name: "wrong type",
+			req:  &proto.GetMetricRequest{Id: "PollCount", Mtype: entity.Gauge},
+			code: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := signedContext(t, context.Background(), tt.req, testHashKey)
+			resp, err := client.GetMetric(ctx, tt.req)
+			assert.Equal(t, tt.code, status.Code(err))
+			if tt.code == codes.OK {
+				assert.True(t, protobuf.Equal(tt.expected, resp.GetMetric()), "got %v", resp.GetMetric())
+			}
+		})
+	}
+}
+
+func TestGrpcListMetrics(t *testing.T) {
+	_, client := newReadTestServer(t, "")
+
+	list := func(req *proto.ListMetricsRequest) (*proto.ListMetricsResponse, error) {
+		return client.ListMetrics(signedContext(t, context.Background(), req, testHashKey), req)
+	}
+	ids := func(resp *proto.ListMetricsResponse) []string {
+		res := make([]string, 0, len(resp.GetMetrics()))
+		for _, m := range resp.GetMetrics() {
+			res = append(res, (&entity.Metrics{ID: m.GetId(), Labels: m.GetLabels()}).Key())
+		}
+		return res
+	}
+	allIDs := func(base *proto.ListMetricsRequest) []string {
+		var res []string
+		req := protobuf.Clone(base).(*proto.ListMetricsRequest)
+		for {
+			resp, err := list(req)
+			require.NoError(t, err)
+			res = append(res, ids(resp)...)
+			if resp.GetNextPageToken() == "" {
+				return res
+			}
+			req.PageToken = resp.GetNextPageToken()
+		}
+	}
+	allocB := (&entity.Metrics{ID: "Alloc", Labels: map[string]string{"host": "b"}}).Key()
+
+	t.Run("all", func(t *testing.T) {
+		resp, err := list(&proto.ListMetricsRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Alloc", allocB, "CPUutilization", "PollCount", "PollErrors"}, ids(resp))
+		assert.Empty(t, resp.GetNextPageToken())
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		assert.Equal(t, []string{"PollCount", "PollErrors"}, allIDs(&proto.ListMetricsRequest{NamePrefix: "Poll"}))
+		assert.Equal(t, []string{"Alloc", allocB, "CPUutilization"}, allIDs(&proto.ListMetricsRequest{
+			Mtype: entity.Gauge,
+		}))
+		assert.Empty(t, allIDs(&proto.ListMetricsRequest{NamePrefix: "Poll", Mtype: entity.Gauge}))
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		resp, err := list(&proto.ListMetricsRequest{PageSize: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Alloc", allocB}, ids(resp))
+		assert.NotEmpty(t, resp.GetNextPageToken())
+
+		assert.Equal(t, []string{"Alloc", allocB, "CPUutilization", "PollCount", "PollErrors"},
+			allIDs(&proto.ListMetricsRequest{PageSize: 2}))
+		assert.Equal(t, []string{"PollCount", "PollErrors"},
+			allIDs(&proto.ListMetricsRequest{PageSize: 1, NamePrefix: "Poll"}))
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		_, err := list(&proto.ListMetricsRequest{PageSize: -1})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		_, err = list(&proto.ListMetricsRequest{PageToken: "!"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestGrpcWatchMetrics(t *testing.T) {
+	srvc, client := newReadTestServer(t, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req := &proto.WatchMetricsRequest{NamePrefix: "Poll", Mtype: entity.Counter}
+	stream, err := client.WatchMetrics(signedContext(t, ctx, req, testHashKey), req)
+	require.NoError(t, err)
+	// заголовки приходят после оформления подписки на сервере
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	for _, m := range []entity.Metrics{
+		{ID: "Alloc", MType: entity.Gauge, Value: float64Ptr(3)},
+		{ID: "PollCount", MType: entity.Counter, Delta: int64Ptr(1)},
+	} {
+		_, err := srvc.CreateOrUpdate(ctx, m)
+		require.NoError(t, err)
+	}
+	_, err = srvc.CreateOrUpdateBatch(ctx, []entity.Metrics{
+		{ID: "PollErrors", MType: entity.Counter, Delta: int64Ptr(2)},
+	})
+	require.NoError(t, err)
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "PollCount", resp.GetMetric().GetId())
+	assert.Equal(t, int64(8), resp.GetMetric().GetDelta())
+
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "PollErrors", resp.GetMetric().GetId())
+	assert.Equal(t, int64(3), resp.GetMetric().GetDelta())
+}
+
+func TestGrpcReadMetrics_Interceptors(t *testing.T) {
+	_, client := newReadTestServer(t, "10.0.0.1")
+
+	tests := []struct {
+		name     string
+		realIP   string
+		hashKey  string
+		expected codes.Code
+	}{
+		{name: "trusted", realIP: "10.0.0.1", hashKey: testHashKey, expected: codes.OK},
+		{name: "bad hash", realIP: "10.0.0.1", hashKey: "wrong key", expected: codes.PermissionDenied},
+		{name: "not trusted", realIP: "10.0.0.2", hashKey: testHashKey, expected: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-real-ip", tt.realIP)
+
+			getReq := &proto.GetMetricRequest{Id: "Alloc", Mtype: entity.Gauge}
+			_, err := client.GetMetric(signedContext(t, ctx, getReq, tt.hashKey), getReq)
+			assert.Equal(t, tt.expected, status.Code(err))
+
+			listReq := &proto.ListMetricsRequest{}
+			_, err = client.ListMetrics(signedContext(t, ctx, listReq, tt.hashKey), listReq)
+			assert.Equal(t, tt.expected, status.Code(err))
+
+			watchReq := &proto.WatchMetricsRequest{}
+			stream, err := client.WatchMetrics(signedContext(t, ctx, watchReq, tt.hashKey), watchReq)
+			require.NoError(t, err)
+			_, err = stream.Header()
+			if tt.expected == codes.OK {
+				assert.NoError(t, err)
+			} else {
+				_, err = stream.Recv()
+				assert.Equal(t, tt.expected, status.Code(err))
+			}
+		})
+	}
+}
+
+func TestIdempotency_HTTPThenGrpc(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	srvc := service.New(repository.New("", log), nil, 0, log)
+	store := idempotency.New(time.Minute)
+	httpServ := NewHTTPServer(context.Background(), &HTTPServerConfig{Service: srvc, Idempotency: store}, log)
+	client := startTestGrpcServer(t, &GrpcServer{service: srvc, log: log, hashKey: testHashKey, idempotency: store})
+
+	// пакет применён по HTTP, ответ агенту потерян
+	req := httptest.NewRequest(http.MethodPost, "/updates/",
+		strings.NewReader(`[{"id":"PollCount","type":"counter","delta":2}]`))
+	req.Header.Set(common.HeaderIdempotencyKey, "batch-1")
+	w := httptest.NewRecorder()
+	httpServ.Handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// повтор того же пакета уходит по gRPC: унарным вызовом и сообщением потока
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	delta := int64(2)
+	metrics := []*proto.Metric{{Id: "PollCount", Mtype: entity.Counter, Delta: &delta}}
+	update := &proto.UpdateMetricsRequest{Metrics: metrics}
+	keyCtx := metadata.AppendToOutgoingContext(ctx, "idempotency-key", "batch-1")
+	_, err := client.UpdateMetrics(signedContext(t, keyCtx, update, testHashKey), update)
+	require.NoError(t, err)
+
+	stream, err := client.StreamMetrics(ctx)
+	require.NoError(t, err)
+	msg := &proto.StreamMetricsRequest{Sequence: 1, Metrics: metrics, IdempotencyKey: "batch-1"}
+	require.NoError(t, stream.Send(signStreamMessage(t, msg, testHashKey)))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Empty(t, resp.GetError())
+	require.NoError(t, stream.CloseSend())
+
+	m, err := srvc.Get(ctx, "PollCount", entity.Counter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), *m.Delta, "batch retried over another protocol must be applied once")
+}
@@ -10,30 +10,42 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	_ "net/http/pprof"
 
+	"github.com/Mr-Filatik/go-metrics-collector/internal/common"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/server/idempotency"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/server/middleware"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/server/ui"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/service"
 	"github.com/go-chi/chi/v5"
 )
 
+// uiPath - путь встроенной HTML-панели.
+const uiPath = "/ui/"
+
 // HTTPServer представляет HTTP-сервер приложения.
 // Использует chi как маршрутизатор, service для бизнес-логики,
 // conveyor для обработки данных и logger для логирования.
 type HTTPServer struct {
-	router      *chi.Mux             // роутер
-	service     *service.Service     // сервис с основной логикой
-	conveyor    *middleware.Conveyor // конвейер для middleware
-	log         logger.Logger        // логгер
-	http.Server                      // сервер
+	router         *chi.Mux             // роутер
+	service        *service.Service     // сервис с основной логикой
+	conveyor       *middleware.Conveyor // конвейер для middleware
+	streamConveyor *middleware.Conveyor // конвейер для middleware потоковых ответов
+	log            logger.Logger        // логгер
+	shutdown       chan struct{}        // закрывается при остановке сервера, завершает потоковые ответы
+	http.Server                         // сервер
 }
 
 type HTTPServerConfig struct {
 	PrivateRsaKey *rsa.PrivateKey
 	Service       *service.Service
+	Idempotency   *idempotency.Store
 	Address       string
 	HashKey       string
 	TrustedSubnet string
@@ -53,12 +65,19 @@ func NewHTTPServer(ctx context.Context, conf *HTTPServerConfig, log logger.Logge
 				return ctx
 			},
 		},
-		router:   chi.NewRouter(),
-		service:  conf.Service,
-		conveyor: middleware.New(log),
-		log:      log,
-	}
-	srv.registerMiddlewares(conf.HashKey, conf.PrivateRsaKey, conf.TrustedSubnet)
+		router:         chi.NewRouter(),
+		service:        conf.Service,
+		conveyor:       middleware.New(log),
+		streamConveyor: middleware.New(log),
+		log:            log,
+		shutdown:       make(chan struct{}),
+	}
+	// Shutdown ждёт завершения активных запросов, поэтому потоковые ответы завершаются сразу.
+	var shutdownOnce sync.Once
+	srv.Server.RegisterOnShutdown(func() {
+		shutdownOnce.Do(func() { close(srv.shutdown) })
+	})
+	srv.registerMiddlewares(conf.HashKey, conf.PrivateRsaKey, conf.TrustedSubnet, conf.Idempotency)
 	srv.registerRoutes()
 
 	log.Info("HTTPServer create is successfull")
@@ -103,7 +122,12 @@ func (s *HTTPServer) Close() error {
 	return nil
 }
 
-func (s *HTTPServer) registerMiddlewares(hashKey string, privateKey *rsa.PrivateKey, ts string) {
+func (s *HTTPServer) registerMiddlewares(
+	hashKey string,
+	privateKey *rsa.PrivateKey,
+	ts string,
+	store *idempotency.Store,
+) {
 	ms := []middleware.Middleware{
 		func(h http.Handler) http.Handler {
 			return s.conveyor.WithLogging(h)
@@ -125,7 +149,16 @@ func (s *HTTPServer) registerMiddlewares(hashKey string, privateKey *rsa.Private
 		})
 	}
 
+	if store != nil {
+		ms = append(ms, func(h http.Handler) http.Handler {
+			return s.conveyor.WithIdempotency(h, store)
+		})
+	}
+
 	s.conveyor.RegisterMiddlewares(ms...)
+
+	// Потоковые ответы не буферизуются: проверка хэша и идемпотентность к ним неприменимы.
+	s.streamConveyor.RegisterMiddlewares(ms[0], ms[1])
 }
 
 func (s *HTTPServer) registerRoutes() {
@@ -137,7 +170,14 @@ func (s *HTTPServer) registerRoutes() {
 	s.router.Handle("/value/", s.conveyor.Middlewares(http.HandlerFunc(s.GetMetricJSON)))
 	s.router.Handle("/update/", s.conveyor.Middlewares(http.HandlerFunc(s.UpdateMetricJSON)))
 	s.router.Handle("/value/{type}/{name}", s.conveyor.Middlewares(http.HandlerFunc(s.GetMetric)))
+	// регистрируется после общего обработчика пути, чтобы заменить его для метода DELETE
+	s.router.Method(http.MethodDelete, "/value/{type}/{name}", s.conveyor.Middlewares(http.HandlerFunc(s.DeleteMetric)))
 	s.router.Handle("/update/{type}/{name}/{value}", s.conveyor.Middlewares(http.HandlerFunc(s.UpdateMetric)))
+	s.router.Handle("/history/{type}/{name}", s.conveyor.Middlewares(http.HandlerFunc(s.GetMetricHistory)))
+	s.router.Handle("/metrics", s.conveyor.Middlewares(http.HandlerFunc(s.GetMetricsPrometheus)))
+	s.router.Handle("/stream", s.streamConveyor.Middlewares(http.HandlerFunc(s.StreamMetrics)))
+	s.router.Handle("/ui", s.conveyor.Middlewares(http.RedirectHandler(uiPath, http.StatusMovedPermanently)))
+	s.router.Handle(uiPath+"*", s.conveyor.Middlewares(ui.Handler(uiPath)))
 
 	s.Handler = s.router
 }
@@ -178,6 +218,30 @@ func (s *HTTPServer) GetAllMetrics(w http.ResponseWriter, r *http.Request) {
 	s.serverResponceWithJSON(w, mArr)
 }
 
+// GetMetricsPrometheus выводит все метрики в текстовом формате Prometheus 0.0.4.
+//
+// Параметры:
+//   - w: ResponseWriter
+//   - r: запрос
+func (s *HTTPServer) GetMetricsPrometheus(w http.ResponseWriter, r *http.Request) {
+	ok := s.validateRequestMethod(w, r.Method, http.MethodGet)
+	if !ok {
+		return
+	}
+
+	mArr, err := s.service.GetAll(r.Context())
+	if err != nil {
+		s.serverResponceInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set(common.HeaderContentType, common.HeaderContentTypeValuePrometheus)
+	w.WriteHeader(http.StatusOK)
+	if wErr := writePrometheusMetrics(w, mArr); wErr != nil {
+		s.log.Error("Write prometheus metrics error", wErr)
+	}
+}
+
 // UpdateAllMetrics обновление всех метрик.
 //
 // Параметры:
@@ -195,16 +259,14 @@ func (s *HTTPServer) UpdateAllMetrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	for _, m := range metr {
-		_, err := s.service.CreateOrUpdate(r.Context(), m)
-		if err != nil {
-			if err.Error() == service.MetricNotFound || err.Error() == service.MetricUncorrect {
-				s.serverResponceBadRequest(w, err)
-				return
-			}
-			s.serverResponceInternalServerError(w, err)
+	_, err = s.service.CreateOrUpdateBatch(withRequestSource(r), metr)
+	if err != nil {
+		if err.Error() == service.MetricNotFound || err.Error() == service.MetricUncorrect {
+			s.serverResponceBadRequest(w, err)
 			return
 		}
+		s.serverResponceInternalServerError(w, err)
+		return
 	}
 }
 
@@ -246,11 +308,55 @@ func (s *HTTPServer) GetMetric(w http.ResponseWriter, r *http.Request) {
 	if m.MType == entity.Counter {
 		val = strconv.FormatInt(*m.Delta, 10)
 	}
+	if m.MType == entity.Histogram {
+		// для гистограммы текстовым значением считается количество наблюдений
+		val = strconv.FormatInt(m.Histogram.Count, 10)
+	}
 	if _, wErr := w.Write([]byte(val)); wErr != nil {
 		s.serverResponceInternalServerError(w, wErr)
 	}
 }
 
+// DeleteMetric удаление одной метрики.
+// Метки метрики передаются параметрами запроса label=key=value, параметр может повторяться
+// (по умолчанию удаляется метрика без меток).
+//
+// Параметры:
+//   - w: ResponseWriter
+//   - r: запрос
+func (s *HTTPServer) DeleteMetric(w http.ResponseWriter, r *http.Request) {
+	ok := s.validateRequestMethod(w, r.Method, http.MethodDelete)
+	if !ok {
+		return
+	}
+
+	metr, merr := getMetricFromRequest(r, false)
+	if merr != nil {
+		s.serverResponceBadRequest(w, merr)
+		return
+	}
+	metr.Labels, merr = getLabelsFromQuery(r)
+	if merr != nil {
+		s.serverResponceBadRequest(w, merr)
+		return
+	}
+
+	err := s.service.Remove(r.Context(), metr.Key(), metr.MType)
+	if err != nil {
+		if err.Error() == service.MetricNotFound {
+			s.serverResponceNotFound(w, err)
+			return
+		}
+		if err.Error() == service.MetricUncorrect {
+			s.serverResponceBadRequest(w, err)
+			return
+		}
+		s.serverResponceInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
 // GetMetricJSON получение одной метрики в формате JSON.
 //
 // Параметры:
@@ -268,7 +374,7 @@ func (s *HTTPServer) GetMetricJSON(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	m, err := s.service.Get(r.Context(), metr.ID, metr.MType)
+	m, err := s.service.Get(r.Context(), metr.Key(), metr.MType)
 	if err != nil {
 		if err.Error() == service.MetricNotFound {
 			s.serverResponceNotFound(w, err)
@@ -302,7 +408,7 @@ func (s *HTTPServer) UpdateMetric(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := s.service.CreateOrUpdate(r.Context(), metr)
+	_, err := s.service.CreateOrUpdate(withRequestSource(r), metr)
 	if err != nil {
 		if err.Error() == service.MetricNotFound || err.Error() == service.MetricUncorrect {
 			s.serverResponceBadRequest(w, err)
@@ -330,7 +436,7 @@ func (s *HTTPServer) UpdateMetricJSON(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	m, err := s.service.CreateOrUpdate(r.Context(), metr)
+	m, err := s.service.CreateOrUpdate(withRequestSource(r), metr)
 	if err != nil {
 		if err.Error() == service.MetricNotFound || err.Error() == service.MetricUncorrect {
 			s.serverResponceBadRequest(w, err)
@@ -343,17 +449,196 @@ func (s *HTTPServer) UpdateMetricJSON(w http.ResponseWriter, r *http.Request) {
 	s.serverResponceWithJSON(w, m)
 }
 
+// GetMetricHistory получение истории значений одной метрики.
+//
+// Параметры запроса (query):
+//   - from, to: границы интервала в формате RFC3339 (по умолчанию вся история до текущего момента)
+//   - step: шаг прореживания в формате time.Duration, например 1m (по умолчанию без прореживания,
+//     при прореживании параметр from обязателен)
+//   - agg: функция агрегации avg, min, max или last (по умолчанию avg)
+//   - label: метка метрики в формате key=value, может повторяться (по умолчанию метрика без меток)
+//
+// Параметры:
+//   - w: ResponseWriter
+//   - r: запрос
+func (s *HTTPServer) GetMetricHistory(w http.ResponseWriter, r *http.Request) {
+	ok := s.validateRequestMethod(w, r.Method, http.MethodGet)
+	if !ok {
+		return
+	}
+
+	metr, merr := getMetricFromRequest(r, false)
+	if merr != nil {
+		s.serverResponceBadRequest(w, merr)
+		return
+	}
+
+	query, qerr := getHistoryQueryFromRequest(r)
+	if qerr != nil {
+		s.serverResponceBadRequest(w, qerr)
+		return
+	}
+	metr.Labels, qerr = getLabelsFromQuery(r)
+	if qerr != nil {
+		s.serverResponceBadRequest(w, qerr)
+		return
+	}
+	query.ID = metr.Key()
+	query.MType = metr.MType
+
+	points, err := s.service.GetHistory(r.Context(), query)
+	if err != nil {
+		if err.Error() == service.MetricNotFound || err.Error() == service.HistoryDisabled {
+			s.serverResponceNotFound(w, err)
+			return
+		}
+		if err.Error() == service.MetricUncorrect || err.Error() == service.HistoryUncorrect {
+			s.serverResponceBadRequest(w, err)
+			return
+		}
+		s.serverResponceInternalServerError(w, err)
+		return
+	}
+
+	s.serverResponceWithJSON(w, points)
+}
+
+// StreamMetrics передаёт изменения метрик в формате Server-Sent Events по мере их применения.
+// Каждое изменение передаётся событием "metric" с метрикой в формате JSON. Если клиент не успевает
+// читать поток, часть изменений пропускается, и перед следующим изменением передаётся событие
+// "dropped" с количеством пропущенных изменений.
+//
+// Параметры запроса (query):
+//   - type: тип метрики (по умолчанию все типы)
+//   - name: шаблон имени метрики в формате path.Match, например Poll* (по умолчанию все метрики)
+//
+// Параметры:
+//   - w: ResponseWriter
+//   - r: запрос
+func (s *HTTPServer) StreamMetrics(w http.ResponseWriter, r *http.Request) {
+	ok := s.validateRequestMethod(w, r.Method, http.MethodGet)
+	if !ok {
+		return
+	}
+
+	filter := service.MetricFilter{
+		NamePattern: r.URL.Query().Get("name"),
+		MType:       r.URL.Query().Get("type"),
+	}
+	if filter.MType != "" && !isKnownMetricType(filter.MType) {
+		s.serverResponceBadRequest(w, errors.New("incorrect metric type"))
+		return
+	}
+	if err := filter.Validate(); err != nil {
+		s.serverResponceBadRequest(w, err)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	events := s.service.Subscribe(ctx, filter)
+
+	w.Header().Set(common.HeaderContentType, common.HeaderContentTypeValueEventStream)
+	w.Header().Set(common.HeaderCacheControl, common.HeaderCacheControlValueNoCache)
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		s.log.Error("Flush event stream error", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-s.shutdown:
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			err = writeSSEMetricEvent(w, e)
+		case <-heartbeat.C:
+			err = writeSSEHeartbeat(w)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			s.log.Warn("Write event stream error", err)
+			return
+		}
+	}
+}
+
+func getHistoryQueryFromRequest(r *http.Request) (service.HistoryQuery, error) {
+	params := r.URL.Query()
+	query := service.HistoryQuery{
+		To:          time.Now().UTC(),
+		Aggregation: service.AggregationAvg,
+	}
+
+	if val := params.Get("from"); val != "" {
+		from, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return query, errors.New("incorrect from value, expected RFC3339")
+		}
+		query.From = from
+	}
+	if val := params.Get("to"); val != "" {
+		to, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return query, errors.New("incorrect to value, expected RFC3339")
+		}
+		query.To = to
+	}
+	if val := params.Get("step"); val != "" {
+		step, err := time.ParseDuration(val)
+		if err != nil || step <= 0 {
+			return query, errors.New("incorrect step value, expected positive duration")
+		}
+		query.Step = step
+	}
+	if val := params.Get("agg"); val != "" {
+		query.Aggregation = val
+	}
+	if query.Step > 0 && query.From.IsZero() {
+		return query, errors.New("from value is required with step")
+	}
+
+	return query, nil
+}
+
+// getLabelsFromQuery возвращает метки метрики из параметров запроса вида label=key=value.
+func getLabelsFromQuery(r *http.Request) (map[string]string, error) {
+	vals := r.URL.Query()["label"]
+	if len(vals) == 0 {
+		return nil, nil
+	}
+
+	labels := make(map[string]string, len(vals))
+	for _, v := range vals {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, errors.New("incorrect label value, expected key=value")
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
 func getMetricFromRequest(r *http.Request, validateValue bool) (entity.Metrics, error) {
 	metr := entity.Metrics{
 		ID:    r.PathValue("name"),
 		MType: r.PathValue("type"),
 	}
-	if metr.MType != entity.Gauge && metr.MType != entity.Counter {
+	if !isKnownMetricType(metr.MType) {
 		return metr, errors.New("incorrect metric type")
 	}
 	if validateValue {
 		val := r.PathValue("value")
-		if metr.MType == entity.Gauge {
+		if metr.MType == entity.Gauge || metr.MType == entity.Histogram {
 			num, err := strconv.ParseFloat(val, 64)
 			if err != nil {
 				return metr, errors.New("incorrect metric value for float")
@@ -383,12 +668,14 @@ func getMetricsFromJSON(r *http.Request, validateValue bool) ([]entity.Metrics,
 		return make([]entity.Metrics, 0), errors.New(err.Error())
 	}
 
-	for _, m := range metr {
-		if m.MType != entity.Gauge && m.MType != entity.Counter {
+	for i, m := range metr {
+		if !isKnownMetricType(m.MType) {
 			return metr, errors.New("incorrect metric type")
 		}
+		// разбивка по источникам ведётся сервером и не принимается от клиента
+		metr[i].Sources = nil
 
-		if validateValue && m.Delta == nil && m.Value == nil {
+		if validateValue && m.Delta == nil && m.Value == nil && m.Histogram == nil {
 			return make([]entity.Metrics, 0), errors.New("invalid metric value or delta")
 		}
 	}
@@ -408,17 +695,23 @@ func getMetricFromJSON(r *http.Request, validateValue bool) (entity.Metrics, err
 		return entity.Metrics{}, errors.New(err.Error())
 	}
 
-	if metr.MType != entity.Gauge && metr.MType != entity.Counter {
+	if !isKnownMetricType(metr.MType) {
 		return metr, errors.New("incorrect metric type")
 	}
+	// разбивка по источникам ведётся сервером и не принимается от клиента
+	metr.Sources = nil
 
-	if validateValue && metr.Delta == nil && metr.Value == nil {
+	if validateValue && metr.Delta == nil && metr.Value == nil && metr.Histogram == nil {
 		return entity.Metrics{}, errors.New("invalid metric value or delta")
 	}
 
 	return metr, nil
 }
 
+func isKnownMetricType(t string) bool {
+	return t == entity.Gauge || t == entity.Counter || t == entity.Histogram
+}
+
 func (s *HTTPServer) validateRequestMethod(w http.ResponseWriter, current string, needed string) bool {
 	if current != needed {
 		s.log.Error(
@@ -0,0 +1,135 @@
+// Пакет idempotency предоставляет хранилище ключей идемпотентности,
+// позволяющее серверу не применять повторно уже обработанные запросы.
+package idempotency
+
+import (
+	"sync"
+	"time"
+)
+
+// State описывает состояние ключа идемпотентности.
+type State int
+
+// Константы - состояния ключа идемпотентности.
+const (
+	StateNew        State = iota // ключ встречен впервые, запрос нужно выполнить
+	StateInProgress              // запрос с этим ключом ещё выполняется
+	StateDone                    // запрос с этим ключом уже выполнен
+)
+
+// Константы - протоколы, в которых сохраняются ответы на запросы с ключами идемпотентности.
+const (
+	ProtocolHTTP = "http" // запросы HTTP-сервера
+	ProtocolGRPC = "grpc" // вызовы gRPC-сервера
+)
+
+// Scope возвращает область сохранённого ответа: протокол и операцию.
+// Повтор ключа не применяется независимо от области (агент может повторить пакет по другому протоколу),
+// но сохранённый ответ возвращается только в той же области, так как ответы разных операций несовместимы.
+//
+// Параметры:
+//   - protocol: протокол (ProtocolHTTP или ProtocolGRPC)
+//   - operation: операция (метод и путь HTTP-запроса или полное имя метода gRPC)
+func Scope(protocol, operation string) string {
+	return protocol + " " + operation
+}
+
+// entry описывает запись о ключе идемпотентности.
+type entry struct {
+	result    any       // сохранённый результат выполнения запроса
+	scope     string    // область, в которой получен результат
+	expiresAt time.Time // время, после которого ключ забывается
+	done      bool      // выполнен ли запрос
+}
+
+// Store хранит недавно встреченные ключи идемпотентности в течение окна window.
+type Store struct {
+	now       func() time.Time  // источник текущего времени
+	entries   map[string]*entry // записи по ключам
+	nextSweep time.Time         // время следующей очистки устаревших записей
+	window    time.Duration     // время хранения ключа
+	mu        sync.Mutex        // защита от конкурентного доступа
+}
+
+// New создаёт и инициализирует новый экзепляр *Store.
+//
+// Параметры:
+//   - window: время, в течение которого ключ считается повторным
+func New(window time.Duration) *Store {
+	return &Store{
+		now:     time.Now,
+		entries: make(map[string]*entry),
+		window:  window,
+	}
+}
+
+// Begin резервирует ключ перед выполнением запроса.
+// Для StateNew запрос нужно выполнить и затем вызвать Commit или Release,
+// для StateDone дополнительно возвращается сохранённый результат, если он получен в той же области
+// (для запроса, выполненного в другой области, результат - nil).
+//
+// Параметры:
+//   - key: ключ идемпотентности
+//   - scope: область запроса (см. Scope)
+func (s *Store) Begin(key, scope string) (State, any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	if e, ok := s.entries[key]; ok && now.Before(e.expiresAt) {
+		if e.done {
+			if e.scope != scope {
+				return StateDone, nil
+			}
+			return StateDone, e.result
+		}
+		return StateInProgress, nil
+	}
+
+	s.entries[key] = &entry{expiresAt: now.Add(s.window)}
+	return StateNew, nil
+}
+
+// Commit отмечает запрос с ключом как выполненный и сохраняет его результат.
+//
+// Параметры:
+//   - key: ключ идемпотентности
+//   - scope: область запроса (см. Scope)
+//   - result: результат выполнения запроса
+func (s *Store) Commit(key, scope string, result any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = &entry{
+		result:    result,
+		scope:     scope,
+		expiresAt: s.now().Add(s.window),
+		done:      true,
+	}
+}
+
+// Release освобождает ключ, если запрос не был выполнен, чтобы его можно было повторить.
+//
+// Параметры:
+//   - key: ключ идемпотентности
+func (s *Store) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+}
+
+// sweep удаляет устаревшие записи не чаще одного раза за окно.
+func (s *Store) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	for k, e := range s.entries {
+		if !now.Before(e.expiresAt) {
+			delete(s.entries, k)
+		}
+	}
+	s.nextSweep = now.Add(s.window)
+}
@@ -0,0 +1,71 @@
+package idempotency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New(time.Minute)
+	s.now = func() time.Time { return now }
+
+	state, _ := s.Begin("key1", "scope")
+	assert.Equal(t, StateNew, state)
+
+	state, _ = s.Begin("key1", "scope")
+	assert.Equal(t, StateInProgress, state)
+
+	s.Commit("key1", "scope", "result")
+	state, result := s.Begin("key1", "scope")
+	assert.Equal(t, StateDone, state)
+	assert.Equal(t, "result", result)
+
+	now = now.Add(2 * time.Minute)
+	state, _ = s.Begin("key1", "scope")
+	assert.Equal(t, StateNew, state, "key must expire after the window")
+}
+
+func TestStore_Release(t *testing.T) {
+	s := New(time.Minute)
+
+	state, _ := s.Begin("key1", "scope")
+	assert.Equal(t, StateNew, state)
+
+	s.Release("key1")
+	state, _ = s.Begin("key1", "scope")
+	assert.Equal(t, StateNew, state, "released key must be applied again")
+}
+
+func TestStore_OtherScope(t *testing.T) {
+	s := New(time.Minute)
+	httpScope := Scope(ProtocolHTTP, "POST /updates/")
+	grpcScope := Scope(ProtocolGRPC, "/metrics.MetricsService/UpdateMetrics")
+
+	state, _ := s.Begin("key1", httpScope)
+	assert.Equal(t, StateNew, state)
+	state, _ = s.Begin("key1", grpcScope)
+	assert.Equal(t, StateInProgress, state, "key in progress must block other scopes")
+
+	s.Commit("key1", httpScope, "result")
+	state, result := s.Begin("key1", grpcScope)
+	assert.Equal(t, StateDone, state, "key applied in another scope must not be applied again")
+	assert.Nil(t, result, "result of another scope must not be returned")
+}
+
+func TestStore_Sweep(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New(time.Minute)
+	s.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		s.Begin(key, "scope")
+		s.Commit(key, "scope", nil)
+	}
+
+	now = now.Add(2 * time.Minute)
+	s.Begin("d", "scope")
+	assert.Len(t, s.entries, 1)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 
 	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/server/idempotency"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/proto"
@@ -14,9 +15,10 @@ import (
 
 // Conveyor описывает сущность конвеера для регистрации intercepters.
 type Conveyor struct {
-	log           logger.Logger // логгер
-	trustedSubnet string        // разрешённые подсети
-	hashKey       string        // ключ хэширования
+	log           logger.Logger      // логгер
+	idempotency   *idempotency.Store // хранилище ключей идемпотентности (nil - проверка отключена)
+	trustedSubnet string             // разрешённые подсети
+	hashKey       string             // ключ хэширования
 }
 
 // New создаёт и инициализирует новый экзепляр *Conveyor.
@@ -24,28 +26,50 @@ type Conveyor struct {
 // Параметры:
 //   - ts: разрешённые подсети;
 //   - hashKey: ключ хэширования;
+//   - store: хранилище ключей идемпотентности;
 //   - l: логгер.
-func New(ts string, hashKey string, l logger.Logger) *Conveyor {
+func New(ts string, hashKey string, store *idempotency.Store, l logger.Logger) *Conveyor {
 	return &Conveyor{
 		log:           l,
+		idempotency:   store,
 		trustedSubnet: ts,
 		hashKey:       hashKey,
 	}
 }
 
 // getBodyFromRequest получение содержимого запроса в формате []byte.
+// Сериализация детерминированная (порядок ключей map фиксирован), чтобы хэш совпадал с хэшем клиента.
 func getBodyFromRequest(req interface{}) ([]byte, error) {
 	mes, ok := req.(proto.Message)
 	if !ok {
 		return nil, errors.New("message is not proto.Message")
 	}
-	val, err := proto.Marshal(mes)
+	val, err := proto.MarshalOptions{Deterministic: true}.Marshal(mes)
 	if err != nil {
 		return nil, fmt.Errorf("marshal proto message error: %w", err)
 	}
 	return val, nil
 }
 
+// wrappedStream - обёртка над потоком сервера, позволяющая проверять каждое принятое сообщение.
+type wrappedStream struct {
+	grpc.ServerStream
+	check    func(m interface{}) error // проверка принятого сообщения (nil - без проверки)
+	received int                       // количество принятых сообщений
+}
+
+// RecvMsg принимает сообщение потока и проверяет его.
+func (s *wrappedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err //nolint:wrapcheck // io.EOF и статус gRPC должны передаваться без изменений
+	}
+	s.received++
+	if s.check != nil {
+		return s.check(m)
+	}
+	return nil
+}
+
 // getStringFromContextMetadata получение значения из метаданных запроса по ключу.
 func getStringFromContextMetadata(ctx context.Context, key string) (string, bool) {
 	md, ok := metadata.FromIncomingContext(ctx)
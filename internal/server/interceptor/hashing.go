@@ -9,8 +9,13 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
+// hashFieldName - имя поля сообщения потока, содержащего хэш сообщения.
+const hashFieldName protoreflect.Name = "hash"
+
 // HashingInterceptor добавляет проверку заголовка hash.
 //
 // Параметры:
@@ -24,31 +29,105 @@ func (c *Conveyor) HashingInterceptor(
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler,
 ) (interface{}, error) {
+	if err := c.checkHeaderHash(ctx, req); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// checkHeaderHash проверяет хэш запроса из заголовка hash метаданных.
+func (c *Conveyor) checkHeaderHash(ctx context.Context, req interface{}) error {
 	// Получаем заголовок "hashsha256" из метаданных.
 	hash, ok := getStringFromContextMetadata(ctx, strings.ToLower(common.HeaderHashSHA256))
 	if !ok {
 		c.log.Error("Get string from context metadata error", errors.New("hashsha256 not exist"))
-		return nil, status.Errorf(codes.PermissionDenied, "hashsha256 not exist")
+		return status.Errorf(codes.PermissionDenied, "hashsha256 not exist")
 	}
 
 	// Получаем содержимое запроса.
 	body, err := getBodyFromRequest(req)
 	if err != nil {
 		c.log.Error("Get request body error", err)
-		return nil, status.Errorf(codes.InvalidArgument, "get request body error")
+		return status.Errorf(codes.InvalidArgument, "get request body error")
 	}
 
 	// Рассчитываем хэш на основе содержимого запроса и ключа хэширования.
 	calculatedHash, hashErr := common.HashBytesToString(body, c.hashKey)
 	if hashErr != nil {
 		c.log.Error("Create hash error", hashErr)
-		return nil, status.Errorf(codes.Internal, "create hash error")
+		return status.Errorf(codes.Internal, "create hash error")
 	}
 
 	if !common.HashValidateStrings(calculatedHash, hash) {
 		c.log.Error("Hashes not equals", errors.New("hashes not equals"))
-		return nil, status.Errorf(codes.PermissionDenied, "hashes not equals")
+		return status.Errorf(codes.PermissionDenied, "hashes not equals")
 	}
 
-	return handler(ctx, req)
+	return nil
+}
+
+// HashingStreamInterceptor добавляет проверку хэша каждого сообщения потока.
+// В потоках от клиента хэш передаётся в поле сообщения "hash" и рассчитывается по сообщению
+// с пустым полем "hash". В потоках только от сервера единственный запрос проверяется,
+// как в HashingInterceptor, по заголовку hash метаданных.
+// Сообщение с неверным хэшем завершает поток с кодом PermissionDenied.
+//
+// Параметры:
+//   - srv: реализация сервиса;
+//   - ss: поток сервера;
+//   - info: информация о потоковом вызове;
+//   - handler: следующий обработчик.
+func (c *Conveyor) HashingStreamInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	check := c.checkMessageHash
+	if !info.IsClientStream {
+		check = func(m interface{}) error {
+			return c.checkHeaderHash(ss.Context(), m)
+		}
+	}
+	return handler(srv, &wrappedStream{ServerStream: ss, check: check})
+}
+
+// checkMessageHash проверяет хэш из поля "hash" сообщения потока.
+func (c *Conveyor) checkMessageHash(m interface{}) error {
+	mes, ok := m.(proto.Message)
+	if !ok {
+		c.log.Error("Get message error", errors.New("message is not proto.Message"))
+		return status.Errorf(codes.InvalidArgument, "message is not proto.Message")
+	}
+
+	fields := mes.ProtoReflect()
+	fd := fields.Descriptor().Fields().ByName(hashFieldName)
+	if fd == nil || fd.Kind() != protoreflect.StringKind {
+		c.log.Error("Get message hash error", errors.New("hash field not exist"))
+		return status.Errorf(codes.InvalidArgument, "hash field not exist")
+	}
+	hash := fields.Get(fd).String()
+
+	// Хэш рассчитывается по сообщению без самого хэша.
+	unsigned := proto.Clone(mes)
+	unsigned.ProtoReflect().Clear(fd)
+	body, err := getBodyFromRequest(unsigned)
+	if err != nil {
+		c.log.Error("Get message body error", err)
+		return status.Errorf(codes.InvalidArgument, "get message body error")
+	}
+
+	calculatedHash, hashErr := common.HashBytesToString(body, c.hashKey)
+	if hashErr != nil {
+		c.log.Error("Create hash error", hashErr)
+		return status.Errorf(codes.Internal, "create hash error")
+	}
+
+	if !common.HashValidateStrings(calculatedHash, hash) {
+		c.log.Error("Hashes not equals", errors.New("hashes not equals"))
+		return status.Errorf(codes.PermissionDenied, "hashes not equals")
+	}
+
+	return nil
 }
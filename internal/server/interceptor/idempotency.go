@@ -0,0 +1,83 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/common"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/server/idempotency"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// IdempotencyInterceptor не применяет повторно запросы с уже обработанным ключом "idempotency-key"
+// из метаданных, а возвращает сохранённый ответ. Если ключ был применён другим протоколом
+// или методом, возвращается пустой ответ метода.
+//
+// Параметры:
+//   - ctx: контекст запроса;
+//   - req: запрос;
+//   - info: информация о сервере;
+//   - handler: следующий обработчик.
+func (c *Conveyor) IdempotencyInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	key, ok := getStringFromContextMetadata(ctx, strings.ToLower(common.HeaderIdempotencyKey))
+	if c.idempotency == nil || !ok || key == "" {
+		return handler(ctx, req)
+	}
+	scope := idempotency.Scope(idempotency.ProtocolGRPC, info.FullMethod)
+
+	state, result := c.idempotency.Begin(key, scope)
+	switch state {
+	case idempotency.StateInProgress:
+		c.log.Info("Request with idempotency key is in progress", "key", key)
+		return nil, status.Errorf(codes.Aborted, "request with this idempotency key is in progress")
+	case idempotency.StateDone:
+		c.log.Info("Duplicate request acknowledged", "key", key)
+		if result != nil {
+			return result, nil
+		}
+		resp, err := emptyResponse(info.FullMethod)
+		if err != nil {
+			c.log.Error("Create duplicate response error", err, "method", info.FullMethod)
+			return nil, status.Errorf(codes.Internal, "unespected error")
+		}
+		return resp, nil
+	case idempotency.StateNew:
+		// ключ встречен впервые, запрос выполняется ниже
+	}
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		c.idempotency.Release(key)
+		return nil, err
+	}
+	c.idempotency.Commit(key, scope, resp)
+	return resp, nil
+}
+
+// emptyResponse создаёт пустое сообщение ответа gRPC-метода по его полному имени (/пакет.Сервис/Метод).
+func emptyResponse(fullMethod string) (any, error) {
+	name := protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(fullMethod, "/"), "/", "."))
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("find method %s: %w", name, err)
+	}
+	method, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a method", name)
+	}
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(method.Output().FullName())
+	if err != nil {
+		return nil, fmt.Errorf("find response type of %s: %w", name, err)
+	}
+	return mt.New().Interface(), nil
+}
@@ -0,0 +1,122 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/server/idempotency"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/testutil"
+	"github.com/Mr-Filatik/go-metrics-collector/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testMethod = "/metrics.MetricsService/UpdateMetrics"
+
+// idempotentContext возвращает входящий контекст вызова с ключом идемпотентности в метаданных.
+func idempotentContext(key string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", key))
+}
+
+func TestIdempotencyInterceptor_Duplicate(t *testing.T) {
+	store := idempotency.New(time.Minute)
+	c := New("", "", store, &testutil.MockLogger{})
+	info := &grpc.UnaryServerInfo{FullMethod: testMethod}
+
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return "response", nil
+	}
+
+	for range 2 {
+		resp, err := c.IdempotencyInterceptor(idempotentContext("key1"), "request", info, handler)
+		require.NoError(t, err)
+		assert.Equal(t, "response", resp)
+	}
+	assert.Equal(t, 1, calls, "duplicate call must not be applied again")
+}
+
+func TestIdempotencyInterceptor_InProgress(t *testing.T) {
+	store := idempotency.New(time.Minute)
+	store.Begin("key1", idempotency.Scope(idempotency.ProtocolGRPC, testMethod))
+	c := New("", "", store, &testutil.MockLogger{})
+	info := &grpc.UnaryServerInfo{FullMethod: testMethod}
+
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return "response", nil
+	}
+
+	_, err := c.IdempotencyInterceptor(idempotentContext("key1"), "request", info, handler)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	assert.Equal(t, 0, calls)
+}
+
+func TestIdempotencyInterceptor_FailureReleasesKey(t *testing.T) {
+	store := idempotency.New(time.Minute)
+	c := New("", "", store, &testutil.MockLogger{})
+	info := &grpc.UnaryServerInfo{FullMethod: testMethod}
+
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("apply error")
+		}
+		return "response", nil
+	}
+
+	_, err := c.IdempotencyInterceptor(idempotentContext("key1"), "request", info, handler)
+	require.Error(t, err)
+
+	resp, err := c.IdempotencyInterceptor(idempotentContext("key1"), "request", info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "response", resp)
+	assert.Equal(t, 2, calls, "failed call must be applied again")
+}
+
+func TestIdempotencyInterceptor_AppliedByOtherProtocol(t *testing.T) {
+	store := idempotency.New(time.Minute)
+	// пакет с тем же ключом уже применён HTTP-запросом: вызов не применяется повторно,
+	// а сохранённый HTTP-ответ заменяется пустым ответом метода
+	store.Begin("key1", idempotency.Scope(idempotency.ProtocolHTTP, "POST /updates/"))
+	store.Commit("key1", idempotency.Scope(idempotency.ProtocolHTTP, "POST /updates/"), struct{}{})
+	c := New("", "", store, &testutil.MockLogger{})
+	info := &grpc.UnaryServerInfo{FullMethod: testMethod}
+
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return "response", nil
+	}
+
+	resp, err := c.IdempotencyInterceptor(idempotentContext("key1"), "request", info, handler)
+	require.NoError(t, err)
+	assert.IsType(t, &proto.UpdateMetricsResponse{}, resp)
+	assert.Equal(t, 0, calls, "key applied by another protocol must not be applied again")
+}
+
+func TestIdempotencyInterceptor_WithoutKey(t *testing.T) {
+	c := New("", "", idempotency.New(time.Minute), &testutil.MockLogger{})
+	info := &grpc.UnaryServerInfo{FullMethod: testMethod}
+
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return "response", nil
+	}
+
+	for range 2 {
+		_, err := c.IdempotencyInterceptor(context.Background(), "request", info, handler)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, calls, "calls without key must always be applied")
+}
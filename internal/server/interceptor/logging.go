@@ -10,6 +10,7 @@ import (
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -71,3 +72,58 @@ func (c *Conveyor) LoggingInterceptor(
 
 	return resp, err
 }
+
+// LoggingStreamInterceptor добавляет логирование потоковых вызовов в gRPC-сервер.
+// Запись в лог делается по завершении потока и содержит количество принятых сообщений.
+//
+// Параметры:
+//   - srv: реализация сервиса;
+//   - ss: поток сервера;
+//   - info: информация о потоковом вызове;
+//   - handler: следующий обработчик.
+func (c *Conveyor) LoggingStreamInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	startTime := time.Now().UTC()
+
+	// Получаем заголовок "x-request-id" из метаданных.
+	requestID, ok := getStringFromContextMetadata(ss.Context(), strings.ToLower(common.HeaderXRequestID))
+	if !ok {
+		requestID = uuid.New().String()
+		err := ss.SetHeader(metadata.Pairs(strings.ToLower(common.HeaderXRequestID), requestID))
+		if err != nil {
+			c.log.Error("Set string to context metadata error", errors.New(strings.ToLower(common.HeaderXRequestID)))
+			return status.Errorf(codes.Internal, "set string to context metadata error")
+		}
+	}
+
+	// Проверяем что заголовок "x-request-id" из метаданных корректный, является uuid.
+	_, err := uuid.Parse(requestID)
+	if err != nil {
+		c.log.Error("Parse x-request-id error", err)
+		return status.Errorf(codes.InvalidArgument, "parse x-request-id error")
+	}
+
+	ws := &wrappedStream{ServerStream: ss}
+	err = handler(srv, ws)
+
+	statusErr := errors.New("200 OK")
+	if err != nil {
+		statusErr = err
+	}
+
+	c.log.Info(
+		"gRPC-Stream",
+		"call_id", requestID,
+		"call_method", info.FullMethod,
+		"call_time", startTime.String(),
+		"call_duration", time.Since(startTime),
+		"status", statusErr.Error(),
+		"messages", ws.received,
+	)
+
+	return err
+}
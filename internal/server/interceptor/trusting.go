@@ -11,6 +11,30 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// checkTrusted проверяет, что заголовок "x-real-ip" из метаданных входит в разрешённые подсети.
+func (c *Conveyor) checkTrusted(ctx context.Context) error {
+	// Пропуск проверки, если разрешённые подсети не указаны.
+	if c.trustedSubnet == "" {
+		return nil
+	}
+
+	// Получение заголовка "x-real-ip" из метаданных.
+	realIP, ok := getStringFromContextMetadata(ctx, strings.ToLower(common.HeaderXRealIP))
+	if !ok {
+		c.log.Error("Get string from context metadata error", errors.New("x-real-ip not exist"))
+		return status.Errorf(codes.PermissionDenied, "x-real-ip not exist")
+	}
+
+	// Проверяем значение заголовка "x-real-ip" с разрешёнными адресами.
+	if realIP != c.trustedSubnet {
+		msg := strings.Join([]string{"subnet", realIP, "not trusted"}, " ")
+		c.log.Error("Subnet not trusted", errors.New(msg))
+		return status.Errorf(codes.PermissionDenied, "subnet not trusted")
+	}
+
+	return nil
+}
+
 // TrustingInterceptor добавляет ограничения доступа для неразрешённых подсетей в gRPC-сервер.
 //
 // Параметры:
@@ -24,24 +48,30 @@ func (c *Conveyor) TrustingInterceptor(
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler,
 ) (interface{}, error) {
-	// Пропуск проверки, если разрешённые подсети не указаны.
-	if c.trustedSubnet == "" {
-		return handler(ctx, req)
+	if err := c.checkTrusted(ctx); err != nil {
+		return nil, err
 	}
 
-	// Получение заголовка "x-real-ip" из метаданных.
-	realIP, ok := getStringFromContextMetadata(ctx, strings.ToLower(common.HeaderXRealIP))
-	if !ok {
-		c.log.Error("Get string from context metadata error", errors.New("x-real-ip not exist"))
-		return nil, status.Errorf(codes.PermissionDenied, "x-real-ip not exist")
-	}
+	return handler(ctx, req)
+}
 
-	// Проверяем значение заголовка "x-real-ip" с разрешёнными адресами.
-	if realIP != c.trustedSubnet {
-		msg := strings.Join([]string{"subnet", realIP, "not trusted"}, " ")
-		c.log.Error("Subnet not trusted", errors.New(msg))
-		return nil, status.Errorf(codes.PermissionDenied, "subnet not trusted")
+// TrustingStreamInterceptor добавляет ограничения доступа для неразрешённых подсетей
+// в потоковые вызовы gRPC-сервера. Проверка выполняется один раз при открытии потока.
+//
+// Параметры:
+//   - srv: реализация сервиса;
+//   - ss: поток сервера;
+//   - info: информация о потоковом вызове;
+//   - handler: следующий обработчик.
+func (c *Conveyor) TrustingStreamInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if err := c.checkTrusted(ss.Context()); err != nil {
+		return err
 	}
 
-	return handler(ctx, req)
+	return handler(srv, ss)
 }
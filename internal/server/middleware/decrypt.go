@@ -32,6 +32,13 @@ func (c *Conveyor) WithDecryption(next http.Handler, key *rsa.PrivateKey) http.H
 			}
 		}()
 
+		// Запросы без тела (например, GET) не шифруются.
+		if len(encryptedBody) == 0 {
+			r.Body = http.NoBody
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		decryptedBody, err := crypto.DecryptBig(encryptedBody, key)
 		if err != nil {
 			c.log.Error("Decryption failed", err)
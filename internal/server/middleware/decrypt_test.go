@@ -75,3 +75,26 @@ func TestWithDecryption_NoKey(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, body, capturedBody)
 }
+
+func TestWithDecryption_EmptyBody(t *testing.T) {
+	mockLog := &testutil.MockLogger{}
+	conveyor := New(mockLog)
+
+	privateKey, _ := generateTestKeys(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler := conveyor.WithDecryption(next, privateKey)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, called)
+}
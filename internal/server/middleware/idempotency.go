@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/common"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/server/idempotency"
+)
+
+// idempotentResponse - сохранённый ответ на запрос с ключом идемпотентности.
+type idempotentResponse struct {
+	header http.Header // заголовки ответа
+	body   []byte      // тело ответа
+	status int         // код ответа
+}
+
+// idempotentWriter запоминает ответ обработчика, одновременно передавая его клиенту.
+type idempotentWriter struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (w *idempotentWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *idempotentWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	num, err := w.ResponseWriter.Write(b)
+	if err != nil {
+		return num, errors.New(err.Error())
+	}
+	return num, nil
+}
+
+// WithIdempotency создает middleware, которое не применяет повторно POST-запросы
+// с уже обработанным заголовком "Idempotency-Key", а возвращает сохранённый ответ
+// (200 OK без тела, если ключ был применён другим протоколом или операцией).
+// Если запрос с таким ключом ещё выполняется, возвращается 409 Conflict.
+//
+// Параметры:
+//   - next: следующий обработчик
+//   - store: хранилище ключей идемпотентности (nil - проверка отключена)
+func (c *Conveyor) WithIdempotency(next http.Handler, store *idempotency.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(common.HeaderIdempotencyKey)
+		if store == nil || key == "" || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		scope := idempotency.Scope(idempotency.ProtocolHTTP, r.Method+" "+r.URL.Path)
+
+		state, result := store.Begin(key, scope)
+		switch state {
+		case idempotency.StateInProgress:
+			c.log.Info("Request with idempotency key is in progress", "key", key)
+			http.Error(w, "request with this idempotency key is in progress", http.StatusConflict)
+			return
+		case idempotency.StateDone:
+			c.log.Info("Duplicate request acknowledged", "key", key)
+			resp, ok := result.(*idempotentResponse)
+			if !ok {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			for k, v := range resp.header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(resp.status)
+			if _, err := w.Write(resp.body); err != nil {
+				c.log.Error("Write idempotent response error", err)
+			}
+			return
+		case idempotency.StateNew:
+			// ключ встречен впервые, запрос выполняется ниже
+		}
+
+		iw := &idempotentWriter{ResponseWriter: w}
+		next.ServeHTTP(iw, r)
+
+		if iw.status == 0 {
+			iw.status = http.StatusOK
+		}
+		if iw.status >= http.StatusBadRequest {
+			store.Release(key)
+			return
+		}
+		store.Commit(key, scope, &idempotentResponse{
+			header: w.Header().Clone(),
+			body:   iw.body.Bytes(),
+			status: iw.status,
+		})
+	})
+}
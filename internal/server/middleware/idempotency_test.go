@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/common"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/server/idempotency"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithIdempotency_Duplicate(t *testing.T) {
+	conveyor := New(&testutil.MockLogger{})
+	store := idempotency.New(time.Minute)
+
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set(common.HeaderContentType, common.HeaderContentTypeValueApplicationJSON)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`[{"id":"c","type":"counter","delta":1}]`))
+	})
+	handler := conveyor.WithIdempotency(next, store)
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", http.NoBody)
+		req.Header.Set(common.HeaderIdempotencyKey, "key1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, common.HeaderContentTypeValueApplicationJSON, rec.Header().Get(common.HeaderContentType))
+		assert.Equal(t, `[{"id":"c","type":"counter","delta":1}]`, rec.Body.String())
+	}
+	assert.Equal(t, 1, calls, "duplicate request must not be applied again")
+}
+
+func TestWithIdempotency_InProgress(t *testing.T) {
+	conveyor := New(&testutil.MockLogger{})
+	store := idempotency.New(time.Minute)
+	store.Begin("key1", idempotency.Scope(idempotency.ProtocolHTTP, "POST /updates/"))
+
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+	handler := conveyor.WithIdempotency(next, store)
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", http.NoBody)
+	req.Header.Set(common.HeaderIdempotencyKey, "key1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, 0, calls)
+}
+
+func TestWithIdempotency_ErrorReleasesKey(t *testing.T) {
+	conveyor := New(&testutil.MockLogger{})
+	store := idempotency.New(time.Minute)
+
+	status := http.StatusInternalServerError
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	})
+	handler := conveyor.WithIdempotency(next, store)
+
+	codes := []int{}
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", http.NoBody)
+		req.Header.Set(common.HeaderIdempotencyKey, "key1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+		status = http.StatusOK
+	}
+
+	assert.Equal(t, []int{http.StatusInternalServerError, http.StatusOK}, codes)
+	assert.Equal(t, 2, calls, "failed request must be applied again")
+}
+
+func TestWithIdempotency_NoKey(t *testing.T) {
+	conveyor := New(&testutil.MockLogger{})
+	store := idempotency.New(time.Minute)
+
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+	handler := conveyor.WithIdempotency(next, store)
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", http.NoBody)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestWithIdempotency_AppliedByOtherProtocol(t *testing.T) {
+	conveyor := New(&testutil.MockLogger{})
+	store := idempotency.New(time.Minute)
+	// пакет с тем же ключом уже применён вызовом gRPC
+	grpcScope := idempotency.Scope(idempotency.ProtocolGRPC, "/metrics.MetricsService/UpdateMetrics")
+	store.Begin("key1", grpcScope)
+	store.Commit("key1", grpcScope, nil)
+
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+	handler := conveyor.WithIdempotency(next, store)
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", http.NoBody)
+	req.Header.Set(common.HeaderIdempotencyKey, "key1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, 0, calls, "key applied by another protocol must not be applied again")
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+)
+
+// Константы - приведение имён, конфликтующих в выводе Prometheus.
+const (
+	prometheusExportedPrefix = "exported_" // префикс имени, занятого другой группой или меткой
+	prometheusLabelLe        = "le"        // метка верхней границы корзины гистограммы
+)
+
+// prometheusHistogramSuffixes - суффиксы рядов, которые выводятся для гистограммы.
+var prometheusHistogramSuffixes = []string{"_bucket", "_sum", "_count"}
+
+// writePrometheusMetrics выводит метрики в текстовом формате Prometheus 0.0.4.
+// Метрики группируются по имени, приведённому к допустимому виду, для каждой группы выводится
+// одна строка # TYPE. Тип группы задаёт её первая метрика, метрики другого типа с тем же именем
+// пропускаются, так как в одной группе Prometheus допускается только один тип.
+// Группа, имя которой совпадает с рядом гистограммы (X_bucket, X_sum, X_count для гистограммы X),
+// выводится с префиксом exported_, а повторные ряды с теми же именем и метками пропускаются:
+// Prometheus отклоняет весь ответ при любом конфликте рядов.
+//
+// Параметры:
+//   - w: получатель данных
+//   - metrics: метрики
+func writePrometheusMetrics(w io.Writer, metrics []entity.Metrics) error {
+	type namedMetric struct {
+		m     *entity.Metrics
+		name  string
+		key   string
+		value string
+	}
+	sorted := make([]namedMetric, 0, len(metrics))
+	for i := range metrics {
+		m := &metrics[i]
+
+		var value string
+		switch {
+		case m.MType == entity.Gauge && m.Value != nil:
+			value = strconv.FormatFloat(*m.Value, 'g', -1, 64)
+		case m.MType == entity.Counter && m.Delta != nil:
+			value = strconv.FormatInt(*m.Delta, 10)
+		case m.MType == entity.Histogram && m.Histogram != nil:
+			// значения гистограммы выводятся отдельно в writePrometheusHistogram
+		default:
+			continue
+		}
+		sorted = append(sorted, namedMetric{m: m, name: sanitizePrometheusName(m.ID), key: m.Key(), value: value})
+	}
+	sortByName := func() {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if sorted[i].name != sorted[j].name {
+				return sorted[i].name < sorted[j].name
+			}
+			return sorted[i].key < sorted[j].key
+		})
+	}
+	sortByName()
+
+	typed := make(map[string]string, len(sorted))
+	for _, nm := range sorted {
+		if _, ok := typed[nm.name]; !ok {
+			typed[nm.name] = nm.m.MType
+		}
+	}
+	renamed := renamePrometheusFamilies(typed)
+	for i := range sorted {
+		sorted[i].name = renamed[sorted[i].name]
+	}
+	sortByName()
+
+	bw := bufio.NewWriter(w)
+	written := make(map[string]struct{}, len(sorted))
+	family := ""
+	familyType := ""
+	for _, nm := range sorted {
+		m, name := nm.m, nm.name
+
+		if name != family {
+			family, familyType = name, m.MType
+			if _, err := fmt.Fprintf(bw, "# TYPE %s %s\n", name, m.MType); err != nil {
+				return fmt.Errorf("write metric type error: %w", err)
+			}
+		}
+		if m.MType != familyType {
+			continue
+		}
+
+		var labels []prometheusLabel
+		if m.MType == entity.Histogram {
+			labels = prometheusLabels(m.Labels, prometheusLabelLe)
+		} else {
+			labels = prometheusLabels(m.Labels)
+		}
+		series := name + formatPrometheusLabels(labels)
+		if _, ok := written[series]; ok {
+			continue
+		}
+		written[series] = struct{}{}
+
+		if m.MType == entity.Histogram {
+			if err := writePrometheusHistogram(bw, name, labels, m.Histogram); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(bw, "%s %s\n", series, nm.value); err != nil {
+			return fmt.Errorf("write metric value error: %w", err)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("flush metrics error: %w", err)
+	}
+	return nil
+}
+
+// renamePrometheusFamilies возвращает итоговые имена групп метрик по их типам.
+// Имя, совпадающее с рядом гистограммы, получает префикс exported_ (повторно, пока имя занято).
+func renamePrometheusFamilies(typed map[string]string) map[string]string {
+	reserved := make(map[string]struct{}, len(typed))
+	for name, mtype := range typed {
+		if mtype != entity.Histogram {
+			continue
+		}
+		for _, suffix := range prometheusHistogramSuffixes {
+			reserved[name+suffix] = struct{}{}
+		}
+	}
+
+	renamed := make(map[string]string, len(typed))
+	for name := range typed {
+		renamed[name] = name
+		if _, ok := reserved[name]; !ok {
+			continue
+		}
+		newName := prometheusExportedPrefix + name
+		for {
+			_, isFamily := typed[newName]
+			_, isReserved := reserved[newName]
+			if !isFamily && !isReserved {
+				break
+			}
+			newName = prometheusExportedPrefix + newName
+		}
+		renamed[name] = newName
+	}
+	return renamed
+}
+
+// writePrometheusHistogram выводит гистограмму в виде накопительных корзин name_bucket{le="..."},
+// а также суммы name_sum и количества наблюдений name_count.
+func writePrometheusHistogram(w io.Writer, name string, labels []prometheusLabel, h *entity.HistogramData) error {
+	bucketLabels := make([]prometheusLabel, len(labels), len(labels)+1)
+	copy(bucketLabels, labels)
+	bucketLabels = append(bucketLabels, prometheusLabel{name: prometheusLabelLe})
+
+	var cumulative int64
+	for i, c := range h.Counts {
+		cumulative += c
+		le := "+Inf"
+		if i < len(h.Bounds) {
+			le = strconv.FormatFloat(h.Bounds[i], 'g', -1, 64)
+		}
+		bucketLabels[len(bucketLabels)-1].value = le
+		_, err := fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatPrometheusLabels(bucketLabels), cumulative)
+		if err != nil {
+			return fmt.Errorf("write metric value error: %w", err)
+		}
+	}
+
+	lbls := formatPrometheusLabels(labels)
+	sum := strconv.FormatFloat(h.Sum, 'g', -1, 64)
+	if _, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", name, lbls, sum, name, lbls, h.Count); err != nil {
+		return fmt.Errorf("write metric value error: %w", err)
+	}
+	return nil
+}
+
+// prometheusLabel - метка с именем, приведённым к допустимому виду.
+type prometheusLabel struct {
+	name  string
+	value string
+}
+
+// prometheusLabels приводит ключи меток к допустимому виду и сортирует их.
+// Допустимые ключи сохраняются как есть, а ключ, совпавший после приведения с другим ключом
+// или с зарезервированным именем (например, le у гистограммы), получает префикс exported_.
+func prometheusLabels(labels map[string]string, reserved ...string) []prometheusLabel {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	used := make(map[string]struct{}, len(labels)+len(reserved))
+	for _, name := range reserved {
+		used[name] = struct{}{}
+	}
+	res := make([]prometheusLabel, 0, len(labels))
+	pending := make([]string, 0)
+	for _, k := range keys {
+		name := sanitizePrometheusLabelName(k)
+		if _, ok := used[name]; ok || name != k {
+			pending = append(pending, k)
+			continue
+		}
+		used[name] = struct{}{}
+		res = append(res, prometheusLabel{name: name, value: labels[k]})
+	}
+	for _, k := range pending {
+		name := sanitizePrometheusLabelName(k)
+		for {
+			if _, ok := used[name]; !ok {
+				break
+			}
+			name = prometheusExportedPrefix + name
+		}
+		used[name] = struct{}{}
+		res = append(res, prometheusLabel{name: name, value: labels[k]})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res
+}
+
+// formatPrometheusLabels формирует набор меток вида {key1="value1",key2="value2"}, экранируя значения.
+func formatPrometheusLabels(labels []prometheusLabel) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(l.name)
+		sb.WriteString(`="`)
+		sb.WriteString(prometheusLabelEscaper.Replace(l.value))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// sanitizePrometheusLabelName приводит ключ метки к виду [a-zA-Z_][a-zA-Z0-9_]*.
+func sanitizePrometheusLabelName(name string) string {
+	return strings.ReplaceAll(sanitizePrometheusName(name), ":", "_")
+}
+
+// prometheusLabelEscaper экранирует значения меток согласно текстовому формату Prometheus.
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// sanitizePrometheusName приводит имя метрики к виду [a-zA-Z_:][a-zA-Z0-9_:]*,
+// заменяя недопустимые символы на подчёркивание.
+func sanitizePrometheusName(name string) string {
+	if name == "" {
+		return "_"
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(name) + 1)
+	for i, r := range name {
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' || r == ':'
+		isDigit := r >= '0' && r <= '9'
+		switch {
+		case isLetter:
+			sb.WriteRune(r)
+		case isDigit && i == 0:
+			sb.WriteByte('_')
+			sb.WriteRune(r)
+		case isDigit:
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
@@ -1,11 +1,20 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Mr-Filatik/go-metrics-collector/internal/common"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
 	logger "github.com/Mr-Filatik/go-metrics-collector/internal/logger/zap/sugar"
 	repository "github.com/Mr-Filatik/go-metrics-collector/internal/repository/memory"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/service"
@@ -118,6 +127,130 @@ func TestGetMetric(t *testing.T) {
 	}
 }
 
+func TestDeleteMetric(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		parameters     map[string]string
+		query          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Invalid request method",
+			method:         http.MethodGet,
+			parameters:     map[string]string{"type": "gauge", "name": "testGauge"},
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedBody:   "Invalid request method\n",
+		},
+		{
+			name:           "Invalid metric type",
+			method:         http.MethodDelete,
+			parameters:     map[string]string{"type": "abracadabra", "name": "testGauge"},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Error: incorrect metric type\n",
+		},
+		{
+			name:           "Mismatched metric type",
+			method:         http.MethodDelete,
+			parameters:     map[string]string{"type": "counter", "name": "testGauge"},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Error: invalid metric\n",
+		},
+		{
+			name:           "Invalid label",
+			method:         http.MethodDelete,
+			parameters:     map[string]string{"type": "gauge", "name": "testGauge"},
+			query:          "?label=host",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Error: incorrect label value, expected key=value\n",
+		},
+		{
+			name:           "Delete labeled gauge",
+			method:         http.MethodDelete,
+			parameters:     map[string]string{"type": "gauge", "name": "testGauge"},
+			query:          "?label=host=a",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "",
+		},
+		{
+			name:           "Delete removed labeled gauge",
+			method:         http.MethodDelete,
+			parameters:     map[string]string{"type": "gauge", "name": "testGauge"},
+			query:          "?label=host=a",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Error: metric not found\n",
+		},
+		{
+			name:           "Delete gauge",
+			method:         http.MethodDelete,
+			parameters:     map[string]string{"type": "gauge", "name": "testGauge"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "",
+		},
+		{
+			name:           "Delete removed gauge",
+			method:         http.MethodDelete,
+			parameters:     map[string]string{"type": "gauge", "name": "testGauge"},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Error: metric not found\n",
+		},
+	}
+
+	log := logger.New(logger.LevelInfo)
+	repo := repository.New("", log)
+	stor := service.New(repo, nil, 0, log)
+	serv := &HTTPServer{
+		service: stor,
+		log:     log,
+	}
+	value := 1.5
+	_, err := stor.CreateOrUpdate(context.Background(),
+		entity.Metrics{ID: "testGauge", MType: entity.Gauge, Value: &value})
+	require.NoError(t, err)
+	_, err = stor.CreateOrUpdate(context.Background(),
+		entity.Metrics{ID: "testGauge", MType: entity.Gauge, Value: &value, Labels: map[string]string{"host": "a"}})
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/value/" + tt.parameters["type"] + "/" + tt.parameters["name"] + tt.query
+			req := httptest.NewRequest(tt.method, path, http.NoBody)
+			req.SetPathValue("type", tt.parameters["type"])
+			req.SetPathValue("name", tt.parameters["name"])
+			w := httptest.NewRecorder()
+
+			serv.DeleteMetric(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestDeleteMetric_Routes(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	srvc := service.New(repository.New("", log), nil, 0, log)
+	serv := NewHTTPServer(context.Background(), &HTTPServerConfig{Service: srvc}, log)
+	value := 1.5
+	_, err := srvc.CreateOrUpdate(context.Background(), entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: &value})
+	require.NoError(t, err)
+
+	serve := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		serv.Handler.ServeHTTP(w, httptest.NewRequest(method, "/value/gauge/Alloc", http.NoBody))
+		return w
+	}
+
+	w := serve(http.MethodGet)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "1.5", w.Body.String())
+
+	require.Equal(t, http.StatusOK, serve(http.MethodDelete).Code)
+	require.Equal(t, http.StatusNotFound, serve(http.MethodGet).Code)
+	require.Equal(t, http.StatusNotFound, serve(http.MethodDelete).Code)
+}
+
 func TestGetMetricJSON(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -202,6 +335,22 @@ func TestUpdateMetric(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			expectedBody:   "",
 		},
+		{
+			name:           "Valid histogram observation",
+			method:         http.MethodPost,
+			path:           "/update/histogram/testHistogram/0.3",
+			parameters:     map[string]string{"type": "histogram", "name": "testHistogram", "value": "0.3"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "",
+		},
+		{
+			name:           "Invalid histogram observation",
+			method:         http.MethodPost,
+			path:           "/update/histogram/testHistogram/abc",
+			parameters:     map[string]string{"type": "histogram", "name": "testHistogram", "value": "abc"},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Error: incorrect metric value for float\n",
+		},
 		{
 			name:           "Invalid metric type",
 			method:         http.MethodPost,
@@ -236,3 +385,480 @@ func TestUpdateMetric(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateAllMetrics_Atomic(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	repo := repository.New("", log)
+	srvc := service.New(repo, nil, 0, log)
+	serv := &HTTPServer{
+		service: srvc,
+		log:     log,
+	}
+
+	ctx := context.Background()
+	value := 1.0
+	_, err := srvc.CreateOrUpdate(ctx, entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: &value})
+	require.NoError(t, err)
+
+	body := `[{"id":"PollCount","type":"counter","delta":5},{"id":"Alloc","type":"counter","delta":1}]`
+	req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	serv.UpdateAllMetrics(w, req)
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	_, err = srvc.Get(ctx, "PollCount", entity.Counter)
+	require.EqualError(t, err, service.MetricNotFound)
+}
+
+func TestUpdateMetric_Sources(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	srvc := service.New(repository.New("", log), nil, 0, log)
+	serv := &HTTPServer{
+		service: srvc,
+		log:     log,
+	}
+
+	send := func(header, value, body string) {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+		req.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		serv.UpdateAllMetrics(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	send(common.HeaderXAgentID, "agent-1", `[{"id":"PollCount","type":"counter","delta":1,"sources":{"fake":100}}]`)
+	send(common.HeaderXRealIP, "10.0.0.2", `[{"id":"PollCount","type":"counter","delta":2}]`)
+	send(common.HeaderXAgentID, "agent-1", `[{"id":"PollCount","type":"counter","delta":3}]`)
+
+	w := httptest.NewRecorder()
+	serv.GetAllMetrics(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t,
+		`[{"id":"PollCount","type":"counter","delta":6,"sources":{"agent-1":4,"10.0.0.2":2}}]`,
+		w.Body.String())
+}
+
+func TestGetMetricHistory(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	repo := repository.New("", log)
+	srvc := service.New(repo, nil, 0, log).SetHistory(repo)
+	serv := &HTTPServer{
+		service: srvc,
+		log:     log,
+	}
+
+	from := time.Now().UTC().Truncate(time.Hour)
+	for _, v := range []float64{1, 2, 3} {
+		val := v
+		_, err := srvc.CreateOrUpdate(context.Background(), entity.Metrics{ID: "testGauge", MType: entity.Gauge, Value: &val})
+		require.NoError(t, err)
+	}
+	labeled := 4.0
+	_, err := srvc.CreateOrUpdate(context.Background(), entity.Metrics{
+		ID: "testGauge", MType: entity.Gauge, Value: &labeled, Labels: map[string]string{"host": "a"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		query          string
+		parameters     map[string]string
+		expectedPoints []entity.MetricPoint
+		expectedStatus int
+		expectedCount  int
+	}{
+		{
+			name:           "All points",
+			parameters:     map[string]string{"type": "gauge", "name": "testGauge"},
+			expectedStatus: http.StatusOK,
+			expectedCount:  3,
+		},
+		{
+			name:           "Downsampled points",
+			query:          "?step=1h&agg=max&from=" + from.Format(time.RFC3339),
+			parameters:     map[string]string{"type": "gauge", "name": "testGauge"},
+			expectedStatus: http.StatusOK,
+			expectedCount:  1,
+			expectedPoints: []entity.MetricPoint{{Time: from, Value: 3}},
+		},
+		{
+			name:           "Step without from",
+			query:          "?step=1h",
+			parameters:     map[string]string{"type": "gauge", "name": "testGauge"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Labeled metric",
+			query:          "?label=host=a",
+			parameters:     map[string]string{"type": "gauge", "name": "testGauge"},
+			expectedStatus: http.StatusOK,
+			expectedCount:  1,
+		},
+		{
+			name:           "Invalid label",
+			query:          "?label=host",
+			parameters:     map[string]string{"type": "gauge", "name": "testGauge"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid from",
+			query:          "?from=yesterday",
+			parameters:     map[string]string{"type": "gauge", "name": "testGauge"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid aggregation",
+			query:          "?step=1m&agg=median",
+			parameters:     map[string]string{"type": "gauge", "name": "testGauge"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown metric",
+			parameters:     map[string]string{"type": "gauge", "name": "unknown"},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/history/" + tt.parameters["type"] + "/" + tt.parameters["name"] + tt.query
+			req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+			req.SetPathValue("type", tt.parameters["type"])
+			req.SetPathValue("name", tt.parameters["name"])
+			w := httptest.NewRecorder()
+
+			serv.GetMetricHistory(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var points []entity.MetricPoint
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &points))
+				require.Len(t, points, tt.expectedCount)
+				if tt.expectedPoints != nil {
+					for i := range tt.expectedPoints {
+						require.True(t, tt.expectedPoints[i].Time.Equal(points[i].Time), "got %v", points[i].Time)
+						require.Equal(t, tt.expectedPoints[i].Value, points[i].Value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGetMetricsPrometheus(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	repo := repository.New("", log)
+	srvc := service.New(repo, nil, 0, log)
+	serv := &HTTPServer{
+		service: srvc,
+		log:     log,
+	}
+
+	gauge := 12.5
+	counter := int64(7)
+	ctx := context.Background()
+	_, err := srvc.CreateOrUpdate(ctx, entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: &gauge})
+	require.NoError(t, err)
+	_, err = srvc.CreateOrUpdate(ctx, entity.Metrics{ID: "Poll.Count", MType: entity.Counter, Delta: &counter})
+	require.NoError(t, err)
+	for _, host := range []string{"b", `a"1`} {
+		_, err = srvc.CreateOrUpdate(ctx, entity.Metrics{
+			ID:     "Alloc",
+			MType:  entity.Gauge,
+			Value:  &gauge,
+			Labels: map[string]string{"host": host},
+		})
+		require.NoError(t, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody)
+	w := httptest.NewRecorder()
+
+	serv.GetMetricsPrometheus(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, common.HeaderContentTypeValuePrometheus, w.Header().Get(common.HeaderContentType))
+	expected := "# TYPE Alloc gauge\n" +
+		"Alloc 12.5\n" +
+		"Alloc{host=\"a\\\"1\"} 12.5\n" +
+		"Alloc{host=\"b\"} 12.5\n" +
+		"# TYPE Poll_Count counter\n" +
+		"Poll_Count 7\n"
+	require.Equal(t, expected, w.Body.String())
+}
+
+func TestGetMetricsPrometheus_Histogram(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	repo := repository.New("", log)
+	srvc := service.New(repo, nil, 0, log).SetHistogramBuckets([]float64{0.1, 1})
+	serv := &HTTPServer{
+		service: srvc,
+		log:     log,
+	}
+
+	ctx := context.Background()
+	for _, v := range []float64{0.05, 0.5, 2} {
+		_, err := srvc.CreateOrUpdate(ctx, entity.Metrics{
+			ID:     "latency",
+			MType:  entity.Histogram,
+			Value:  &v,
+			Labels: map[string]string{"host": "a"},
+		})
+		require.NoError(t, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody)
+	w := httptest.NewRecorder()
+
+	serv.GetMetricsPrometheus(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	expected := "# TYPE latency histogram\n" +
+		"latency_bucket{host=\"a\",le=\"0.1\"} 1\n" +
+		"latency_bucket{host=\"a\",le=\"1\"} 2\n" +
+		"latency_bucket{host=\"a\",le=\"+Inf\"} 3\n" +
+		"latency_sum{host=\"a\"} 2.55\n" +
+		"latency_count{host=\"a\"} 3\n"
+	require.Equal(t, expected, w.Body.String())
+}
+
+func TestWritePrometheusMetrics_NameCollision(t *testing.T) {
+	v1, v2, v3, d := 1.0, 2.0, 3.0, int64(4)
+	metrics := []entity.Metrics{
+		{ID: "a.b", MType: entity.Gauge, Value: &v1},
+		{ID: "a_a", MType: entity.Gauge, Value: &v3},
+		{ID: "a_b", MType: entity.Gauge, Value: &v2, Labels: map[string]string{"host": "x"}},
+		{ID: "a:b", MType: entity.Counter, Delta: &d},
+		{ID: "a-b", MType: entity.Counter, Delta: &d},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writePrometheusMetrics(&buf, metrics))
+	expected := "# TYPE a:b counter\n" +
+		"a:b 4\n" +
+		"# TYPE a_a gauge\n" +
+		"a_a 3\n" +
+		"# TYPE a_b counter\n" +
+		"a_b 4\n"
+	require.Equal(t, expected, buf.String())
+}
+
+func TestWritePrometheusMetrics_LabelCollision(t *testing.T) {
+	v := 1.0
+	metrics := []entity.Metrics{
+		{ID: "Alloc", MType: entity.Gauge, Value: &v, Labels: map[string]string{"a-b": "1", "a_b": "2", "a.b": "3"}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writePrometheusMetrics(&buf, metrics))
+	expected := "# TYPE Alloc gauge\n" +
+		"Alloc{a_b=\"2\",exported_a_b=\"1\",exported_exported_a_b=\"3\"} 1\n"
+	require.Equal(t, expected, buf.String())
+}
+
+func TestWritePrometheusMetrics_HistogramLeLabel(t *testing.T) {
+	metrics := []entity.Metrics{{
+		ID:        "latency",
+		MType:     entity.Histogram,
+		Labels:    map[string]string{"le": "user"},
+		Histogram: &entity.HistogramData{Bounds: []float64{1}, Counts: []int64{1, 0}, Sum: 0.5, Count: 1},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, writePrometheusMetrics(&buf, metrics))
+	expected := "# TYPE latency histogram\n" +
+		"latency_bucket{exported_le=\"user\",le=\"1\"} 1\n" +
+		"latency_bucket{exported_le=\"user\",le=\"+Inf\"} 1\n" +
+		"latency_sum{exported_le=\"user\"} 0.5\n" +
+		"latency_count{exported_le=\"user\"} 1\n"
+	require.Equal(t, expected, buf.String())
+}
+
+func TestWritePrometheusMetrics_HistogramSeriesCollision(t *testing.T) {
+	v := 2.0
+	metrics := []entity.Metrics{
+		{ID: "latency_sum", MType: entity.Gauge, Value: &v},
+		{ID: "latency_count", MType: entity.Gauge, Value: &v},
+		{
+			ID:        "latency",
+			MType:     entity.Histogram,
+			Histogram: &entity.HistogramData{Counts: []int64{1}, Sum: 0.5, Count: 1},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writePrometheusMetrics(&buf, metrics))
+	expected := "# TYPE exported_latency_count gauge\n" +
+		"exported_latency_count 2\n" +
+		"# TYPE exported_latency_sum gauge\n" +
+		"exported_latency_sum 2\n" +
+		"# TYPE latency histogram\n" +
+		"latency_bucket{le=\"+Inf\"} 1\n" +
+		"latency_sum 0.5\n" +
+		"latency_count 1\n"
+	require.Equal(t, expected, buf.String())
+}
+
+func TestWritePrometheusMetrics_DuplicateSeries(t *testing.T) {
+	v1, v2 := 1.0, 2.0
+	metrics := []entity.Metrics{
+		{ID: "a.b", MType: entity.Gauge, Value: &v1},
+		{ID: "a-b", MType: entity.Gauge, Value: &v2},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writePrometheusMetrics(&buf, metrics))
+	require.Equal(t, "# TYPE a_b gauge\na_b 2\n", buf.String())
+}
+
+func TestSanitizePrometheusName(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "valid name", input: "CPUutilization1", expected: "CPUutilization1"},
+		{name: "invalid chars", input: "disk/used-bytes", expected: "disk_used_bytes"},
+		{name: "leading digit", input: "1minute", expected: "_1minute"},
+		{name: "colon allowed", input: "job:requests", expected: "job:requests"},
+		{name: "empty name", input: "", expected: "_"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, sanitizePrometheusName(tt.input))
+		})
+	}
+}
+
+func TestStreamMetricsSSE(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	srvc := service.New(repository.New("", log), nil, 0, log)
+	serv := &HTTPServer{
+		service:  srvc,
+		log:      log,
+		shutdown: make(chan struct{}),
+	}
+	ts := httptest.NewServer(http.HandlerFunc(serv.StreamMetrics))
+	defer ts.Close()
+
+	t.Run("bad filters", func(t *testing.T) {
+		for _, query := range []string{"?type=unknown", "?name=Poll["} {
+			resp, err := http.Get(ts.URL + query)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		}
+	})
+
+	t.Run("filtered events", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "?type=counter&name=Poll*")
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, common.HeaderContentTypeValueEventStream, resp.Header.Get(common.HeaderContentType))
+
+		// заголовки отправляются после оформления подписки
+		value, delta := 1.0, int64(2)
+		for _, m := range []entity.Metrics{
+			{ID: "PollInterval", MType: entity.Gauge, Value: &value},
+			{ID: "Alloc", MType: entity.Counter, Delta: &delta},
+			{ID: "PollCount", MType: entity.Counter, Delta: &delta},
+		} {
+			_, err := srvc.CreateOrUpdate(context.Background(), m)
+			require.NoError(t, err)
+		}
+
+		reader := bufio.NewReader(resp.Body)
+		var lines []string
+		for len(lines) < 3 {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+		}
+		require.Equal(t, "event: metric", lines[0])
+		require.Equal(t, `data: {"delta":2,"id":"PollCount","type":"counter"}`, lines[1])
+		require.Empty(t, lines[2])
+
+		close(serv.shutdown)
+		_, err = io.ReadAll(reader)
+		require.NoError(t, err)
+	})
+}
+
+func TestWriteSSEMetricEvent(t *testing.T) {
+	value := 1.5
+	var buf bytes.Buffer
+	err := writeSSEMetricEvent(&buf, service.Event{
+		Metric:  entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: &value},
+		Dropped: 3,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "event: dropped\ndata: {\"dropped\":3}\n\n"+
+		"event: metric\ndata: {\"value\":1.5,\"id\":\"Alloc\",\"type\":\"gauge\"}\n\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeSSEMetricEvent(&buf, service.Event{Dropped: 2}))
+	require.Equal(t, "event: dropped\ndata: {\"dropped\":2}\n\n", buf.String())
+}
+
+func TestUI_Middlewares(t *testing.T) {
+	log := logger.New(logger.LevelInfo)
+	srvc := service.New(repository.New("", log), nil, 0, log)
+	serv := NewHTTPServer(context.Background(), &HTTPServerConfig{
+		Service:       srvc,
+		HashKey:       "secret",
+		TrustedSubnet: "10.0.0.1",
+	}, log)
+	value := 1.5
+	_, err := srvc.CreateOrUpdate(context.Background(), entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: &value})
+	require.NoError(t, err)
+
+	// заголовки, которые отправляет браузер
+	browserRequest := func(path, accept, realIP string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		req.Header.Set(common.HeaderAccept, accept)
+		req.Header.Set(common.HeaderAcceptEncoding, "gzip, deflate, br")
+		req.Header.Set(common.HeaderXRealIP, realIP)
+		return req
+	}
+	readBody := func(t *testing.T, w *httptest.ResponseRecorder) string {
+		t.Helper()
+		if w.Header().Get(common.HeaderContentEncoding) != common.HeaderEncodingValueGZIP {
+			return w.Body.String()
+		}
+		gz, err := gzip.NewReader(w.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	t.Run("redirect", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		serv.Handler.ServeHTTP(w, browserRequest("/ui", "text/html", "10.0.0.1"))
+		require.Equal(t, http.StatusMovedPermanently, w.Code)
+		require.Equal(t, "/ui/", w.Header().Get("Location"))
+	})
+
+	t.Run("page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		serv.Handler.ServeHTTP(w, browserRequest("/ui/", "text/html,application/xhtml+xml,*/*;q=0.8", "10.0.0.1"))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "text/html; charset=utf-8", w.Header().Get(common.HeaderContentType))
+		require.Contains(t, readBody(t, w), "<title>Metrics</title>")
+	})
+
+	t.Run("metrics request from page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		serv.Handler.ServeHTTP(w, browserRequest("/", common.HeaderContentTypeValueApplicationJSON, "10.0.0.1"))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `[{"id":"Alloc","type":"gauge","value":1.5}]`, readBody(t, w))
+	})
+
+	t.Run("not trusted", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		serv.Handler.ServeHTTP(w, browserRequest("/ui/", "text/html", "10.0.0.2"))
+		require.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/common"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/service"
+	"google.golang.org/grpc/metadata"
+)
+
+// withRequestSource возвращает контекст запроса с источником обновления метрик:
+// заголовок "X-Agent-Id", а при его отсутствии - "X-Real-IP".
+func withRequestSource(r *http.Request) context.Context {
+	source := r.Header.Get(common.HeaderXAgentID)
+	if source == "" {
+		source = r.Header.Get(common.HeaderXRealIP)
+	}
+	return service.WithSource(r.Context(), source)
+}
+
+// withMetadataSource возвращает контекст вызова с источником обновления метрик:
+// метаданные "x-agent-id", а при их отсутствии - "x-real-ip".
+func withMetadataSource(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range []string{common.HeaderXAgentID, common.HeaderXRealIP} {
+		if vals := md.Get(strings.ToLower(key)); len(vals) > 0 && vals[0] != "" {
+			return service.WithSource(ctx, vals[0])
+		}
+	}
+	return ctx
+}
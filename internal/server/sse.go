@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/service"
+)
+
+// sseHeartbeatInterval - интервал отправки комментария-пульса, не дающего прокси закрыть простаивающий поток.
+const sseHeartbeatInterval = 15 * time.Second
+
+// Константы - типы событий потока Server-Sent Events.
+const (
+	sseEventMetric  = "metric"  // изменение метрики
+	sseEventDropped = "dropped" // пропуск изменений из-за медленного чтения потока
+)
+
+// sseDropped описывает данные события "dropped".
+type sseDropped struct {
+	Dropped uint64 `json:"dropped"` // количество пропущенных изменений
+}
+
+// writeSSEMetricEvent выводит событие изменения метрики в формате Server-Sent Events.
+// Если перед изменением были пропущены события, сначала выводится событие "dropped".
+// Событие без метрики выводится только как "dropped".
+//
+// Параметры:
+//   - w: получатель данных
+//   - e: событие изменения метрики
+func writeSSEMetricEvent(w io.Writer, e service.Event) error {
+	if e.Dropped > 0 {
+		if err := writeSSEEvent(w, sseEventDropped, sseDropped{Dropped: e.Dropped}); err != nil {
+			return err
+		}
+	}
+	if e.Metric.ID == "" {
+		return nil
+	}
+	return writeSSEEvent(w, sseEventMetric, e.Metric)
+}
+
+// writeSSEEvent выводит событие с данными в формате JSON.
+func writeSSEEvent(w io.Writer, event string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal event data error: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body); err != nil {
+		return fmt.Errorf("write event error: %w", err)
+	}
+	return nil
+}
+
+// writeSSEHeartbeat выводит комментарий-пульс, который клиенты игнорируют.
+func writeSSEHeartbeat(w io.Writer) error {
+	if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+		return fmt.Errorf("write heartbeat error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// Панель метрик: загружает все метрики запросом GET /, группирует их по типу,
+// фильтрует по строке поиска и периодически обновляет значения.
+"use strict";
+
+const TYPE_ORDER = ["gauge", "counter", "histogram"];
+
+const groupsEl = document.getElementById("groups");
+const searchEl = document.getElementById("search");
+const intervalEl = document.getElementById("interval");
+const statusEl = document.getElementById("status");
+
+let metrics = [];
+let previous = new Map();
+let timer = null;
+
+// metricKey возвращает идентификатор метрики с учётом меток.
+function metricKey(m) {
+  return m.type + ":" + m.id + formatLabels(m.labels);
+}
+
+function formatLabels(labels) {
+  if (!labels) {
+    return "";
+  }
+  const parts = Object.keys(labels).sort().map((k) => k + "=" + JSON.stringify(labels[k]));
+  return parts.length ? "{" + parts.join(",") + "}" : "";
+}
+
+function formatValue(m) {
+  switch (m.type) {
+    case "gauge":
+      return m.value === undefined ? "" : String(m.value);
+    case "counter":
+      return m.delta === undefined ? "" : String(m.delta);
+    case "histogram":
+      if (!m.histogram) {
+        return "";
+      }
+      return "count=" + m.histogram.count + " sum=" + m.histogram.sum;
+    default:
+      return m.value !== undefined ? String(m.value) : String(m.delta ?? "");
+  }
+}
+
+function matches(m, query) {
+  if (!query) {
+    return true;
+  }
+  return (m.id + " " + formatLabels(m.labels)).toLowerCase().includes(query);
+}
+
+function render() {
+  const query = searchEl.value.trim().toLowerCase();
+  const groups = new Map();
+  for (const m of metrics) {
+    if (!matches(m, query)) {
+      continue;
+    }
+    if (!groups.has(m.type)) {
+      groups.set(m.type, []);
+    }
+    groups.get(m.type).push(m);
+  }
+
+  const types = [...groups.keys()].sort((a, b) => {
+    const ia = TYPE_ORDER.indexOf(a);
+    const ib = TYPE_ORDER.indexOf(b);
+    return (ia < 0 ? TYPE_ORDER.length : ia) - (ib < 0 ? TYPE_ORDER.length : ib) || a.localeCompare(b);
+  });
+
+  groupsEl.replaceChildren();
+  if (types.length === 0) {
+    const p = document.createElement("p");
+    p.className = "empty";
+    p.textContent = metrics.length ? "No metrics match the search." : "No metrics yet.";
+    groupsEl.append(p);
+    return;
+  }
+
+  for (const type of types) {
+    const list = groups.get(type).sort((a, b) => metricKey(a).localeCompare(metricKey(b)));
+    const section = document.createElement("section");
+
+    const title = document.createElement("h2");
+    title.textContent = type + " ";
+    const count = document.createElement("span");
+    count.className = "count";
+    count.textContent = "(" + list.length + ")";
+    title.append(count);
+
+    const table = document.createElement("table");
+    const head = table.createTHead().insertRow();
+    for (const name of ["Name", "Labels", "Value"]) {
+      const th = document.createElement("th");
+      th.textContent = name;
+      head.append(th);
+    }
+    const body = table.createTBody();
+    for (const m of list) {
+      const value = formatValue(m);
+      const row = body.insertRow();
+      const key = metricKey(m);
+      if (previous.has(key) && previous.get(key) !== value) {
+        row.className = "changed";
+      }
+      row.insertCell().textContent = m.id;
+      const labels = row.insertCell();
+      labels.className = "labels";
+      labels.textContent = formatLabels(m.labels);
+      const cell = row.insertCell();
+      cell.className = "value";
+      cell.textContent = value;
+    }
+
+    section.append(title, table);
+    groupsEl.append(section);
+  }
+}
+
+async function refresh() {
+  try {
+    const resp = await fetch("../", {
+      headers: { Accept: "application/json" },
+      cache: "no-store",
+    });
+    if (!resp.ok) {
+      throw new Error(resp.status + " " + (await resp.text()).trim());
+    }
+    const next = (await resp.json()) || [];
+    previous = new Map(metrics.map((m) => [metricKey(m), formatValue(m)]));
+    metrics = next;
+    statusEl.className = "";
+    statusEl.textContent = "Updated " + new Date().toLocaleTimeString();
+    render();
+  } catch (err) {
+    statusEl.className = "error";
+    statusEl.textContent = "Update failed: " + err.message;
+  }
+}
+
+function schedule() {
+  clearInterval(timer);
+  const seconds = Number(intervalEl.value);
+  if (seconds > 0) {
+    timer = setInterval(refresh, seconds * 1000);
+  }
+}
+
+searchEl.addEventListener("input", render);
+intervalEl.addEventListener("change", schedule);
+
+refresh();
+schedule();
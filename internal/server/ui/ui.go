@@ -0,0 +1,45 @@
+// Пакет ui предоставляет встроенную в сервер HTML-панель для просмотра метрик.
+package ui
+
+import (
+	"embed"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/common"
+)
+
+// static содержит файлы панели.
+//
+//go:embed static
+var static embed.FS
+
+// Константы для поиска файлов панели.
+const (
+	staticDir string = "static"     // каталог файлов панели
+	indexFile string = "index.html" // файл, отдаваемый для корня панели
+)
+
+// Handler возвращает обработчик, отдающий файлы панели.
+// Панель получает метрики запросом GET / и периодически обновляет их,
+// поэтому обслуживается тем же сервером и за теми же middleware, что и остальные запросы.
+//
+// Параметры:
+//   - prefix: путь, по которому доступна панель (например, /ui/)
+func Handler(prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + strings.TrimPrefix(r.URL.Path, prefix))
+		if name == "/" {
+			name = "/" + indexFile
+		}
+
+		// middleware сжатия заполняет тип данных из заголовка Accept, для файлов панели он определяется по расширению
+		w.Header().Del(common.HeaderContentType)
+		if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+			w.Header().Set(common.HeaderContentType, ctype)
+		}
+		http.ServeFileFS(w, r, static, staticDir+name)
+	})
+}
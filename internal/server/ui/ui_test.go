@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		expectedType string
+		expectedBody string
+		expectedCode int
+	}{
+		{
+			name:         "index",
+			path:         "/ui/",
+			expectedCode: http.StatusOK,
+			expectedType: "text/html; charset=utf-8",
+			expectedBody: "<title>Metrics</title>",
+		},
+		{
+			name:         "script",
+			path:         "/ui/app.js",
+			expectedCode: http.StatusOK,
+			expectedType: "text/javascript; charset=utf-8",
+			expectedBody: "function refresh",
+		},
+		{
+			name:         "styles",
+			path:         "/ui/style.css",
+			expectedCode: http.StatusOK,
+			expectedType: "text/css; charset=utf-8",
+		},
+		{
+			name:         "not found",
+			path:         "/ui/missing.html",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "outside of static",
+			path:         "/ui/../ui.go",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	handler := Handler("/ui/")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ui/", http.NoBody)
+			req.URL.Path = tt.path
+			// тип данных, заполненный middleware сжатия из заголовка Accept, заменяется
+			w := httptest.NewRecorder()
+			w.Header().Set(common.HeaderContentType, "text/html,application/xhtml+xml,*/*;q=0.8")
+
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode == http.StatusOK {
+				assert.Equal(t, tt.expectedType, w.Header().Get(common.HeaderContentType))
+				assert.Contains(t, w.Body.String(), tt.expectedBody)
+			}
+		})
+	}
+}
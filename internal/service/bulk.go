@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/repository"
+)
+
+// bulkRepository возвращает репозиторий с пакетной записью, если он поддерживается
+// и набор можно записать одним запросом (гистограммы объединяются на стороне сервиса).
+// С журналом изменений пакетная запись не используется:
+// записи журнала добавляются в транзакции вместе с изменениями.
+func (s *Service) bulkRepository(es []entity.Metrics) (repository.BulkRepository, bool) {
+	bulk, ok := s.repository.(repository.BulkRepository)
+	if !ok || s.journal != nil {
+		return nil, false
+	}
+	for _, e := range es {
+		if e.MType == entity.Histogram {
+			return nil, false
+		}
+	}
+	return bulk, true
+}
+
+// upsertBatch применяет набор метрик одним запросом к репозиторию.
+// Повторяющиеся метрики набора предварительно объединяются.
+func (s *Service) upsertBatch(
+	ctx context.Context,
+	bulk repository.BulkRepository,
+	es []entity.Metrics,
+) ([]entity.Metrics, error) {
+	merged, ok := mergeBatch(es, SourceFromContext(ctx), s.now().UTC())
+	if !ok {
+		s.reportStorageError(MetricUncorrect, "")
+		return nil, errors.New(MetricUncorrect)
+	}
+
+	applied, err := bulk.Upsert(ctx, merged)
+	if err != nil {
+		s.reportStorageError(err.Error(), "")
+		if err.Error() == repository.ErrorMetricTypeMismatch {
+			return nil, errors.New(MetricUncorrect)
+		}
+		return nil, errors.New(UnexpectedMetricUpdate)
+	}
+
+	for _, m := range applied {
+		s.reportMetricInfo("Storage upsert value", m)
+	}
+	return applied, nil
+}
+
+// mergeBatch объединяет метрики набора с одинаковым ключом: для gauge остаётся последнее значение,
+// значения counter складываются и учитываются в разбивке по источнику source.
+// Порядок метрик - по первому появлению ключа.
+// Возвращает false, если метрика набора некорректна или один ключ встречается с разными типами.
+func mergeBatch(es []entity.Metrics, source string, now time.Time) ([]entity.Metrics, bool) {
+	index := make(map[string]int, len(es))
+	merged := make([]entity.Metrics, 0, len(es))
+	for _, e := range es {
+		switch {
+		case e.MType == entity.Gauge && e.Value != nil:
+		case e.MType == entity.Counter && e.Delta != nil:
+		default:
+			return nil, false
+		}
+		e.UpdatedAt = now
+		e.Sources = nil
+
+		key := e.Key()
+		i, ok := index[key]
+		if !ok {
+			if e.MType == entity.Counter {
+				delta := *e.Delta
+				e.Delta = &delta
+				e.Sources = addSource(nil, source, delta)
+			}
+			index[key] = len(merged)
+			merged = append(merged, e)
+			continue
+		}
+
+		m := &merged[i]
+		if m.MType != e.MType {
+			return nil, false
+		}
+		if e.MType == entity.Counter {
+			*m.Delta += *e.Delta
+			m.Sources = addSource(m.Sources, source, *e.Delta)
+		} else {
+			m.Value = e.Value
+		}
+	}
+	return merged, true
+}
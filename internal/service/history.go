@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+)
+
+// Константы - функции агрегации значений при прореживании истории.
+const (
+	AggregationAvg  = "avg"  // среднее значение за интервал
+	AggregationMin  = "min"  // минимальное значение за интервал
+	AggregationMax  = "max"  // максимальное значение за интервал
+	AggregationLast = "last" // последнее значение за интервал
+)
+
+// HistoryQuery описывает параметры запроса истории значений метрики.
+type HistoryQuery struct {
+	From        time.Time     // начало интервала (обязательно при прореживании)
+	To          time.Time     // конец интервала
+	ID          string        // идентификатор метрики с учётом меток (entity.Metrics.Key)
+	MType       string        // тип метрики
+	Aggregation string        // функция агрегации (avg, min, max, last)
+	Step        time.Duration // шаг прореживания (0 - без прореживания)
+}
+
+// GetHistory возвращает историю значений метрики, при необходимости прореженную с шагом q.Step.
+//
+// Параметры:
+//   - q: параметры запроса истории
+func (s *Service) GetHistory(ctx context.Context, q HistoryQuery) ([]entity.MetricPoint, error) {
+	if s.history == nil {
+		s.reportStorageError(HistoryDisabled, "")
+		return nil, errors.New(HistoryDisabled)
+	}
+	// интервалы прореживания отсчитываются от начала, поэтому без него прореживание невозможно
+	invalidStep := q.Step < 0 || (q.Step > 0 && q.From.IsZero())
+	if q.To.Before(q.From) || invalidStep || !isValidAggregation(q.Aggregation) {
+		s.reportStorageError(HistoryUncorrect, q.ID)
+		return nil, errors.New(HistoryUncorrect)
+	}
+
+	if _, err := s.Get(ctx, q.ID, q.MType); err != nil {
+		return nil, err
+	}
+
+	points, err := s.history.GetHistory(ctx, q.ID, q.From, q.To)
+	if err != nil {
+		s.reportStorageError(err.Error(), q.ID)
+		return nil, errors.New(err.Error())
+	}
+
+	if q.Step == 0 {
+		return points, nil
+	}
+	return downsample(points, q.From, q.Step, q.Aggregation), nil
+}
+
+// recordHistory сохраняет применённое значение метрики в историю, если она включена.
+func (s *Service) recordHistory(ctx context.Context, e entity.Metrics) {
+	if s.history == nil {
+		return
+	}
+
+	p := entity.MetricPoint{Time: time.Now().UTC()}
+	switch {
+	case e.MType == entity.Gauge && e.Value != nil:
+		p.Value = *e.Value
+	case e.MType == entity.Counter && e.Delta != nil:
+		p.Value = float64(*e.Delta)
+	default:
+		return
+	}
+
+	if err := s.history.AddPoint(ctx, e.Key(), p); err != nil {
+		s.log.Error("Add metric point to history error", err, "id", e.Key())
+	}
+}
+
+// downsample группирует точки по интервалам длиной step, отсчитываемым от from,
+// и сворачивает каждый интервал в одну точку с помощью функции агрегации.
+// Время результирующей точки - начало интервала.
+func downsample(points []entity.MetricPoint, from time.Time, step time.Duration, agg string) []entity.MetricPoint {
+	result := make([]entity.MetricPoint, 0)
+
+	var (
+		bucket int64
+		count  int
+		acc    float64
+	)
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		if agg == AggregationAvg {
+			acc /= float64(count)
+		}
+		result = append(result, entity.MetricPoint{
+			Time:  from.Add(time.Duration(bucket) * step),
+			Value: acc,
+		})
+	}
+
+	for _, p := range points {
+		b := int64(p.Time.Sub(from) / step)
+		if count > 0 && b != bucket {
+			flush()
+			count = 0
+		}
+		bucket = b
+
+		switch {
+		case count == 0:
+			acc = p.Value
+		case agg == AggregationAvg:
+			acc += p.Value
+		case agg == AggregationMin:
+			acc = math.Min(acc, p.Value)
+		case agg == AggregationMax:
+			acc = math.Max(acc, p.Value)
+		case agg == AggregationLast:
+			acc = p.Value
+		}
+		count++
+	}
+	flush()
+
+	return result
+}
+
+func isValidAggregation(agg string) bool {
+	switch agg {
+	case AggregationAvg, AggregationMin, AggregationMax, AggregationLast:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+)
+
+// subscriberBufferSize - размер буфера событий одного подписчика.
+const subscriberBufferSize = 256
+
+// Event описывает событие изменения метрики.
+// Если после пропуска событий новых изменений нет, пропуск передаётся отдельным событием
+// с пустой метрикой (Metric.ID == "") сразу после освобождения места в буфере подписчика.
+type Event struct {
+	Metric  entity.Metrics // значение метрики после создания или обновления
+	Dropped uint64         // количество событий, пропущенных подписчиком перед этим событием
+}
+
+// Subscribe подписывает на события изменения метрик, подходящих под фильтр: событие
+// публикуется после каждого создания или обновления метрики. Подписка снимается
+// и канал закрывается при отмене ctx. Медленный подписчик не задерживает приём метрик:
+// при заполнении его буфера события пропускаются, а их количество передаётся
+// в поле Dropped следующего доставленного события или отдельным событием без метрики.
+//
+// Параметры:
+//   - f: фильтр метрик
+func (s *Service) Subscribe(ctx context.Context, f MetricFilter) <-chan Event {
+	return s.hub.subscribe(ctx, f.Match)
+}
+
+// subscriber - подписчик на события изменения метрик.
+type subscriber struct {
+	events   chan Event                // канал, из которого подписчик читает события
+	notify   chan struct{}             // сигнал о появлении событий в очереди
+	match    func(entity.Metrics) bool // фильтр событий
+	queue    []Event                   // буфер событий подписчика
+	dropped  uint64                    // количество пропущенных событий с момента последней доставки
+	inFlight int                       // количество событий, взятых из очереди и ещё не прочитанных
+	mu       sync.Mutex                // защищает queue, dropped и inFlight
+}
+
+// hub рассылает события изменения метрик подписчикам.
+// Публикация не ждёт подписчиков: если буфер подписчика заполнен, событие для него пропускается,
+// а количество пропущенных событий передаётся в следующем доставленном событии
+// или отдельным событием, когда очередь подписчика опустеет.
+type hub struct {
+	subscribers map[*subscriber]struct{} // подписчики
+	bufferSize  int                      // размер буфера событий подписчика
+	mu          sync.Mutex               // защищает subscribers
+}
+
+// newHub создаёт и инициализирует новый экзепляр *hub.
+//
+// Параметры:
+//   - bufferSize: размер буфера событий подписчика
+func newHub(bufferSize int) *hub {
+	return &hub{
+		subscribers: map[*subscriber]struct{}{},
+		bufferSize:  bufferSize,
+	}
+}
+
+// subscribe оформляет подписку на события, подходящие под фильтр match.
+// Подписка снимается и канал закрывается при отмене ctx.
+func (h *hub) subscribe(ctx context.Context, match func(entity.Metrics) bool) <-chan Event {
+	sub := &subscriber{
+		events: make(chan Event),
+		notify: make(chan struct{}, 1),
+		match:  match,
+	}
+
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		defer close(sub.events)
+		sub.forward(ctx)
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers, sub)
+	}()
+
+	return sub.events
+}
+
+// publish рассылает события изменения метрик подписчикам без ожидания.
+func (h *hub) publish(ms ...entity.Metrics) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		for _, m := range ms {
+			if sub.match(m) {
+				sub.push(m, h.bufferSize)
+			}
+		}
+	}
+}
+
+// push ставит событие в очередь подписчика или учитывает его как пропущенное, если буфер заполнен.
+func (sub *subscriber) push(m entity.Metrics, bufferSize int) {
+	sub.mu.Lock()
+	if len(sub.queue)+sub.inFlight >= bufferSize {
+		sub.dropped++
+	} else {
+		sub.queue = append(sub.queue, Event{Metric: m, Dropped: sub.dropped})
+		sub.dropped = 0
+	}
+	sub.mu.Unlock()
+
+	select {
+	case sub.notify <- struct{}{}:
+	default:
+	}
+}
+
+// next берёт из очереди следующее событие. Если очередь пуста, а события были пропущены,
+// возвращает отдельное событие с количеством пропущенных.
+func (sub *subscriber) next() (Event, bool) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	var e Event
+	switch {
+	case len(sub.queue) > 0:
+		e = sub.queue[0]
+		sub.queue[0] = Event{}
+		sub.queue = sub.queue[1:]
+	case sub.dropped > 0:
+		e = Event{Dropped: sub.dropped}
+		sub.dropped = 0
+	default:
+		return Event{}, false
+	}
+	sub.inFlight++
+	return e, true
+}
+
+// forward передаёт события из очереди в канал подписчика до отмены ctx.
+func (sub *subscriber) forward(ctx context.Context) {
+	for {
+		e, ok := sub.next()
+		if !ok {
+			select {
+			case <-sub.notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case sub.events <- e:
+		case <-ctx.Done():
+			return
+		}
+
+		sub.mu.Lock()
+		sub.inFlight--
+		sub.mu.Unlock()
+	}
+}
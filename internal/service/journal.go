@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/storage"
+)
+
+// appendJournal записывает изменения метрик в журнал. Вызывается под блокировкой изменений
+// внутри транзакции репозитория, поэтому ошибка записи откатывает изменения. Без журнала ничего не делает.
+//
+// Параметры:
+//   - op: операция (storage.OperationUpdate или storage.OperationRemove)
+//   - ms: изменённые метрики (для обновления - итоговые значения)
+func (s *Service) appendJournal(op string, ms []entity.Metrics) error {
+	if s.journal == nil || len(ms) == 0 {
+		return nil
+	}
+
+	records := make([]storage.Record, 0, len(ms))
+	for _, m := range ms {
+		records = append(records, storage.Record{Op: op, Metric: m})
+	}
+	if err := s.journal.Append(records...); err != nil {
+		s.log.Error("Append to journal error", err)
+		return err
+	}
+	return nil
+}
+
+// compactJournal сохраняет данные в снимок и очищает журнал, если он превысил journalCompactSize.
+// Вызывается под блокировкой изменений после завершения транзакции, так как читает весь репозиторий.
+func (s *Service) compactJournal(ctx context.Context) {
+	if s.journal == nil || s.journal.Size() < journalCompactSize {
+		return
+	}
+	// изменения уже в журнале, поэтому ошибка сохранения снимка не отменяет их
+	if err := s.saveDataWithoutInterval(ctx); err != nil {
+		s.log.Error("Compact journal error", err)
+	}
+}
+
+// restoreJournal восстанавливает данные из снимка и журнала изменений, после чего сохраняет новый снимок
+// и очищает журнал. Снимок сохраняется и без восстановления, чтобы старые записи журнала
+// не применились к новым данным при следующем запуске.
+//
+// Параметры:
+//   - restoreData: флаг, указывающий загружать ли данные
+func (s *Service) restoreJournal(ctx context.Context, restoreData bool) {
+	if restoreData {
+		data, err := s.journal.LoadData()
+		if err != nil {
+			s.log.Error("Load data from storage error", err)
+		}
+		// метрики снимка и журнала содержат итоговые значения и применяются к пустому репозиторию
+		for _, val := range data {
+			if _, err := s.apply(withRestore(ctx), s.repository, val); err != nil {
+				s.log.Error("Set data to repository error", err)
+			}
+		}
+		s.log.Info(
+			"Restore data from snapshot and journal is success",
+			"count", len(data),
+			"time", time.Now(),
+		)
+	}
+
+	if err := s.saveDataWithoutInterval(ctx); err != nil {
+		s.log.Error("Compact journal error", err)
+	}
+}
+
+// restoreKey - ключ контекста, отмечающий восстановление данных из хранилища.
+type restoreKey struct{}
+
+// withRestore возвращает контекст восстановления данных: метрики сохраняют
+// время последнего изменения из хранилища, чтобы перезапуск не продлевал время их хранения.
+func withRestore(ctx context.Context) context.Context {
+	return context.WithValue(ctx, restoreKey{}, true)
+}
+
+// isRestore сообщает, выполняется ли восстановление данных из хранилища.
+func isRestore(ctx context.Context) bool {
+	restore, _ := ctx.Value(restoreKey{}).(bool)
+	return restore
+}
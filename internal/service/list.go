@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+)
+
+// MetricFilter описывает фильтр метрик по имени и типу.
+type MetricFilter struct {
+	NamePrefix  string // префикс имени метрики (пусто - все метрики)
+	NamePattern string // шаблон имени метрики в формате path.Match (пусто - все метрики)
+	MType       string // тип метрики (пусто - все типы)
+}
+
+// Validate проверяет корректность шаблона имени фильтра.
+func (f MetricFilter) Validate() error {
+	if _, err := path.Match(f.NamePattern, ""); err != nil {
+		return errors.New(MetricFilterUncorrect)
+	}
+	return nil
+}
+
+// Match проверяет, подходит ли метрика под фильтр.
+//
+// Параметры:
+//   - m: метрика
+func (f MetricFilter) Match(m entity.Metrics) bool {
+	if f.MType != "" && f.MType != m.MType {
+		return false
+	}
+	if f.NamePattern != "" {
+		if ok, err := path.Match(f.NamePattern, m.ID); err != nil || !ok {
+			return false
+		}
+	}
+	return strings.HasPrefix(m.ID, f.NamePrefix)
+}
+
+// List возвращает метрики, подходящие под фильтр, отсортированные по идентификатору с учётом меток.
+//
+// Параметры:
+//   - f: фильтр метрик
+func (s *Service) List(ctx context.Context, f MetricFilter) ([]entity.Metrics, error) {
+	all, err := s.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]entity.Metrics, 0, len(all))
+	for _, m := range all {
+		if f.Match(m) {
+			res = append(res, m)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Key() < res[j].Key()
+	})
+	return res, nil
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/repository"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/storage"
+)
+
+// Константы - границы интервала очистки устаревших метрик.
+const (
+	minJanitorInterval = time.Second // минимальный интервал очистки
+	maxJanitorInterval = time.Minute // максимальный интервал очистки
+)
+
+// RetentionRule задаёт время хранения метрик, имя которых подходит под шаблон.
+type RetentionRule struct {
+	Pattern string        // шаблон имени метрики в формате path.Match
+	TTL     time.Duration // время хранения с момента последнего изменения (0 - без ограничения)
+}
+
+// RetentionPolicy описывает время хранения метрик: метрика, не изменявшаяся дольше
+// времени хранения, удаляется. Для метрики применяется первое подходящее по имени правило,
+// а если подходящих правил нет - время хранения по умолчанию.
+type RetentionPolicy struct {
+	Rules   []RetentionRule // правила для отдельных метрик
+	Default time.Duration   // время хранения по умолчанию (0 - без ограничения)
+}
+
+// ParseRetentionRules разбирает правила хранения из строки вида "cpu_*=60,tmp_*=0".
+// Время хранения задаётся в секундах, 0 - без ограничения. Для пустой строки правил нет.
+//
+// Параметры:
+//   - s: правила через запятую в формате шаблон=секунды
+func ParseRetentionRules(s string) ([]RetentionRule, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(s, ",")
+	rules := make([]RetentionRule, 0, len(parts))
+	for _, part := range parts {
+		pattern, secs, ok := strings.Cut(strings.TrimSpace(part), "=")
+		pattern = strings.TrimSpace(pattern)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid retention rule %q", part)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid retention rule pattern %q: %w", pattern, err)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(secs), 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid retention rule ttl %q", secs)
+		}
+		rules = append(rules, RetentionRule{Pattern: pattern, TTL: time.Duration(n) * time.Second})
+	}
+	return rules, nil
+}
+
+// TTL возвращает время хранения метрики с указанным именем (0 - без ограничения).
+//
+// Параметры:
+//   - name: имя метрики
+func (p RetentionPolicy) TTL(name string) time.Duration {
+	for _, r := range p.Rules {
+		if ok, err := path.Match(r.Pattern, name); err == nil && ok {
+			return r.TTL
+		}
+	}
+	return p.Default
+}
+
+// janitorInterval возвращает интервал очистки: половину наименьшего времени хранения
+// в пределах [minJanitorInterval, maxJanitorInterval]. Возвращает 0, если время хранения не ограничено.
+func (p RetentionPolicy) janitorInterval() time.Duration {
+	least := p.Default
+	for _, r := range p.Rules {
+		if r.TTL > 0 && (least == 0 || r.TTL < least) {
+			least = r.TTL
+		}
+	}
+	if least == 0 {
+		return 0
+	}
+	return min(max(least/2, minJanitorInterval), maxJanitorInterval)
+}
+
+// SetRetention задаёт время хранения метрик. Устаревшие метрики удаляются в фоне после Start.
+//
+// Параметры:
+//   - p: время хранения метрик
+func (s *Service) SetRetention(p RetentionPolicy) *Service {
+	s.retention = p
+	return s
+}
+
+// Remove удаляет метрику.
+//
+// Параметры:
+//   - id: идентификатор метрики с учётом меток (entity.Metrics.Key)
+//   - t: тип метрики
+func (s *Service) Remove(ctx context.Context, id string, t string) error {
+	defer s.lockChanges()()
+
+	m, err := s.Get(ctx, id, t)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.InTransaction(ctx, func(ctx context.Context, tx repository.Repository) error {
+		if _, err := tx.Remove(ctx, m); err != nil {
+			s.reportStorageError(err.Error(), id)
+			return errors.New(UnexpectedMetricRemove)
+		}
+		return s.journalRemove(m)
+	})
+	if err != nil {
+		return err
+	}
+	s.reportMetricInfo("Storage remove value", m)
+
+	return s.afterRemove(ctx)
+}
+
+// removeExpired удаляет метрики, которые не изменялись дольше их времени хранения.
+// Перед удалением метрика перечитывается в транзакции, чтобы не удалить метрику, обновлённую во время очистки.
+// Возвращает количество удалённых метрик.
+func (s *Service) removeExpired(ctx context.Context) (int, error) {
+	defer s.lockChanges()()
+
+	all, err := s.repository.GetAll(ctx)
+	if err != nil {
+		return 0, errors.New(err.Error())
+	}
+
+	now := s.now()
+	expired := func(m entity.Metrics) bool {
+		ttl := s.retention.TTL(m.ID)
+		return ttl > 0 && !m.UpdatedAt.IsZero() && now.Sub(m.UpdatedAt) >= ttl
+	}
+
+	removed := make([]entity.Metrics, 0)
+	var removeErr error
+	for _, candidate := range all {
+		if !expired(candidate) {
+			continue
+		}
+		var m entity.Metrics
+		deleted := false
+		err := s.repository.InTransaction(ctx, func(ctx context.Context, tx repository.Repository) error {
+			var err error
+			m, err = tx.GetByID(ctx, candidate.Key())
+			if err != nil || !expired(m) {
+				// метрика удалена или обновлена после чтения
+				return nil
+			}
+			if _, err := tx.Remove(ctx, m); err != nil {
+				return errors.New(err.Error())
+			}
+			if err := s.journalRemove(m); err != nil {
+				return err
+			}
+			deleted = true
+			return nil
+		})
+		if err != nil {
+			s.reportStorageError(err.Error(), candidate.Key())
+			removeErr = errors.New(UnexpectedMetricRemove)
+			break
+		}
+		// удаление учитывается только после успешной фиксации транзакции
+		if deleted {
+			removed = append(removed, m)
+		}
+	}
+
+	if len(removed) > 0 {
+		// удалённые до ошибки метрики уже записаны в журнал
+		if err := s.afterRemove(ctx); err != nil && removeErr == nil {
+			removeErr = err
+		}
+	}
+	return len(removed), removeErr
+}
+
+// autoRemoveExpired периодически удаляет устаревшие метрики до отмены ctx.
+func (s *Service) autoRemoveExpired(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		removed, err := s.removeExpired(ctx)
+		if err != nil {
+			s.log.Error("Remove expired metrics error", err)
+		}
+		if removed > 0 {
+			s.log.Info("Remove expired metrics is success", "count", removed)
+		}
+	}
+}
+
+// journalRemove записывает удаление метрик в журнал изменений внутри транзакции.
+func (s *Service) journalRemove(ms ...entity.Metrics) error {
+	if err := s.appendJournal(storage.OperationRemove, ms); err != nil {
+		return errors.New(UnexpectedMetricRemove)
+	}
+	return nil
+}
+
+// afterRemove сжимает журнал изменений
+// или синхронно сохраняет данные в хранилище, если интервал сохранения 0.
+func (s *Service) afterRemove(ctx context.Context) error {
+	if s.journal != nil {
+		s.compactJournal(ctx)
+	} else if s.storage != nil && s.storSaveInterval == 0 {
+		if err := s.saveDataWithoutInterval(ctx); err != nil {
+			return errors.New(UnexpectedMetricRemove)
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ package service
 import (
 	"context"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
@@ -15,10 +16,18 @@ import (
 // Service представляет основную логику приложения.
 // Использует или репозиторий или хранилище для хранения данных.
 type Service struct {
-	repository       repository.Repository // репозиторий
-	storage          storage.Storage       // хранилище
-	log              logger.Logger         // логгер
-	storSaveInterval int64                 // интервал сохранения данных (в секундах)
+	repository       repository.Repository        // репозиторий
+	history          repository.HistoryRepository // репозиторий истории значений (nil, если история отключена)
+	storage          storage.Storage              // хранилище
+	journal          storage.Journal              // журнал изменений (nil, если хранилище его не ведёт)
+	log              logger.Logger                // логгер
+	hub              *hub                         // рассылка событий изменения метрик подписчикам
+	now              func() time.Time             // источник текущего времени
+	stopJanitor      context.CancelFunc           // останавливает очистку устаревших метрик (nil, если не запущена)
+	retention        RetentionPolicy              // время хранения метрик
+	histogramBounds  []float64                    // границы корзин для новых гистограмм
+	storSaveInterval int64                        // интервал сохранения данных (в секундах)
+	changeMu         sync.Mutex                   // упорядочивает изменения репозитория, записи журнала и события
 }
 
 // Константы - основные ошибки сервиса.
@@ -27,9 +36,18 @@ const (
 	MetricNotFound         = repository.ErrorMetricNotFound // ошибка, метрика не найдена
 	UnexpectedMetricCreate = "create error"                 // ошибка создания метрики
 	UnexpectedMetricUpdate = "update error"                 // ошибка обновления значения метрики
+	UnexpectedMetricRemove = "remove error"                 // ошибка удаления метрики
+	HistoryDisabled        = "history is disabled"          // ошибка, история значений не ведётся
+	HistoryUncorrect       = "invalid history query"        // ошибка, некорректный запрос истории
+	MetricFilterUncorrect  = "invalid metric filter"        // ошибка, некорректный фильтр метрик
 )
 
+// journalCompactSize - размер журнала изменений (в байтах), после которого снимок сохраняется вне интервала.
+const journalCompactSize int64 = 4 << 20
+
 // New создаёт и инициализирует новый экзепляр *Service.
+// Если хранилище ведёт журнал изменений (storage.Journal), каждое изменение записывается в журнал,
+// а данные целиком сохраняются раз в интервал или при переполнении журнала.
 //
 // Параметры:
 //   - r: репозиторий с данными
@@ -41,25 +59,49 @@ func New(r repository.Repository, s storage.Storage, strInterval int64, l logger
 		repository:       r,
 		storage:          s,
 		log:              l,
+		hub:              newHub(subscriberBufferSize),
+		now:              time.Now,
+		histogramBounds:  entity.DefaultHistogramBounds,
 		storSaveInterval: strInterval,
 	}
+	srvc.journal, _ = s.(storage.Journal)
 
 	return &srvc
 }
 
+// SetHistory включает сохранение истории значений метрик в указанный репозиторий.
+//
+// Параметры:
+//   - h: репозиторий истории значений
+func (s *Service) SetHistory(h repository.HistoryRepository) *Service {
+	s.history = h
+	return s
+}
+
+// SetHistogramBuckets задаёт границы корзин для гистограмм, создаваемых из отдельных наблюдений.
+//
+// Параметры:
+//   - bounds: верхние границы корзин (строго по возрастанию)
+func (s *Service) SetHistogramBuckets(bounds []float64) *Service {
+	s.histogramBounds = bounds
+	return s
+}
+
 // Start запускает основную логику приложения.
 //
 // Параметры:
 //   - restoreData: флаг, указывающий загружать ли данные при старте
 func (s *Service) Start(restoreData bool) {
 	ctx := context.Background()
-	if s.storage != nil && restoreData {
+	if s.journal != nil {
+		s.restoreJournal(ctx, restoreData)
+	} else if s.storage != nil && restoreData {
 		data, serr := s.storage.LoadData()
 		if serr != nil {
 			s.log.Error("Load data from storage error", serr)
 		}
 		for _, val := range data {
-			_, err := s.CreateOrUpdate(ctx, val)
+			_, err := s.CreateOrUpdate(withRestore(ctx), val)
 			if err != nil {
 				s.log.Error("Set data to repository error", err)
 			}
@@ -73,11 +115,23 @@ func (s *Service) Start(restoreData bool) {
 	if s.storage != nil && s.storSaveInterval != 0 {
 		go s.autoSaveDataWithInterval(ctx, s.storSaveInterval)
 	}
+
+	if interval := s.retention.janitorInterval(); interval > 0 {
+		janitorCtx, cancel := context.WithCancel(ctx)
+		s.stopJanitor = cancel
+		go s.autoRemoveExpired(janitorCtx, interval)
+	}
 }
 
 // Stop останавливает основную логику приложения.
 func (s *Service) Stop() {
+	if s.stopJanitor != nil {
+		s.stopJanitor()
+	}
+
+	unlock := s.lockChanges()
 	err := s.saveDataWithoutInterval(context.Background())
+	unlock()
 	if err != nil {
 		s.log.Error("Stop service error", err)
 	}
@@ -104,7 +158,7 @@ func (s *Service) GetAll(ctx context.Context) ([]entity.Metrics, error) {
 // Get получает одну метрику.
 //
 // Параметры:
-//   - id: идентификатор метрики
+//   - id: идентификатор метрики с учётом меток (entity.Metrics.Key)
 //   - t: тип метрики
 func (s *Service) Get(ctx context.Context, id string, t string) (entity.Metrics, error) {
 	m, err := s.repository.GetByID(ctx, id)
@@ -126,62 +180,210 @@ func (s *Service) Get(ctx context.Context, id string, t string) (entity.Metrics,
 // Параметры:
 //   - e: метрика
 func (s *Service) CreateOrUpdate(ctx context.Context, e entity.Metrics) (entity.Metrics, error) {
-	m, err := s.repository.GetByID(ctx, e.ID)
+	defer s.lockChanges()()
+
+	var m entity.Metrics
+	err := s.repository.InTransaction(ctx, func(ctx context.Context, tx repository.Repository) error {
+		var err error
+		if m, err = s.apply(ctx, tx, e); err != nil {
+			return err
+		}
+		return s.journalUpdate(m)
+	})
+	if err != nil {
+		return entity.Metrics{}, err
+	}
+
+	if err := s.afterApply(ctx, m); err != nil {
+		return entity.Metrics{}, err
+	}
+	return m, nil
+}
+
+// CreateOrUpdateBatch обновляет значения набора метрик атомарно:
+// либо применяются все метрики набора, либо ни одна из них.
+// Если метрика не была создана - создаёт её. Если репозиторий поддерживает пакетную запись
+// (repository.BulkRepository), набор без гистограмм записывается одним запросом.
+//
+// Параметры:
+//   - es: набор метрик
+func (s *Service) CreateOrUpdateBatch(ctx context.Context, es []entity.Metrics) ([]entity.Metrics, error) {
+	defer s.lockChanges()()
+
+	if bulk, ok := s.bulkRepository(es); ok {
+		applied, err := s.upsertBatch(ctx, bulk, es)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.afterApply(ctx, applied...); err != nil {
+			return nil, err
+		}
+		return applied, nil
+	}
+
+	applied := make([]entity.Metrics, 0, len(es))
+	err := s.repository.InTransaction(ctx, func(ctx context.Context, tx repository.Repository) error {
+		applied = applied[:0]
+		for _, e := range es {
+			m, err := s.apply(ctx, tx, e)
+			if err != nil {
+				return err
+			}
+			applied = append(applied, m)
+		}
+		return s.journalUpdate(applied...)
+	})
+	if err != nil {
+		s.reportStorageError(err.Error(), "")
+		return nil, errors.New(err.Error())
+	}
+
+	if err := s.afterApply(ctx, applied...); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// apply применяет значение метрики в указанном репозитории и возвращает итоговое значение.
+func (s *Service) apply(ctx context.Context, repo repository.Repository, e entity.Metrics) (entity.Metrics, error) {
+	if (e.MType == entity.Gauge && e.Value == nil) || (e.MType == entity.Counter && e.Delta == nil) {
+		s.reportStorageError(MetricUncorrect, e.MType)
+		return entity.Metrics{}, errors.New(MetricUncorrect)
+	}
+	if !isRestore(ctx) || e.UpdatedAt.IsZero() {
+		e.UpdatedAt = s.now().UTC()
+	}
+	m, err := repo.GetByID(ctx, e.Key())
 	if err != nil {
-		_, iErr := s.repository.Create(ctx, e)
+		if e.MType == entity.Histogram {
+			h, ok := s.mergeHistogram(nil, e)
+			if !ok {
+				s.reportStorageError(MetricUncorrect, e.MType)
+				return entity.Metrics{}, errors.New(MetricUncorrect)
+			}
+			e.Histogram = h
+			e.Value = nil
+			e.Delta = nil
+		}
+		if e.MType == entity.Counter && e.Delta != nil {
+			e.Sources = addSource(e.Sources, SourceFromContext(ctx), *e.Delta)
+		} else {
+			e.Sources = nil
+		}
+		_, iErr := repo.Create(ctx, e)
 		if iErr != nil {
 			s.reportStorageError(iErr.Error(), "")
 			return entity.Metrics{}, errors.New(UnexpectedMetricCreate)
 		}
 		s.reportMetricInfo("Storage create value", e)
 		return e, nil
-	} else {
-		if e.MType != m.MType {
+	}
+
+	if e.MType != m.MType {
+		s.reportStorageError(MetricUncorrect, e.MType)
+		return entity.Metrics{}, errors.New(MetricUncorrect)
+	}
+	e.Sources = nil
+	switch e.MType {
+	case entity.Gauge:
+		// значение gauge заменяется без преобразований
+	case entity.Counter:
+		if e.Delta == nil || m.Delta == nil {
 			s.reportStorageError(MetricUncorrect, e.MType)
 			return entity.Metrics{}, errors.New(MetricUncorrect)
 		}
-		if e.MType == entity.Gauge {
-			ival, idel, iErr := s.repository.Update(ctx, e)
-			if iErr != nil {
-				s.reportStorageError(iErr.Error(), "")
-				return entity.Metrics{}, errors.New(UnexpectedMetricUpdate)
-			}
+		e.Sources = addSource(m.Sources, SourceFromContext(ctx), *e.Delta)
+		val := *m.Delta + *e.Delta
+		e.Delta = &val
+	case entity.Histogram:
+		h, ok := s.mergeHistogram(m.Histogram, e)
+		if !ok {
+			s.reportStorageError(MetricUncorrect, e.MType)
+			return entity.Metrics{}, errors.New(MetricUncorrect)
+		}
+		e.Histogram = h
+		e.Value = nil
+		e.Delta = nil
+	default:
+		s.reportStorageError(MetricUncorrect, e.MType)
+		return entity.Metrics{}, errors.New(MetricUncorrect)
+	}
 
-			if s.storage != nil && s.storSaveInterval == 0 {
-				err := s.saveDataWithoutInterval(ctx)
-				if err != nil {
-					return entity.Metrics{}, errors.New(UnexpectedMetricUpdate)
-				}
-			}
+	ival, idel, iErr := repo.Update(ctx, e)
+	if iErr != nil {
+		s.reportStorageError(iErr.Error(), "")
+		return entity.Metrics{}, errors.New(UnexpectedMetricUpdate)
+	}
+	if e.MType != entity.Histogram {
+		e.Value = &ival
+		e.Delta = &idel
+	}
+	s.reportMetricInfo("Storage update value", e)
+	return e, nil
+}
 
-			e.Value = &ival
-			e.Delta = &idel
-			s.reportMetricInfo("Storage update value", e)
-			return e, nil
-		}
-		if e.MType == entity.Counter {
-			val := *m.Delta + *e.Delta
-			e.Delta = &val
-			ival, idel, iErr := s.repository.Update(ctx, e)
-			if iErr != nil {
-				s.reportStorageError(iErr.Error(), "")
-				return entity.Metrics{}, errors.New(UnexpectedMetricUpdate)
-			}
+// journalUpdate записывает итоговые значения метрик в журнал изменений внутри транзакции.
+func (s *Service) journalUpdate(ms ...entity.Metrics) error {
+	if err := s.appendJournal(storage.OperationUpdate, ms); err != nil {
+		return errors.New(UnexpectedMetricUpdate)
+	}
+	return nil
+}
 
-			if s.storage != nil && s.storSaveInterval == 0 {
-				err := s.saveDataWithoutInterval(ctx)
-				if err != nil {
-					return entity.Metrics{}, errors.New(UnexpectedMetricUpdate)
-				}
-			}
+// lockChanges захватывает блокировку изменений метрик и возвращает функцию её освобождения.
+// Изменение репозитория, его запись в журнал и публикация событий подписчикам выполняются
+// под одной блокировкой, чтобы порядок записей журнала и событий совпадал с порядком изменений.
+func (s *Service) lockChanges() func() {
+	s.changeMu.Lock()
+	return s.changeMu.Unlock
+}
 
-			e.Value = &ival
-			e.Delta = &idel
-			s.reportMetricInfo("Storage update value", e)
-			return e, nil
+// afterApply выполняет действия после успешного применения метрик: сжатие журнала изменений
+// или синхронное сохранение в хранилище (если интервал сохранения 0), запись истории и публикация событий подписчикам.
+// Вызывается под блокировкой изменений, поэтому события публикуются в порядке применения.
+func (s *Service) afterApply(ctx context.Context, ms ...entity.Metrics) error {
+	if s.journal != nil {
+		s.compactJournal(ctx)
+	} else if s.storage != nil && s.storSaveInterval == 0 {
+		err := s.saveDataWithoutInterval(ctx)
+		if err != nil {
+			return errors.New(UnexpectedMetricUpdate)
 		}
-		s.reportStorageError(MetricUncorrect, e.MType)
-		return entity.Metrics{}, errors.New(MetricUncorrect)
+	}
+
+	for _, m := range ms {
+		s.recordHistory(ctx, m)
+	}
+	s.hub.publish(ms...)
+	return nil
+}
+
+// mergeHistogram применяет обновление гистограммы к текущему значению.
+// Обновление содержит либо гистограмму целиком (e.Histogram), которая складывается с текущей,
+// либо одно наблюдение (e.Value), которое добавляется в соответствующую корзину.
+// Возвращает false, если обновление некорректно или границы корзин не совпадают.
+func (s *Service) mergeHistogram(current *entity.HistogramData, e entity.Metrics) (*entity.HistogramData, bool) {
+	if current == nil {
+		current = entity.NewHistogram(s.histogramBounds)
+		if e.Histogram != nil {
+			current = entity.NewHistogram(e.Histogram.Bounds)
+		}
+	}
+
+	switch {
+	case e.Histogram != nil:
+		if !e.Histogram.IsValid() || !current.SameBounds(e.Histogram) {
+			return nil, false
+		}
+		return current.Merge(e.Histogram), true
+	case e.Value != nil:
+		h := current.Merge(entity.NewHistogram(current.Bounds))
+		if !h.Observe(*e.Value) {
+			return nil, false
+		}
+		return h, true
+	default:
+		return nil, false
 	}
 }
 
@@ -189,6 +391,7 @@ func (s *Service) autoSaveDataWithInterval(ctx context.Context, interval int64)
 	t := time.Tick(time.Duration(interval) * time.Second)
 
 	for range t {
+		unlock := s.lockChanges()
 		data, rerr := s.repository.GetAll(ctx)
 		if rerr != nil {
 			s.log.Error("Get data from repository error", rerr)
@@ -203,6 +406,7 @@ func (s *Service) autoSaveDataWithInterval(ctx context.Context, interval int64)
 				"time", time.Now(),
 			)
 		}
+		unlock()
 	}
 }
 
@@ -244,7 +448,7 @@ func (s *Service) reportStorageError(text string, value string) {
 func (s *Service) reportMetricInfo(t string, m entity.Metrics) {
 	s.log.Debug(
 		t,
-		"name", m.ID,
+		"name", m.Key(),
 		"type", m.MType,
 		"value", m.Value,
 		"delta", m.Delta,
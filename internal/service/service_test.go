@@ -3,13 +3,18 @@ package service
 import (
 	"context"
 	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
 	logger "github.com/Mr-Filatik/go-metrics-collector/internal/logger/zap/sugar"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/repository"
+	repositoryMemory "github.com/Mr-Filatik/go-metrics-collector/internal/repository/memory"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/storage"
+	storageWAL "github.com/Mr-Filatik/go-metrics-collector/internal/storage/wal"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,6 +28,8 @@ type MockRepository struct {
 	UpdateFunc  func(ctx context.Context, e entity.Metrics) (float64, int64, error)
 	RemoveFunc  func(ctx context.Context, e entity.Metrics) (string, error)
 	PingFunc    func(ctx context.Context) error
+
+	InTransactionFunc func(ctx context.Context, fn func(ctx context.Context, tx repository.Repository) error) error
 }
 
 func (m MockRepository) GetByID(ctx context.Context, id string) (entity.Metrics, error) {
@@ -49,6 +56,33 @@ func (m MockRepository) Ping(ctx context.Context) error {
 	return m.PingFunc(ctx)
 }
 
+func (m MockRepository) InTransaction(
+	ctx context.Context,
+	fn func(ctx context.Context, tx repository.Repository) error,
+) error {
+	if m.InTransactionFunc == nil {
+		return fn(ctx, m)
+	}
+	return m.InTransactionFunc(ctx, fn)
+}
+
+var _ repository.HistoryRepository = (*MockHistoryRepository)(nil)
+
+// MockHistoryRepository — реализация HistoryRepository для тестов.
+type MockHistoryRepository struct {
+	AddPointFunc   func(ctx context.Context, id string, p entity.MetricPoint) error
+	GetHistoryFunc func(ctx context.Context, id string, from, to time.Time) ([]entity.MetricPoint, error)
+}
+
+func (m MockHistoryRepository) AddPoint(ctx context.Context, id string, p entity.MetricPoint) error {
+	return m.AddPointFunc(ctx, id, p)
+}
+
+func (m MockHistoryRepository) GetHistory(
+	ctx context.Context, id string, from, to time.Time) ([]entity.MetricPoint, error) {
+	return m.GetHistoryFunc(ctx, id, from, to)
+}
+
 var _ storage.Storage = (*MockStorage)(nil)
 
 // MockStorage — реализация Storage для тестов.
@@ -221,3 +255,844 @@ func TestPing_WithErrors(t *testing.T) {
 
 	assert.Error(t, err)
 }
+
+func TestGetHistory(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	ctx := context.Background()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mockRepo := MockRepository{
+		GetByIDFunc: func(ctx context.Context, id string) (entity.Metrics, error) {
+			return entity.Metrics{ID: id, MType: entity.Gauge, Value: new(float64)}, nil
+		},
+	}
+	mockHistory := MockHistoryRepository{
+		GetHistoryFunc: func(ctx context.Context, id string, from, to time.Time) ([]entity.MetricPoint, error) {
+			return []entity.MetricPoint{
+				{Time: start, Value: 1},
+				{Time: start.Add(20 * time.Second), Value: 5},
+				{Time: start.Add(40 * time.Second), Value: 3},
+				{Time: start.Add(70 * time.Second), Value: 10},
+			}, nil
+		},
+	}
+
+	t.Run("history disabled", func(t *testing.T) {
+		s := New(&mockRepo, nil, 0, log)
+		_, err := s.GetHistory(ctx, HistoryQuery{ID: "g", MType: entity.Gauge, Aggregation: AggregationAvg})
+		assert.EqualError(t, err, HistoryDisabled)
+	})
+
+	t.Run("without step", func(t *testing.T) {
+		s := New(&mockRepo, nil, 0, log).SetHistory(&mockHistory)
+		points, err := s.GetHistory(ctx, HistoryQuery{
+			ID: "g", MType: entity.Gauge, From: start, To: start.Add(time.Hour), Aggregation: AggregationAvg,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, points, 4)
+	})
+
+	tests := []struct {
+		agg      string
+		expected []float64
+	}{
+		{agg: AggregationAvg, expected: []float64{3, 10}},
+		{agg: AggregationMin, expected: []float64{1, 10}},
+		{agg: AggregationMax, expected: []float64{5, 10}},
+		{agg: AggregationLast, expected: []float64{3, 10}},
+	}
+	for _, tt := range tests {
+		t.Run("step with "+tt.agg, func(t *testing.T) {
+			s := New(&mockRepo, nil, 0, log).SetHistory(&mockHistory)
+			points, err := s.GetHistory(ctx, HistoryQuery{
+				ID: "g", MType: entity.Gauge, From: start, To: start.Add(time.Hour),
+				Step: time.Minute, Aggregation: tt.agg,
+			})
+			assert.NoError(t, err)
+			assert.Len(t, points, len(tt.expected))
+			for i := range tt.expected {
+				assert.Equal(t, tt.expected[i], points[i].Value)
+				assert.Equal(t, start.Add(time.Duration(i)*time.Minute), points[i].Time)
+			}
+		})
+	}
+
+	t.Run("invalid aggregation", func(t *testing.T) {
+		s := New(&mockRepo, nil, 0, log).SetHistory(&mockHistory)
+		_, err := s.GetHistory(ctx, HistoryQuery{ID: "g", MType: entity.Gauge, Aggregation: "median"})
+		assert.EqualError(t, err, HistoryUncorrect)
+	})
+
+	t.Run("step without from", func(t *testing.T) {
+		s := New(&mockRepo, nil, 0, log).SetHistory(&mockHistory)
+		_, err := s.GetHistory(ctx, HistoryQuery{
+			ID: "g", MType: entity.Gauge, To: start.Add(time.Hour), Step: time.Minute, Aggregation: AggregationAvg,
+		})
+		assert.EqualError(t, err, HistoryUncorrect)
+	})
+}
+
+func TestCreateOrUpdate_RecordsHistory(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	ctx := context.Background()
+
+	var recorded []entity.MetricPoint
+	mockRepo := MockRepository{
+		GetByIDFunc: func(ctx context.Context, id string) (entity.Metrics, error) {
+			delta := int64(5)
+			return entity.Metrics{ID: id, MType: entity.Counter, Delta: &delta}, nil
+		},
+		UpdateFunc: func(ctx context.Context, e entity.Metrics) (float64, int64, error) {
+			return 0, *e.Delta, nil
+		},
+	}
+	mockHistory := MockHistoryRepository{
+		AddPointFunc: func(ctx context.Context, id string, p entity.MetricPoint) error {
+			recorded = append(recorded, p)
+			return nil
+		},
+	}
+
+	s := New(&mockRepo, nil, 0, log).SetHistory(&mockHistory)
+	delta := int64(2)
+	_, err := s.CreateOrUpdate(ctx, entity.Metrics{ID: "counter1", MType: entity.Counter, Delta: &delta})
+
+	assert.NoError(t, err)
+	assert.Len(t, recorded, 1)
+	assert.Equal(t, 7.0, recorded[0].Value)
+}
+
+func TestCreateOrUpdate_Histogram(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	ctx := context.Background()
+
+	stored := map[string]entity.Metrics{}
+	mockRepo := MockRepository{
+		GetByIDFunc: func(ctx context.Context, id string) (entity.Metrics, error) {
+			m, ok := stored[id]
+			if !ok {
+				return entity.Metrics{}, errors.New(repository.ErrorMetricNotFound)
+			}
+			return m, nil
+		},
+		CreateFunc: func(ctx context.Context, e entity.Metrics) (string, error) {
+			stored[e.Key()] = e
+			return e.ID, nil
+		},
+		UpdateFunc: func(ctx context.Context, e entity.Metrics) (float64, int64, error) {
+			stored[e.Key()] = e
+			return 0, 0, nil
+		},
+	}
+
+	s := New(&mockRepo, nil, 0, log).SetHistogramBuckets([]float64{1, 5})
+
+	observation := 0.5
+	res, err := s.CreateOrUpdate(ctx, entity.Metrics{ID: "latency", MType: entity.Histogram, Value: &observation})
+	assert.NoError(t, err)
+	assert.Nil(t, res.Value)
+	assert.Equal(t, []int64{1, 0, 0}, res.Histogram.Counts)
+
+	observation = 7
+	_, err = s.CreateOrUpdate(ctx, entity.Metrics{ID: "latency", MType: entity.Histogram, Value: &observation})
+	assert.NoError(t, err)
+
+	batch := &entity.HistogramData{Bounds: []float64{1, 5}, Counts: []int64{0, 2, 0}, Sum: 6, Count: 2}
+	res, err = s.CreateOrUpdate(ctx, entity.Metrics{ID: "latency", MType: entity.Histogram, Histogram: batch})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 1}, res.Histogram.Counts)
+	assert.Equal(t, 13.5, res.Histogram.Sum)
+	assert.Equal(t, int64(4), res.Histogram.Count)
+
+	other := &entity.HistogramData{Bounds: []float64{2}, Counts: []int64{1, 0}, Sum: 1, Count: 1}
+	_, err = s.CreateOrUpdate(ctx, entity.Metrics{ID: "latency", MType: entity.Histogram, Histogram: other})
+	assert.EqualError(t, err, MetricUncorrect)
+
+	broken := &entity.HistogramData{Bounds: []float64{1}, Counts: []int64{1}, Sum: 1, Count: 1}
+	_, err = s.CreateOrUpdate(ctx, entity.Metrics{ID: "broken", MType: entity.Histogram, Histogram: broken})
+	assert.EqualError(t, err, MetricUncorrect)
+}
+
+func TestCreateOrUpdateBatch(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	ctx := context.Background()
+
+	t.Run("all metrics applied", func(t *testing.T) {
+		mockRepo := MockRepository{
+			GetByIDFunc: func(ctx context.Context, id string) (entity.Metrics, error) {
+				delta := int64(5)
+				return entity.Metrics{ID: id, MType: entity.Counter, Delta: &delta}, nil
+			},
+			UpdateFunc: func(ctx context.Context, e entity.Metrics) (float64, int64, error) {
+				return 0, *e.Delta, nil
+			},
+		}
+
+		s := New(&mockRepo, nil, 0, log)
+		d1, d2 := int64(1), int64(2)
+		res, err := s.CreateOrUpdateBatch(ctx, []entity.Metrics{
+			{ID: "c1", MType: entity.Counter, Delta: &d1},
+			{ID: "c2", MType: entity.Counter, Delta: &d2},
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, res, 2)
+		assert.Equal(t, int64(6), *res[0].Delta)
+		assert.Equal(t, int64(7), *res[1].Delta)
+	})
+
+	t.Run("error aborts transaction", func(t *testing.T) {
+		var recorded []entity.MetricPoint
+		var txErr error
+		mockRepo := MockRepository{
+			GetByIDFunc: func(ctx context.Context, id string) (entity.Metrics, error) {
+				delta := int64(5)
+				return entity.Metrics{ID: id, MType: entity.Counter, Delta: &delta}, nil
+			},
+			UpdateFunc: func(ctx context.Context, e entity.Metrics) (float64, int64, error) {
+				return 0, *e.Delta, nil
+			},
+		}
+		mockRepo.InTransactionFunc = func(
+			ctx context.Context,
+			fn func(ctx context.Context, tx repository.Repository) error,
+		) error {
+			txErr = fn(ctx, mockRepo)
+			return txErr
+		}
+		mockHistory := MockHistoryRepository{
+			AddPointFunc: func(ctx context.Context, id string, p entity.MetricPoint) error {
+				recorded = append(recorded, p)
+				return nil
+			},
+		}
+
+		s := New(&mockRepo, nil, 0, log).SetHistory(&mockHistory)
+		d1, v2 := int64(1), 2.0
+		res, err := s.CreateOrUpdateBatch(ctx, []entity.Metrics{
+			{ID: "c1", MType: entity.Counter, Delta: &d1},
+			{ID: "c2", MType: entity.Gauge, Value: &v2}, // тип не совпадает с сохранённым
+		})
+
+		assert.EqualError(t, err, MetricUncorrect)
+		assert.EqualError(t, txErr, MetricUncorrect)
+		assert.Nil(t, res)
+		assert.Empty(t, recorded, "history must not be written for a rolled back batch")
+	})
+}
+
+func TestSubscribe(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	mockRepo := MockRepository{
+		GetByIDFunc: func(ctx context.Context, id string) (entity.Metrics, error) {
+			return entity.Metrics{}, errors.New(MetricNotFound)
+		},
+		CreateFunc: func(ctx context.Context, e entity.Metrics) (string, error) {
+			return e.ID, nil
+		},
+	}
+	s := New(&mockRepo, nil, 0, log)
+	value, delta := 1.0, int64(1)
+
+	t.Run("filtered events are delivered until cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		events := s.Subscribe(ctx, MetricFilter{NamePattern: "Poll*", MType: entity.Counter})
+
+		for _, m := range []entity.Metrics{
+			{ID: "Alloc", MType: entity.Gauge, Value: &value},
+			{ID: "PollInterval", MType: entity.Gauge, Value: &value},
+			{ID: "PollCount", MType: entity.Counter, Delta: &delta},
+		} {
+			_, err := s.CreateOrUpdate(ctx, m)
+			assert.NoError(t, err)
+		}
+		e := <-events
+		assert.Equal(t, "PollCount", e.Metric.ID)
+		assert.Zero(t, e.Dropped)
+
+		cancel()
+		_, ok := <-events
+		assert.False(t, ok)
+	})
+
+	t.Run("slow subscriber gets drop count", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events := s.Subscribe(ctx, MetricFilter{})
+
+		for range subscriberBufferSize + 3 {
+			_, err := s.CreateOrUpdate(ctx, entity.Metrics{ID: "g1", MType: entity.Gauge, Value: &value})
+			assert.NoError(t, err)
+		}
+		for range subscriberBufferSize {
+			e := <-events
+			assert.Zero(t, e.Dropped)
+		}
+
+		_, err := s.CreateOrUpdate(ctx, entity.Metrics{ID: "g1", MType: entity.Gauge, Value: &value})
+		assert.NoError(t, err)
+		e := <-events
+		assert.Equal(t, uint64(3), e.Dropped)
+	})
+
+	t.Run("drop count delivered without further updates", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events := s.Subscribe(ctx, MetricFilter{})
+
+		for range subscriberBufferSize + 3 {
+			_, err := s.CreateOrUpdate(ctx, entity.Metrics{ID: "g1", MType: entity.Gauge, Value: &value})
+			assert.NoError(t, err)
+		}
+		for range subscriberBufferSize {
+			e := <-events
+			assert.Equal(t, "g1", e.Metric.ID)
+			assert.Zero(t, e.Dropped)
+		}
+
+		// новых изменений нет: пропуск доставляется отдельным событием без метрики
+		select {
+		case e := <-events:
+			assert.Empty(t, e.Metric.ID)
+			assert.Equal(t, uint64(3), e.Dropped)
+		case <-time.After(time.Second):
+			t.Fatal("drop event is not delivered")
+		}
+	})
+}
+
+// slowCommitRepository задерживает возврат из транзакции после фиксации,
+// чтобы конкурентные изменения успевали обогнать друг друга.
+type slowCommitRepository struct {
+	*repositoryMemory.MemoryRepository
+	commits atomic.Int64
+}
+
+func (r *slowCommitRepository) InTransaction(
+	ctx context.Context,
+	fn func(ctx context.Context, tx repository.Repository) error,
+) error {
+	err := r.MemoryRepository.InTransaction(ctx, fn)
+	if r.commits.Add(1)%2 == 1 {
+		time.Sleep(time.Millisecond)
+	}
+	return err
+}
+
+func TestSubscribe_CommitOrder(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	s := New(&slowCommitRepository{MemoryRepository: repositoryMemory.New("", log)}, nil, 0, log)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := s.Subscribe(ctx, MetricFilter{})
+
+	const writers = 100
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			delta := int64(1)
+			_, err := s.CreateOrUpdate(ctx, entity.Metrics{ID: "c1", MType: entity.Counter, Delta: &delta})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// каждое изменение счётчика даёт новое значение, поэтому события должны идти по возрастанию
+	for i := range writers {
+		e := <-events
+		assert.Equal(t, int64(i+1), *e.Metric.Delta)
+	}
+}
+
+func TestMetricFilter_Validate(t *testing.T) {
+	assert.NoError(t, MetricFilter{}.Validate())
+	assert.NoError(t, MetricFilter{NamePattern: "Poll*"}.Validate())
+	assert.EqualError(t, MetricFilter{NamePattern: "Poll["}.Validate(), MetricFilterUncorrect)
+}
+
+func TestList(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	v, d := 1.0, int64(1)
+	mockRepo := MockRepository{
+		GetAllFunc: func(ctx context.Context) ([]entity.Metrics, error) {
+			return []entity.Metrics{
+				{ID: "PollCount", MType: entity.Counter, Delta: &d},
+				{ID: "Alloc", MType: entity.Gauge, Value: &v, Labels: map[string]string{"host": "b"}},
+				{ID: "Alloc", MType: entity.Gauge, Value: &v},
+				{ID: "PollErrors", MType: entity.Counter, Delta: &d},
+			}, nil
+		},
+	}
+	s := New(&mockRepo, nil, 0, log)
+
+	keys := func(ms []entity.Metrics) []string {
+		res := make([]string, 0, len(ms))
+		for _, m := range ms {
+			res = append(res, m.Key())
+		}
+		return res
+	}
+	allocB := (&entity.Metrics{ID: "Alloc", Labels: map[string]string{"host": "b"}}).Key()
+
+	tests := []struct {
+		name     string
+		filter   MetricFilter
+		expected []string
+	}{
+		{name: "all", filter: MetricFilter{}, expected: []string{"Alloc", allocB, "PollCount", "PollErrors"}},
+		{name: "prefix", filter: MetricFilter{NamePrefix: "Poll"}, expected: []string{"PollCount", "PollErrors"}},
+		{name: "type", filter: MetricFilter{MType: entity.Gauge}, expected: []string{"Alloc", allocB}},
+		{name: "no match", filter: MetricFilter{NamePrefix: "Alloc", MType: entity.Counter}, expected: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := s.List(context.Background(), tt.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, keys(res))
+		})
+	}
+}
+
+func TestParseRetentionRules(t *testing.T) {
+	rules, err := ParseRetentionRules(" cpu_* = 60, tmp_*=0 ")
+	assert.NoError(t, err)
+	assert.Equal(t, []RetentionRule{
+		{Pattern: "cpu_*", TTL: time.Minute},
+		{Pattern: "tmp_*", TTL: 0},
+	}, rules)
+
+	rules, err = ParseRetentionRules("")
+	assert.NoError(t, err)
+	assert.Empty(t, rules)
+
+	for _, s := range []string{"cpu_*", "=60", "cpu_*=-1", "cpu_*=1m", "cpu_[=60"} {
+		_, err := ParseRetentionRules(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	p := RetentionPolicy{
+		Rules: []RetentionRule{
+			{Pattern: "Poll*", TTL: 0},
+			{Pattern: "*Alloc", TTL: 10 * time.Second},
+		},
+		Default: time.Hour,
+	}
+	assert.Equal(t, time.Duration(0), p.TTL("PollCount"))
+	assert.Equal(t, 10*time.Second, p.TTL("HeapAlloc"))
+	assert.Equal(t, time.Hour, p.TTL("RandomValue"))
+
+	assert.Equal(t, 5*time.Second, p.janitorInterval())
+	assert.Equal(t, time.Minute, RetentionPolicy{Default: time.Hour}.janitorInterval())
+	assert.Equal(t, time.Second, RetentionPolicy{Default: time.Second}.janitorInterval())
+	assert.Zero(t, RetentionPolicy{Rules: []RetentionRule{{Pattern: "*"}}}.janitorInterval())
+}
+
+func TestRemoveExpired(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s := New(repositoryMemory.New("", log), nil, 0, log).SetRetention(RetentionPolicy{
+		Rules:   []RetentionRule{{Pattern: "Poll*", TTL: 0}},
+		Default: time.Minute,
+	})
+	s.now = func() time.Time { return now }
+
+	v, d := 1.0, int64(1)
+	for _, m := range []entity.Metrics{
+		{ID: "Alloc", MType: entity.Gauge, Value: &v},
+		{ID: "Alloc", MType: entity.Gauge, Value: &v, Labels: map[string]string{"host": "a"}},
+		{ID: "PollCount", MType: entity.Counter, Delta: &d},
+	} {
+		_, err := s.CreateOrUpdate(ctx, m)
+		assert.NoError(t, err)
+	}
+
+	now = now.Add(30 * time.Second)
+	_, err := s.CreateOrUpdate(ctx, entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: &v})
+	assert.NoError(t, err)
+
+	m, err := s.Get(ctx, "Alloc", entity.Gauge)
+	assert.NoError(t, err)
+	assert.Equal(t, now, m.UpdatedAt)
+
+	now = now.Add(45 * time.Second)
+	removed, err := s.removeExpired(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	all, err := s.List(ctx, MetricFilter{})
+	assert.NoError(t, err)
+	keys := make([]string, 0, len(all))
+	for _, m := range all {
+		keys = append(keys, m.Key())
+	}
+	assert.Equal(t, []string{"Alloc", "PollCount"}, keys)
+}
+
+func TestRemoveExpired_CommitError(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	v := 1.0
+	stale := entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: &v, UpdatedAt: now.Add(-time.Hour)}
+
+	mockRepo := MockRepository{
+		GetAllFunc: func(ctx context.Context) ([]entity.Metrics, error) {
+			return []entity.Metrics{stale}, nil
+		},
+		GetByIDFunc: func(ctx context.Context, id string) (entity.Metrics, error) {
+			return stale, nil
+		},
+		RemoveFunc: func(ctx context.Context, e entity.Metrics) (string, error) {
+			return e.ID, nil
+		},
+	}
+	// изменения внутри транзакции прошли, но фиксация не удалась
+	mockRepo.InTransactionFunc = func(
+		ctx context.Context,
+		fn func(ctx context.Context, tx repository.Repository) error,
+	) error {
+		if err := fn(ctx, mockRepo); err != nil {
+			return err
+		}
+		return errors.New("commit failed")
+	}
+	s := New(&mockRepo, nil, 0, log).SetRetention(RetentionPolicy{Default: time.Minute})
+	s.now = func() time.Time { return now }
+
+	removed, err := s.removeExpired(ctx)
+	assert.EqualError(t, err, UnexpectedMetricRemove)
+	assert.Zero(t, removed, "metric must not be counted as removed when commit fails")
+}
+
+func TestRemove(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	ctx := context.Background()
+	saved := 0
+	s := New(repositoryMemory.New("", log), &MockStorage{
+		SaveDataFunc: func(data []entity.Metrics) error {
+			saved = len(data)
+			return nil
+		},
+	}, 0, log)
+
+	v := 1.0
+	_, err := s.CreateOrUpdate(ctx, entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: &v})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, saved)
+
+	assert.EqualError(t, s.Remove(ctx, "Alloc", entity.Counter), MetricUncorrect)
+	assert.NoError(t, s.Remove(ctx, "Alloc", entity.Gauge))
+	assert.Equal(t, 0, saved)
+	assert.EqualError(t, s.Remove(ctx, "Alloc", entity.Gauge), MetricNotFound)
+}
+
+var _ repository.BulkRepository = (*MockBulkRepository)(nil)
+
+// MockBulkRepository — реализация BulkRepository для тестов.
+type MockBulkRepository struct {
+	UpsertFunc func(ctx context.Context, es []entity.Metrics) ([]entity.Metrics, error)
+	MockRepository
+}
+
+func (m MockBulkRepository) Upsert(ctx context.Context, es []entity.Metrics) ([]entity.Metrics, error) {
+	return m.UpsertFunc(ctx, es)
+}
+
+func TestCreateOrUpdateBatch_Bulk(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	ctx := context.Background()
+	d1, d2, v1, v2 := int64(1), int64(2), 1.0, 2.0
+
+	t.Run("duplicates merged into one upsert", func(t *testing.T) {
+		var upserted []entity.Metrics
+		mockRepo := MockBulkRepository{
+			UpsertFunc: func(ctx context.Context, es []entity.Metrics) ([]entity.Metrics, error) {
+				upserted = es
+				res := make([]entity.Metrics, 0, len(es))
+				for _, e := range es {
+					if e.MType == entity.Counter {
+						delta := *e.Delta + 10
+						e.Delta = &delta
+					}
+					res = append(res, e)
+				}
+				return res, nil
+			},
+		}
+
+		s := New(&mockRepo, nil, 0, log)
+		res, err := s.CreateOrUpdateBatch(ctx, []entity.Metrics{
+			{ID: "c1", MType: entity.Counter, Delta: &d1},
+			{ID: "g1", MType: entity.Gauge, Value: &v1},
+			{ID: "c1", MType: entity.Counter, Delta: &d2},
+			{ID: "g1", MType: entity.Gauge, Value: &v2},
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, upserted, 2)
+		assert.Len(t, res, 2)
+		assert.Equal(t, "c1", res[0].ID)
+		assert.Equal(t, int64(13), *res[0].Delta)
+		assert.Equal(t, "g1", res[1].ID)
+		assert.Equal(t, 2.0, *res[1].Value)
+		assert.Equal(t, int64(1), d1, "input metrics must not be modified")
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		mockRepo := MockBulkRepository{
+			UpsertFunc: func(ctx context.Context, es []entity.Metrics) ([]entity.Metrics, error) {
+				return nil, errors.New(repository.ErrorMetricTypeMismatch)
+			},
+		}
+
+		s := New(&mockRepo, nil, 0, log)
+		_, err := s.CreateOrUpdateBatch(ctx, []entity.Metrics{{ID: "c1", MType: entity.Counter, Delta: &d1}})
+		assert.EqualError(t, err, MetricUncorrect)
+
+		_, err = s.CreateOrUpdateBatch(ctx, []entity.Metrics{
+			{ID: "m1", MType: entity.Counter, Delta: &d1},
+			{ID: "m1", MType: entity.Gauge, Value: &v1},
+		})
+		assert.EqualError(t, err, MetricUncorrect)
+	})
+
+	t.Run("histograms use per-metric path", func(t *testing.T) {
+		created := 0
+		mockRepo := MockBulkRepository{
+			UpsertFunc: func(ctx context.Context, es []entity.Metrics) ([]entity.Metrics, error) {
+				t.Fatal("upsert must not be used for histograms")
+				return nil, nil
+			},
+			MockRepository: MockRepository{
+				GetByIDFunc: func(ctx context.Context, id string) (entity.Metrics, error) {
+					return entity.Metrics{}, errors.New(MetricNotFound)
+				},
+				CreateFunc: func(ctx context.Context, e entity.Metrics) (string, error) {
+					created++
+					return e.ID, nil
+				},
+			},
+		}
+
+		s := New(&mockRepo, nil, 0, log)
+		_, err := s.CreateOrUpdateBatch(ctx, []entity.Metrics{
+			{ID: "c1", MType: entity.Counter, Delta: &d1},
+			{ID: "h1", MType: entity.Histogram, Value: &v1},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, created)
+	})
+
+	t.Run("source merged into upsert", func(t *testing.T) {
+		var upserted []entity.Metrics
+		mockRepo := MockBulkRepository{
+			UpsertFunc: func(ctx context.Context, es []entity.Metrics) ([]entity.Metrics, error) {
+				upserted = es
+				return es, nil
+			},
+		}
+
+		s := New(&mockRepo, nil, 0, log)
+		_, err := s.CreateOrUpdateBatch(WithSource(ctx, "agent-1"), []entity.Metrics{
+			{ID: "c1", MType: entity.Counter, Delta: &d1, Sources: map[string]int64{"forged": 5}},
+			{ID: "g1", MType: entity.Gauge, Value: &v1, Sources: map[string]int64{"forged": 5}},
+			{ID: "c1", MType: entity.Counter, Delta: &d2},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, upserted, 2)
+		assert.Equal(t, map[string]int64{"agent-1": 3}, upserted[0].Sources)
+		assert.Nil(t, upserted[1].Sources)
+	})
+}
+
+func TestCreateOrUpdate_Sources(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	ctx := context.Background()
+	s := New(repositoryMemory.New("", log), nil, 300, log)
+
+	d1, d2, d3, v := int64(1), int64(2), int64(4), 1.5
+	updates := []struct {
+		source string
+		delta  *int64
+	}{{"agent-1", &d1}, {"agent-2", &d2}, {"agent-1", &d2}}
+	for _, u := range updates {
+		e := entity.Metrics{ID: "PollCount", MType: entity.Counter, Delta: u.delta}
+		_, err := s.CreateOrUpdate(WithSource(ctx, u.source), e)
+		assert.NoError(t, err)
+	}
+	// обновление без источника учитывается только в общем значении
+	m, err := s.CreateOrUpdate(ctx, entity.Metrics{ID: "PollCount", MType: entity.Counter, Delta: &d3})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), *m.Delta)
+	assert.Equal(t, map[string]int64{"agent-1": 3, "agent-2": 2}, m.Sources)
+
+	got, err := s.Get(ctx, "PollCount", entity.Counter)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"agent-1": 3, "agent-2": 2}, got.Sources)
+
+	// для gauge разбивка по источникам не ведётся
+	g, err := s.CreateOrUpdate(WithSource(ctx, "agent-1"), entity.Metrics{
+		ID: "Alloc", MType: entity.Gauge, Value: &v, Sources: map[string]int64{"x": 1},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, g.Sources)
+}
+
+func TestStart_RestoreJournal(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	journal, err := storageWAL.New(path, log)
+	assert.NoError(t, err)
+	s := New(repositoryMemory.New("", log), journal, 300, log)
+	s.Start(false)
+
+	v, d := 1.5, int64(2)
+	for range 3 {
+		_, err = s.CreateOrUpdate(ctx, entity.Metrics{ID: "PollCount", MType: entity.Counter, Delta: &d})
+		assert.NoError(t, err)
+	}
+	_, err = s.CreateOrUpdateBatch(ctx, []entity.Metrics{
+		{ID: "Alloc", MType: entity.Gauge, Value: &v},
+		{ID: "Frees", MType: entity.Gauge, Value: &v},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Remove(ctx, "Frees", entity.Gauge))
+	assert.Positive(t, journal.Size())
+
+	// сбой: сервис не остановлен, снимок не сохранён, изменения есть только в журнале
+	assert.NoError(t, journal.Close())
+
+	journal, err = storageWAL.New(path, log)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, journal.Close())
+	}()
+	restored := New(repositoryMemory.New("", log), journal, 300, log)
+	restored.Start(true)
+	defer restored.Stop()
+
+	all, err := restored.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+	m, err := restored.Get(ctx, "PollCount", entity.Counter)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), *m.Delta)
+	m, err = restored.Get(ctx, "Alloc", entity.Gauge)
+	assert.NoError(t, err)
+	assert.Equal(t, v, *m.Value)
+
+	// после восстановления данные сохранены в снимок, журнал очищен
+	assert.Equal(t, int64(0), journal.Size())
+}
+
+// failingJournal - журнал изменений, запись в который завершается ошибкой после включения fail.
+type failingJournal struct {
+	storage.Journal
+	fail bool
+}
+
+func (j *failingJournal) Append(records ...storage.Record) error {
+	if j.fail {
+		return errors.New("disk is full")
+	}
+	return j.Journal.Append(records...)
+}
+
+func TestJournalAppendError_RollsBack(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	ctx := context.Background()
+
+	wal, err := storageWAL.New(filepath.Join(t.TempDir(), "metrics.json"), log)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, wal.Close())
+	}()
+	journal := &failingJournal{Journal: wal}
+	s := New(repositoryMemory.New("", log), journal, 300, log)
+
+	v1, v2, d := 1.5, 2.5, int64(2)
+	_, err = s.CreateOrUpdate(ctx, entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: &v1})
+	assert.NoError(t, err)
+
+	journal.fail = true
+	_, err = s.CreateOrUpdate(ctx, entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: &v2})
+	assert.EqualError(t, err, UnexpectedMetricUpdate)
+	_, err = s.CreateOrUpdateBatch(ctx, []entity.Metrics{
+		{ID: "PollCount", MType: entity.Counter, Delta: &d},
+		{ID: "Alloc", MType: entity.Gauge, Value: &v2},
+	})
+	assert.EqualError(t, err, UnexpectedMetricUpdate)
+	assert.EqualError(t, s.Remove(ctx, "Alloc", entity.Gauge), UnexpectedMetricRemove)
+
+	// изменения, не записанные в журнал, не применены
+	all, err := s.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+	m, err := s.Get(ctx, "Alloc", entity.Gauge)
+	assert.NoError(t, err)
+	assert.Equal(t, v1, *m.Value)
+}
+
+func TestStart_RestoreKeepsUpdatedAt(t *testing.T) {
+	log := logger.New(logger.LevelDebug)
+	ctx := context.Background()
+	updated := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := updated.Add(time.Hour)
+	v, d := 1.5, int64(2)
+
+	t.Run("storage", func(t *testing.T) {
+		s := New(repositoryMemory.New("", log), &MockStorage{
+			LoadDataFunc: func() ([]entity.Metrics, error) {
+				return []entity.Metrics{
+					{ID: "Alloc", MType: entity.Gauge, Value: &v, UpdatedAt: updated},
+					{ID: "PollCount", MType: entity.Counter, Delta: &d},
+				}, nil
+			},
+		}, 300, log)
+		s.now = func() time.Time { return now }
+		s.Start(true)
+		defer s.Stop()
+
+		m, err := s.Get(ctx, "Alloc", entity.Gauge)
+		assert.NoError(t, err)
+		assert.Equal(t, updated, m.UpdatedAt)
+		// без сохранённого времени метрика считается изменённой при восстановлении
+		m, err = s.Get(ctx, "PollCount", entity.Counter)
+		assert.NoError(t, err)
+		assert.Equal(t, now, m.UpdatedAt)
+	})
+
+	t.Run("journal", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "metrics.json")
+		journal, err := storageWAL.New(path, log)
+		assert.NoError(t, err)
+		s := New(repositoryMemory.New("", log), journal, 300, log)
+		s.now = func() time.Time { return updated }
+		s.Start(false)
+		_, err = s.CreateOrUpdate(ctx, entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: &v})
+		assert.NoError(t, err)
+		assert.NoError(t, journal.Close())
+
+		journal, err = storageWAL.New(path, log)
+		assert.NoError(t, err)
+		defer func() {
+			assert.NoError(t, journal.Close())
+		}()
+		restored := New(repositoryMemory.New("", log), journal, 300, log)
+		restored.now = func() time.Time { return now }
+		restored.Start(true)
+		defer restored.Stop()
+
+		m, err := restored.Get(ctx, "Alloc", entity.Gauge)
+		assert.NoError(t, err)
+		assert.Equal(t, updated, m.UpdatedAt)
+	})
+}
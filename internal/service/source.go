@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"maps"
+)
+
+// sourceKey - ключ контекста для идентификатора источника обновления.
+type sourceKey struct{}
+
+// WithSource возвращает контекст с идентификатором источника обновления метрик
+// (идентификатор агента или его IP-адрес). Пустой идентификатор не сохраняется.
+//
+// Параметры:
+//   - ctx: контекст
+//   - source: идентификатор источника
+func WithSource(ctx context.Context, source string) context.Context {
+	if source == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFromContext возвращает идентификатор источника обновления метрик или пустую строку.
+//
+// Параметры:
+//   - ctx: контекст
+func SourceFromContext(ctx context.Context) string {
+	source, _ := ctx.Value(sourceKey{}).(string)
+	return source
+}
+
+// addSource возвращает копию разбивки counter по источникам с добавленным приращением.
+// Без источника разбивка не меняется: приращение учитывается только в общем значении.
+func addSource(sources map[string]int64, source string, delta int64) map[string]int64 {
+	if source == "" {
+		return sources
+	}
+	res := make(map[string]int64, len(sources)+1)
+	maps.Copy(res, sources)
+	res[source] += delta
+	return res
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/common"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/storage"
+	"github.com/Mr-Filatik/go-metrics-collector/proto"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+// Format - формат файла снимка.
+type Format string
+
+// Константы - поддерживаемые форматы файла снимка.
+const (
+	FormatJSON      Format = "json"       // JSON с отступами (по умолчанию)
+	FormatProto     Format = "proto"      // бинарный: заголовок и последовательность proto.SnapshotMetric
+	FormatProtoGzip Format = "proto-gzip" // бинарный, сжатый gzip
+)
+
+// Константы бинарного формата снимка. Файл начинается с заголовка: сигнатура, версия формата
+// и флаги, далее (при флаге сжатия - в gzip) идут сообщения proto.SnapshotMetric с префиксом длины.
+const (
+	binaryMagic     = "GMCS" // сигнатура бинарного снимка
+	binaryVersion   = 2      // версия бинарного формата
+	binaryVersionV1 = 1      // первая версия: сообщения proto.Metric без времени изменения
+	binaryFlagGzip  = 1 << 0 // флаг: данные после заголовка сжаты gzip
+	binaryHeaderLen = len(binaryMagic) + 2
+)
+
+// ParseFormat проверяет название формата снимка (пустая строка - FormatJSON).
+//
+// Параметры:
+//   - s: название формата
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case "":
+		return FormatJSON, nil
+	case FormatJSON, FormatProto, FormatProtoGzip:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown snapshot format %q", s)
+	}
+}
+
+// EncodeSnapshot кодирует метрики в указанном формате.
+//
+// Параметры:
+//   - data: метрики
+//   - format: формат снимка
+func EncodeSnapshot(data []entity.Metrics, format Format) ([]byte, error) {
+	if format != FormatProto && format != FormatProtoGzip {
+		stored := make([]storage.Metric, 0, len(data))
+		for _, m := range data {
+			stored = append(stored, storage.NewMetric(m))
+		}
+		return json.MarshalIndent(stored, "", "  ")
+	}
+
+	var body bytes.Buffer
+	for _, m := range data {
+		if _, err := protodelim.MarshalTo(&body, snapshotToProto(m)); err != nil {
+			return nil, fmt.Errorf("marshal metric %s: %w", m.Key(), err)
+		}
+	}
+
+	var flags byte
+	payload := body.Bytes()
+	if format == FormatProtoGzip {
+		flags |= binaryFlagGzip
+		compressed, err := common.CompressBytes(payload)
+		if err != nil {
+			return nil, err
+		}
+		payload = compressed
+	}
+
+	out := make([]byte, 0, binaryHeaderLen+len(payload))
+	out = append(out, binaryMagic...)
+	out = append(out, binaryVersion, flags)
+	return append(out, payload...), nil
+}
+
+// DecodeSnapshot определяет формат снимка по содержимому и декодирует метрики.
+// Файлы без сигнатуры бинарного формата читаются как JSON.
+//
+// Параметры:
+//   - raw: содержимое снимка
+func DecodeSnapshot(raw []byte) ([]entity.Metrics, error) {
+	if !bytes.HasPrefix(raw, []byte(binaryMagic)) {
+		var stored []storage.Metric
+		if err := json.Unmarshal(raw, &stored); err != nil {
+			return nil, err
+		}
+		metrics := make([]entity.Metrics, 0, len(stored))
+		for _, m := range stored {
+			metrics = append(metrics, m.Entity())
+		}
+		return metrics, nil
+	}
+
+	if len(raw) < binaryHeaderLen {
+		return nil, errors.New("binary snapshot header is truncated")
+	}
+	version, flags := raw[len(binaryMagic)], raw[len(binaryMagic)+1]
+	if version != binaryVersion && version != binaryVersionV1 {
+		return nil, fmt.Errorf("unsupported binary snapshot version %d", version)
+	}
+	payload := raw[binaryHeaderLen:]
+	if flags&binaryFlagGzip != 0 {
+		decompressed, err := common.DecompressBytes(payload)
+		if err != nil {
+			return nil, err
+		}
+		payload = decompressed
+	}
+
+	metrics := make([]entity.Metrics, 0)
+	r := bufio.NewReader(bytes.NewReader(payload))
+	for {
+		sm := &proto.SnapshotMetric{}
+		var err error
+		if version == binaryVersionV1 {
+			sm.Metric = &proto.Metric{}
+			err = protodelim.UnmarshalFrom(r, sm.Metric)
+		} else {
+			err = protodelim.UnmarshalFrom(r, sm)
+		}
+		if errors.Is(err, io.EOF) {
+			return metrics, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal metric %d: %w", len(metrics), err)
+		}
+		metrics = append(metrics, snapshotFromProto(sm))
+	}
+}
+
+// snapshotToProto преобразует метрику в сообщение снимка без потери незаполненных значений.
+func snapshotToProto(m entity.Metrics) *proto.SnapshotMetric {
+	pm := &proto.Metric{
+		Id:      m.ID,
+		Mtype:   m.MType,
+		Value:   m.Value,
+		Delta:   m.Delta,
+		Labels:  m.Labels,
+		Sources: m.Sources,
+	}
+	if h := m.Histogram; h != nil {
+		pm.Histogram = &proto.Histogram{
+			Bounds: h.Bounds,
+			Counts: h.Counts,
+			Sum:    h.Sum,
+			Count:  h.Count,
+		}
+	}
+	sm := &proto.SnapshotMetric{Metric: pm}
+	if !m.UpdatedAt.IsZero() {
+		sm.UpdatedAt = m.UpdatedAt.UnixNano()
+	}
+	return sm
+}
+
+// snapshotFromProto преобразует сообщение снимка в метрику, оставляя незаполненные значения пустыми.
+func snapshotFromProto(sm *proto.SnapshotMetric) entity.Metrics {
+	pm := sm.GetMetric()
+	m := entity.Metrics{
+		ID:      pm.GetId(),
+		MType:   pm.GetMtype(),
+		Labels:  pm.GetLabels(),
+		Sources: pm.GetSources(),
+	}
+	if pm.Value != nil {
+		v := pm.GetValue()
+		m.Value = &v
+	}
+	if pm.Delta != nil {
+		d := pm.GetDelta()
+		m.Delta = &d
+	}
+	if ts := sm.GetUpdatedAt(); ts != 0 {
+		m.UpdatedAt = time.Unix(0, ts).UTC()
+	}
+	if h := pm.GetHistogram(); h != nil {
+		m.Histogram = &entity.HistogramData{
+			Bounds: h.GetBounds(),
+			Counts: h.GetCounts(),
+			Sum:    h.GetSum(),
+			Count:  h.GetCount(),
+		}
+	}
+	return m
+}
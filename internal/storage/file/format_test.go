@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+func formatTestMetrics() []entity.Metrics {
+	return []entity.Metrics{
+		{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(3.14)},
+		{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(0), Labels: map[string]string{"host": "a"}},
+		{ID: "PollCount", MType: entity.Counter, Delta: intPtr(42)},
+		{ID: "PollErrors", MType: entity.Counter, Delta: intPtr(3), Sources: map[string]int64{"agent-1": 1, "agent-2": 2}},
+		{ID: "Latency", MType: entity.Histogram, Histogram: &entity.HistogramData{
+			Bounds: []float64{0.1, 1},
+			Counts: []int64{1, 2, 0},
+			Sum:    1.3,
+			Count:  3,
+		}},
+	}
+}
+
+func TestSnapshotFormats_RoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatProto, FormatProtoGzip} {
+		t.Run(string(format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "metrics.snapshot")
+			storage := New(path, &testutil.MockLogger{}).SetFormat(format)
+
+			data := formatTestMetrics()
+			require.NoError(t, storage.SaveData(data))
+
+			// формат определяется при чтении, поэтому читающему хранилищу формат не задаётся
+			loaded, err := New(path, &testutil.MockLogger{}).LoadData()
+			require.NoError(t, err)
+			assert.Equal(t, data, loaded)
+		})
+	}
+}
+
+func TestLoadData_LegacyJSON(t *testing.T) {
+	data := formatTestMetrics()
+	content, err := json.MarshalIndent(data, "", "  ")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	require.NoError(t, os.WriteFile(path, content, filePermission))
+
+	loaded, err := New(path, &testutil.MockLogger{}).SetFormat(FormatProtoGzip).LoadData()
+	require.NoError(t, err)
+	assert.Equal(t, data, loaded)
+}
+
+func TestEncodeSnapshot_Size(t *testing.T) {
+	data := make([]entity.Metrics, 0, 1000)
+	for i := range 1000 {
+		data = append(data, entity.Metrics{
+			ID:     fmt.Sprintf("metric_%d", i),
+			MType:  entity.Gauge,
+			Value:  floatPtr(float64(i)),
+			Labels: map[string]string{"host": "worker-1", "region": "eu"},
+		})
+	}
+
+	sizes := make(map[Format]int)
+	for _, format := range []Format{FormatJSON, FormatProto, FormatProtoGzip} {
+		raw, err := EncodeSnapshot(data, format)
+		require.NoError(t, err)
+		sizes[format] = len(raw)
+	}
+	assert.Less(t, sizes[FormatProto], sizes[FormatJSON])
+	assert.Less(t, sizes[FormatProtoGzip], sizes[FormatProto])
+}
+
+func TestDecodeSnapshot_Invalid(t *testing.T) {
+	_, err := DecodeSnapshot([]byte(binaryMagic))
+	assert.Error(t, err)
+
+	_, err = DecodeSnapshot([]byte(binaryMagic + "\x03\x00"))
+	assert.EqualError(t, err, "unsupported binary snapshot version 3")
+
+	raw, err := EncodeSnapshot(formatTestMetrics(), FormatProto)
+	require.NoError(t, err)
+	_, err = DecodeSnapshot(raw[:len(raw)-3])
+	assert.Error(t, err)
+}
+
+func TestDecodeSnapshot_BinaryV1(t *testing.T) {
+	// снимок первой версии: сообщения proto.Metric без обёртки и времени изменения
+	raw := bytes.NewBufferString(binaryMagic + "\x01\x00")
+	for _, m := range formatTestMetrics() {
+		_, err := protodelim.MarshalTo(raw, snapshotToProto(m).GetMetric())
+		require.NoError(t, err)
+	}
+
+	loaded, err := DecodeSnapshot(raw.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, formatTestMetrics(), loaded)
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, f)
+
+	f, err = ParseFormat("proto-gzip")
+	require.NoError(t, err)
+	assert.Equal(t, FormatProtoGzip, f)
+
+	_, err = ParseFormat("xml")
+	assert.Error(t, err)
+}
+
+func TestSnapshotFormats_UpdatedAt(t *testing.T) {
+	updated := time.Date(2025, 1, 1, 12, 0, 0, 500, time.UTC)
+	data := []entity.Metrics{
+		{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(3.14), UpdatedAt: updated},
+		{ID: "PollCount", MType: entity.Counter, Delta: intPtr(42)},
+	}
+
+	for _, format := range []Format{FormatJSON, FormatProto, FormatProtoGzip} {
+		t.Run(string(format), func(t *testing.T) {
+			raw, err := EncodeSnapshot(data, format)
+			require.NoError(t, err)
+
+			loaded, err := DecodeSnapshot(raw)
+			require.NoError(t, err)
+			assert.Equal(t, data, loaded)
+		})
+	}
+}
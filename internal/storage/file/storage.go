@@ -3,9 +3,11 @@
 package storage
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
 	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
@@ -13,13 +15,27 @@ import (
 
 // Костанты для работы с файловой системой.
 const (
-	filePermission os.FileMode = 0o600 // разрешения для работы с файлом
+	filePermission     os.FileMode = 0o600    // разрешения для работы с файлом
+	tempPattern                    = ".tmp-*" // суффикс имени временного файла снимка
+	defaultGenerations             = 1        // количество хранимых поколений снимка по умолчанию
+)
+
+// Ошибки чтения снимка.
+var (
+	errFileNotExist = errors.New("file does not exist")
+	errFileRead     = errors.New("failed to read metrics from file")
+	errFileDecode   = errors.New("failed to deserialize metrics")
 )
 
 // FileStorage реализация хранилища для файловой системы.
+// Снимок записывается во временный файл и атомарно переименовывается в целевой,
+// предыдущие снимки хранятся рядом с суффиксами .1, .2, ... (чем больше номер, тем старше снимок).
 type FileStorage struct {
-	log      logger.Logger // логгер
-	filePath string        // путь до файла
+	log         logger.Logger // логгер
+	filePath    string        // путь до файла
+	format      Format        // формат записи снимка (при чтении формат определяется по содержимому)
+	generations int           // количество хранимых поколений снимка, включая текущее
+	mu          sync.Mutex    // защита от одновременной записи снимков
 }
 
 // New создаёт и инициализирует новый экзепляр *FileStorage.
@@ -29,45 +45,182 @@ type FileStorage struct {
 //   - log: логгер
 func New(filePath string, log logger.Logger) *FileStorage {
 	return &FileStorage{
-		filePath: filePath,
-		log:      log,
+		filePath:    filePath,
+		log:         log,
+		format:      FormatJSON,
+		generations: defaultGenerations,
 	}
 }
 
-// LoadData загружает данные из хранилища в приложение.
-func (s *FileStorage) LoadData() ([]entity.Metrics, error) {
-	if _, err := os.Stat(s.filePath); os.IsNotExist(err) {
-		return make([]entity.Metrics, 0), errors.New("file does not exist")
-	}
+// SetGenerations задаёт количество хранимых поколений снимка, включая текущее.
+// При загрузке используется самое новое поколение, которое удалось прочитать.
+//
+// Параметры:
+//   - n: количество поколений (не меньше 1)
+func (s *FileStorage) SetGenerations(n int) *FileStorage {
+	s.generations = max(n, 1)
+	return s
+}
 
-	data, err := os.ReadFile(s.filePath)
-	if err != nil {
-		return make([]entity.Metrics, 0), errors.New("failed to read metrics from file")
-	}
+// SetFormat задаёт формат записи снимка. Снимки в любом из форматов читаются независимо от него.
+//
+// Параметры:
+//   - f: формат снимка
+func (s *FileStorage) SetFormat(f Format) *FileStorage {
+	s.format = f
+	return s
+}
 
-	var metrics []entity.Metrics
-	err = json.Unmarshal(data, &metrics)
-	if err != nil {
-		return make([]entity.Metrics, 0), errors.New("failed to deserialize metrics")
+// LoadData загружает данные из самого нового корректного поколения снимка.
+func (s *FileStorage) LoadData() ([]entity.Metrics, error) {
+	loadErr := errFileNotExist
+	for gen := range s.generations {
+		path := s.generationPath(gen)
+		metrics, err := readSnapshot(path)
+		if err == nil {
+			s.log.Info("Load data from snapshot", "path", path, "generation", gen)
+			return metrics, nil
+		}
+		if errors.Is(err, errFileNotExist) {
+			continue
+		}
+		s.log.Warn("Skip unreadable snapshot", err, "path", path, "generation", gen)
+		if errors.Is(loadErr, errFileNotExist) {
+			loadErr = err
+		}
 	}
-
-	return metrics, nil
+	return make([]entity.Metrics, 0), loadErr
 }
 
 // SaveData сохраняет данные приложения в хранилища.
+// Данные записываются во временный файл, который после fsync атомарно заменяет текущий снимок,
+// поэтому сбой во время записи не повреждает уже сохранённые снимки.
 //
 // Параметры:
 //   - data: метрики
 func (s *FileStorage) SaveData(data []entity.Metrics) error {
-	fd, err := json.MarshalIndent(data, "", "  ")
+	fd, err := EncodeSnapshot(data, s.format)
 	if err != nil {
 		return errors.New("failed to serialize metrics")
 	}
 
-	err = os.WriteFile(s.filePath, fd, filePermission)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeStaleTemp()
+	tmpPath, err := s.writeTemp(fd)
 	if err != nil {
+		s.log.Error("Write snapshot error", err, "path", s.filePath)
 		return errors.New("failed to write metrics to file")
 	}
+	if err := s.rotate(); err != nil {
+		_ = os.Remove(tmpPath)
+		s.log.Error("Rotate snapshots error", err, "path", s.filePath)
+		return errors.New("failed to rotate metrics files")
+	}
+	if err := os.Rename(tmpPath, s.filePath); err != nil {
+		_ = os.Remove(tmpPath)
+		s.log.Error("Rename snapshot error", err, "path", s.filePath)
+		return errors.New("failed to write metrics to file")
+	}
+	syncDir(filepath.Dir(s.filePath))
+
+	return nil
+}
+
+// generationPath возвращает путь до поколения снимка (0 - текущий снимок).
+func (s *FileStorage) generationPath(gen int) string {
+	if gen == 0 {
+		return s.filePath
+	}
+	return fmt.Sprintf("%s.%d", s.filePath, gen)
+}
+
+// writeTemp записывает данные во временный файл рядом с целевым и дожидается их сохранения на диск.
+func (s *FileStorage) writeTemp(data []byte) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(s.filePath), filepath.Base(s.filePath)+tempPattern)
+	if err != nil {
+		return "", err
+	}
+	if err := f.Chmod(filePermission); err != nil {
+		return "", errors.Join(err, f.Close(), os.Remove(f.Name()))
+	}
+	if _, err := f.Write(data); err != nil {
+		return "", errors.Join(err, f.Close(), os.Remove(f.Name()))
+	}
+	if err := f.Sync(); err != nil {
+		return "", errors.Join(err, f.Close(), os.Remove(f.Name()))
+	}
+	if err := f.Close(); err != nil {
+		return "", errors.Join(err, os.Remove(f.Name()))
+	}
+	return f.Name(), nil
+}
+
+// rotate сдвигает поколения снимка: самое старое удаляется, текущий снимок становится поколением 1.
+// Текущий снимок связывается жёсткой ссылкой, чтобы до переименования нового снимка файл filePath не пропадал.
+func (s *FileStorage) rotate() error {
+	if s.generations < 2 {
+		return nil
+	}
+	for gen := s.generations - 2; gen >= 1; gen-- {
+		err := os.Rename(s.generationPath(gen), s.generationPath(gen+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
 
+	prev := s.generationPath(1)
+	if err := os.Remove(prev); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	err := os.Link(s.filePath, prev)
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	// файловая система без жёстких ссылок: до переименования нового снимка текущим будет поколение 1
+	if err := os.Rename(s.filePath, prev); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
 	return nil
 }
+
+// removeStaleTemp удаляет временные файлы, оставшиеся после прерванной записи снимка.
+func (s *FileStorage) removeStaleTemp() {
+	stale, _ := filepath.Glob(s.filePath + tempPattern)
+	for _, path := range stale {
+		if err := os.Remove(path); err == nil {
+			s.log.Info("Remove stale temporary snapshot", "path", path)
+		}
+	}
+}
+
+// readSnapshot читает и разбирает файл снимка.
+func readSnapshot(path string) ([]entity.Metrics, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, errFileNotExist
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errFileRead
+	}
+
+	metrics, err := DecodeSnapshot(data)
+	if err != nil {
+		return nil, errFileDecode
+	}
+
+	return metrics, nil
+}
+
+// syncDir сохраняет на диск изменения каталога (переименование файлов).
+// Ошибки игнорируются: не на всех платформах каталог можно открыть для fsync.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
@@ -110,3 +111,54 @@ func TestLoadData_InvalidJSON(t *testing.T) {
 	assert.Equal(t, "failed to deserialize metrics", err.Error())
 	assert.Empty(t, data)
 }
+
+func TestSaveData_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	storage := New(path, &testutil.MockLogger{}).SetGenerations(3)
+
+	for i := range 4 {
+		require.NoError(t, storage.SaveData([]entity.Metrics{{ID: "PollCount", MType: "counter", Delta: intPtr(int64(i))}}))
+	}
+
+	// хранятся три поколения: текущее и два предыдущих
+	for gen, want := range []int64{3, 2, 1} {
+		loaded, err := readSnapshot(storage.generationPath(gen))
+		require.NoError(t, err)
+		assert.Equal(t, want, *loaded[0].Delta)
+	}
+	_, err := os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	// временные файлы не остаются
+	temp, err := filepath.Glob(path + tempPattern)
+	require.NoError(t, err)
+	assert.Empty(t, temp)
+}
+
+func TestLoadData_FallbackGeneration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	mockLog := &testutil.MockLogger{}
+	storage := New(path, mockLog).SetGenerations(3)
+
+	data := []entity.Metrics{{ID: "gauge1", MType: "gauge", Value: floatPtr(1.5)}}
+	require.NoError(t, storage.SaveData(data))
+	require.NoError(t, storage.SaveData(data))
+
+	// текущий снимок обрезан сбоем во время записи
+	require.NoError(t, os.WriteFile(path, []byte(`[{"id":"gauge1","ty`), filePermission))
+
+	loaded, err := storage.LoadData()
+	require.NoError(t, err)
+	assert.Equal(t, data, loaded)
+}
+
+func TestLoadData_AllGenerationsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	storage := New(path, &testutil.MockLogger{}).SetGenerations(2)
+
+	require.NoError(t, os.WriteFile(path, []byte("invalid json {]"), filePermission))
+
+	data, err := storage.LoadData()
+	assert.EqualError(t, err, "failed to deserialize metrics")
+	assert.Empty(t, data)
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Константы подписи запросов AWS Signature Version 4.
+const (
+	signAlgorithm   = "AWS4-HMAC-SHA256"     // алгоритм подписи
+	signService     = "s3"                   // сервис, для которого подписывается запрос
+	signTerminator  = "aws4_request"         // завершающая часть области действия подписи
+	signTimeFormat  = "20060102T150405Z"     // формат времени подписи
+	signDateFormat  = "20060102"             // формат даты области действия подписи
+	headerDate      = "X-Amz-Date"           // заголовок времени подписи
+	headerContentSH = "X-Amz-Content-Sha256" // заголовок хэша тела запроса
+	headerToken     = "X-Amz-Security-Token" // заголовок токена временных учётных данных
+	headerAuth      = "Authorization"        // заголовок подписи
+	emptyPayloadSH  = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// credentials - учётные данные для подписи запросов.
+type credentials struct {
+	accessKey    string // идентификатор ключа доступа
+	secretKey    string // секретный ключ доступа
+	sessionToken string // токен временных учётных данных (пусто - не используется)
+}
+
+// signRequest подписывает запрос по AWS Signature Version 4. Подписываются заголовок Host
+// и все заголовки, уже установленные в запросе.
+//
+// Параметры:
+//   - req: запрос
+//   - payloadHash: SHA256 тела запроса в hex
+//   - creds: учётные данные
+//   - region: регион
+//   - now: время подписи
+func signRequest(req *http.Request, payloadHash string, creds credentials, region string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set(headerDate, now.Format(signTimeFormat))
+	req.Header.Set(headerContentSH, payloadHash)
+	if creds.sessionToken != "" {
+		req.Header.Set(headerToken, creds.sessionToken)
+	}
+
+	headers, signedHeaders := canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format(signDateFormat), region, signService, signTerminator}, "/")
+	stringToSign := strings.Join([]string{
+		signAlgorithm,
+		now.Format(signTimeFormat),
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretKey), now.Format(signDateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, signService)
+	key = hmacSHA256(key, signTerminator)
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set(headerAuth, signAlgorithm+" Credential="+creds.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalHeaders возвращает канонические заголовки и список подписанных заголовков.
+func canonicalHeaders(req *http.Request) (string, string) {
+	values := map[string]string{"host": req.Host}
+	if req.Host == "" {
+		values["host"] = req.URL.Host
+	}
+	for name, vals := range req.Header {
+		name = strings.ToLower(name)
+		if name == "authorization" {
+			continue
+		}
+		trimmed := make([]string, 0, len(vals))
+		for _, v := range vals {
+			trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(values[name])
+		b.WriteByte('\n')
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// canonicalURI кодирует путь запроса: каждый сегмент пути кодируется отдельно.
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		unescaped, err := url.PathUnescape(s)
+		if err != nil {
+			unescaped = s
+		}
+		segments[i] = uriEncode(unescaped)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery кодирует параметры запроса, отсортированные по имени и значению.
+func canonicalQuery(q url.Values) string {
+	pairs := make([]string, 0, len(q))
+	for name, vals := range q {
+		for _, v := range vals {
+			pairs = append(pairs, uriEncode(name)+"="+uriEncode(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode кодирует строку по правилам подписи: не кодируются только A-Z, a-z, 0-9, '-', '.', '_' и '~'.
+func uriEncode(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0x0f])
+		}
+	}
+	return b.String()
+}
+
+// hashHex возвращает SHA256 данных в hex.
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 возвращает HMAC SHA256 данных.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
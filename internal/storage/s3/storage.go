@@ -0,0 +1,378 @@
+// Пакет storage предоставляет реализацию хранилища снимков данных
+// в S3-совместимом объектном хранилище (AWS S3, MinIO и т.п.).
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/storage"
+	fileStorage "github.com/Mr-Filatik/go-metrics-collector/internal/storage/file"
+)
+
+// Scheme - схема строки подключения: s3://бакет/префикс?endpoint=http://host:port&region=регион.
+const Scheme = "s3"
+
+// Константы для работы с объектным хранилищем.
+const (
+	keyPrefix       = "snapshot-"                  // префикс имени объекта снимка
+	keyTimeFormat   = "20060102T150405.000000000Z" // формат времени в имени объекта (сортируется как строка)
+	defaultEndpoint = "https://s3.amazonaws.com"   // адрес хранилища по умолчанию
+	defaultRegion   = "us-east-1"                  // регион по умолчанию
+	defaultTimeout  = 30 * time.Second             // время ожидания запросов одной операции
+	contentType     = "application/octet-stream"   // тип содержимого объекта снимка
+	maxErrorBody    = 1024                         // сколько байт ответа с ошибкой попадает в текст ошибки
+)
+
+// Константы - переменные окружения с учётными данными.
+const (
+	envAccessKey = "AWS_ACCESS_KEY_ID"     // идентификатор ключа доступа
+	envSecretKey = "AWS_SECRET_ACCESS_KEY" // секретный ключ доступа
+	envToken     = "AWS_SESSION_TOKEN"     // токен временных учётных данных
+	envRegion    = "AWS_REGION"            // регион
+)
+
+// Константы - основные ошибки хранилища.
+const (
+	errNoSnapshot  = "snapshot does not exist"         // ошибка, в хранилище нет снимков
+	errListFailure = "failed to list snapshots"        // ошибка получения списка снимков
+	errLoadFailure = "failed to load metrics snapshot" // ошибка, ни один снимок не удалось прочитать
+)
+
+// Config - параметры подключения к объектному хранилищу.
+type Config struct {
+	Endpoint     string             // адрес хранилища (http(s)://host:port), запросы в стиле path: адрес/бакет/ключ
+	Region       string             // регион
+	Bucket       string             // бакет
+	Prefix       string             // префикс ключей снимков внутри бакета
+	AccessKey    string             // идентификатор ключа доступа
+	SecretKey    string             // секретный ключ доступа
+	SessionToken string             // токен временных учётных данных (пусто - не используется)
+	Format       fileStorage.Format // формат записи снимка
+	Generations  int                // количество хранимых снимков, включая последний
+}
+
+// S3Storage хранилище снимков данных в S3-совместимом объектном хранилище.
+// Каждый снимок сохраняется отдельным объектом с временем сохранения в ключе,
+// при загрузке используется самый новый снимок, который удалось прочитать.
+type S3Storage struct {
+	log     logger.Logger    // логгер
+	client  *http.Client     // HTTP клиент
+	now     func() time.Time // источник текущего времени
+	cfg     Config           // параметры подключения
+	lastKey string           // ключ последнего сохранённого снимка
+	seeded  bool             // lastKey прочитан из списка снимков хранилища
+	mu      sync.Mutex       // защита от одновременной записи снимков
+}
+
+var _ storage.Storage = (*S3Storage)(nil)
+
+// New создаёт и инициализирует новый экзепляр *S3Storage.
+//
+// Параметры:
+//   - cfg: параметры подключения
+//   - log: логгер
+func New(cfg Config, log logger.Logger) (*S3Storage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket are required")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("s3 credentials are required (%s, %s)", envAccessKey, envSecretKey)
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+	cfg.Generations = max(cfg.Generations, 1)
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+
+	return &S3Storage{
+		log:    log,
+		client: &http.Client{Timeout: defaultTimeout},
+		now:    time.Now,
+		cfg:    cfg,
+	}, nil
+}
+
+// ParseDSN разбирает строку подключения вида
+// s3://бакет[/префикс][?endpoint=http://host:port&region=регион&generations=N&format=F].
+// Учётные данные и регион по умолчанию берутся из переменных окружения AWS_*.
+//
+// Параметры:
+//   - dsn: строка подключения
+//   - getenv: функция чтения переменных окружения
+func ParseDSN(dsn string, getenv func(string) string) (Config, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme != Scheme || u.Host == "" {
+		return Config{}, fmt.Errorf("expected %s://bucket/prefix", Scheme)
+	}
+	query := u.Query()
+
+	cfg := Config{
+		Endpoint:     query.Get("endpoint"),
+		Region:       query.Get("region"),
+		Bucket:       u.Host,
+		Prefix:       strings.TrimPrefix(u.Path, "/"),
+		AccessKey:    getenv(envAccessKey),
+		SecretKey:    getenv(envSecretKey),
+		SessionToken: getenv(envToken),
+		Generations:  1,
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultEndpoint
+	}
+	if cfg.Region == "" {
+		cfg.Region = getenv(envRegion)
+	}
+	if cfg.Prefix != "" && !strings.HasSuffix(cfg.Prefix, "/") {
+		cfg.Prefix += "/"
+	}
+	if v := query.Get("generations"); v != "" {
+		cfg.Generations, err = strconv.Atoi(v)
+		if err != nil || cfg.Generations < 1 {
+			return Config{}, fmt.Errorf("invalid generations %q", v)
+		}
+	}
+	cfg.Format, err = fileStorage.ParseFormat(query.Get("format"))
+	if err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// LoadData загружает данные из самого нового снимка, который удалось прочитать.
+func (s *S3Storage) LoadData() ([]entity.Metrics, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	keys, err := s.listSnapshots(ctx)
+	if err != nil {
+		s.log.Error("List snapshots error", err, "bucket", s.cfg.Bucket, "prefix", s.cfg.Prefix)
+		return make([]entity.Metrics, 0), errors.New(errListFailure)
+	}
+	if len(keys) == 0 {
+		return make([]entity.Metrics, 0), errors.New(errNoSnapshot)
+	}
+
+	for i := len(keys) - 1; i >= 0; i-- {
+		raw, err := s.getObject(ctx, keys[i])
+		if err == nil {
+			var metrics []entity.Metrics
+			metrics, err = fileStorage.DecodeSnapshot(raw)
+			if err == nil {
+				s.log.Info("Load data from snapshot", "bucket", s.cfg.Bucket, "key", keys[i])
+				return metrics, nil
+			}
+		}
+		s.log.Warn("Skip unreadable snapshot", err, "bucket", s.cfg.Bucket, "key", keys[i])
+	}
+	return make([]entity.Metrics, 0), errors.New(errLoadFailure)
+}
+
+// SaveData сохраняет данные новым снимком и удаляет снимки старше заданного количества поколений.
+//
+// Параметры:
+//   - data: метрики
+func (s *S3Storage) SaveData(data []entity.Metrics) error {
+	body, err := fileStorage.EncodeSnapshot(data, s.cfg.Format)
+	if err != nil {
+		return errors.New("failed to serialize metrics")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	// часы после перезапуска могут отставать от времени в ключах уже сохранённых снимков
+	if !s.seeded {
+		keys, err := s.listSnapshots(ctx)
+		if err != nil {
+			s.log.Error("List snapshots error", err, "bucket", s.cfg.Bucket, "prefix", s.cfg.Prefix)
+			return errors.New(errListFailure)
+		}
+		// ключи с другим форматом имени не учитываются: по ним нельзя выбрать время нового снимка
+		for i := len(keys) - 1; i >= 0; i-- {
+			if _, ok := s.keyTime(keys[i]); ok {
+				s.lastKey = max(s.lastKey, keys[i])
+				break
+			}
+		}
+		s.seeded = true
+	}
+
+	key := s.nextKey()
+	if err := s.putObject(ctx, key, body); err != nil {
+		s.log.Error("Upload snapshot error", err, "bucket", s.cfg.Bucket, "key", key)
+		return errors.New("failed to upload metrics snapshot")
+	}
+	s.lastKey = key
+
+	// снимок уже сохранён, ошибка удаления старых снимков только логируется
+	if err := s.pruneSnapshots(ctx); err != nil {
+		s.log.Warn("Remove old snapshots error", err, "bucket", s.cfg.Bucket, "prefix", s.cfg.Prefix)
+	}
+	return nil
+}
+
+// nextKey возвращает ключ нового снимка, который при сортировке всегда следует за предыдущим,
+// в том числе за последним снимком в хранилище на момент первого сохранения.
+func (s *S3Storage) nextKey() string {
+	t := s.now().UTC()
+	if last, ok := s.keyTime(s.lastKey); ok && !t.After(last) {
+		t = last.Add(time.Nanosecond)
+	}
+	return s.cfg.Prefix + keyPrefix + t.Format(keyTimeFormat)
+}
+
+// keyTime возвращает время сохранения снимка из его ключа.
+func (s *S3Storage) keyTime(key string) (time.Time, bool) {
+	value, ok := strings.CutPrefix(key, s.cfg.Prefix+keyPrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(keyTimeFormat, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// pruneSnapshots удаляет самые старые снимки сверх заданного количества поколений.
+func (s *S3Storage) pruneSnapshots(ctx context.Context) error {
+	keys, err := s.listSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	if len(keys) <= s.cfg.Generations {
+		return nil
+	}
+
+	var errs []error
+	for _, key := range keys[:len(keys)-s.cfg.Generations] {
+		if err := s.deleteObject(ctx, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// listBucketResult - ответ ListObjectsV2.
+type listBucketResult struct {
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated bool `xml:"IsTruncated"`
+}
+
+// listSnapshots возвращает ключи снимков по возрастанию (от старых к новым).
+func (s *S3Storage) listSnapshots(ctx context.Context) ([]string, error) {
+	prefix := s.cfg.Prefix + keyPrefix
+	keys := make([]string, 0)
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listBucketResult
+		err = xml.Unmarshal(resp, &result)
+		if err != nil {
+			return nil, fmt.Errorf("decode list response: %w", err)
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// getObject читает объект.
+func (s *S3Storage) getObject(ctx context.Context, key string) ([]byte, error) {
+	return s.do(ctx, http.MethodGet, key, nil, nil)
+}
+
+// putObject записывает объект.
+func (s *S3Storage) putObject(ctx context.Context, key string, body []byte) error {
+	_, err := s.do(ctx, http.MethodPut, key, nil, body)
+	return err
+}
+
+// deleteObject удаляет объект.
+func (s *S3Storage) deleteObject(ctx context.Context, key string) error {
+	_, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+	return err
+}
+
+// do выполняет подписанный запрос к объекту бакета (или к самому бакету, если key пустой)
+// и возвращает тело ответа. Ответ с кодом не 2xx возвращается как ошибка.
+func (s *S3Storage) do(ctx context.Context, method string, key string, query url.Values, body []byte) ([]byte, error) {
+	target := s.cfg.Endpoint + "/" + uriEncode(s.cfg.Bucket)
+	if key != "" {
+		segments := strings.Split(key, "/")
+		for i, seg := range segments {
+			segments[i] = uriEncode(seg)
+		}
+		target += "/" + strings.Join(segments, "/")
+	}
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	payloadHash := emptyPayloadSH
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+		payloadHash = hashHex(body)
+	}
+	creds := credentials{accessKey: s.cfg.AccessKey, secretKey: s.cfg.SecretKey, sessionToken: s.cfg.SessionToken}
+	signRequest(req, payloadHash, creds, s.cfg.Region, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, key, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		if len(data) > maxErrorBody {
+			data = data[:maxErrorBody]
+		}
+		return nil, fmt.Errorf("%s %s: unexpected status %d: %s", method, key, resp.StatusCode, data)
+	}
+	return data, nil
+}
@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	fileStorage "github.com/Mr-Filatik/go-metrics-collector/internal/storage/file"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBucket    = "metrics"
+	testAccessKey = "test-access-key"
+	testSecretKey = "test-secret-key"
+	testRegion    = "eu-central-1"
+	testPageSize  = 2 // размер страницы списка объектов, чтобы проверить постраничное чтение
+)
+
+// fakeS3 - S3-совместимый сервер в памяти: проверяет подпись запросов
+// и поддерживает PUT, GET и DELETE объектов и ListObjectsV2.
+type fakeS3 struct {
+	t       *testing.T
+	objects map[string][]byte
+	mu      sync.Mutex
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	t.Helper()
+	f := &fakeS3{t: t, objects: make(map[string][]byte)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.validSignature(r) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != testBucket {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, r)
+	case r.Method == http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		require.NoError(f.t, err)
+		f.objects[key] = body
+	case r.Method == http.MethodGet:
+		body, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(body)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// validSignature пересчитывает подпись запроса секретным ключом сервера.
+func (f *fakeS3) validSignature(r *http.Request) bool {
+	auth := r.Header.Get(headerAuth)
+	if !strings.HasPrefix(auth, signAlgorithm+" Credential="+testAccessKey+"/") {
+		return false
+	}
+	date, err := time.Parse(signTimeFormat, r.Header.Get(headerDate))
+	if err != nil {
+		return false
+	}
+
+	body, err := io.ReadAll(r.Body)
+	require.NoError(f.t, err)
+	r.Body = io.NopCloser(strings.NewReader(string(body)))
+	if hashHex(body) != r.Header.Get(headerContentSH) {
+		return false
+	}
+
+	// подписываются только заголовки, перечисленные клиентом
+	_, signed, _ := strings.Cut(auth, "SignedHeaders=")
+	signed, _, _ = strings.Cut(signed, ",")
+	check := r.Clone(r.Context())
+	check.Header = http.Header{}
+	for _, name := range strings.Split(signed, ";") {
+		if name != "host" {
+			check.Header.Set(name, r.Header.Get(name))
+		}
+	}
+	check.URL.Host = r.Host
+	creds := credentials{accessKey: testAccessKey, secretKey: testSecretKey}
+	signRequest(check, r.Header.Get(headerContentSH), creds, testRegion, date)
+	return check.Header.Get(headerAuth) == auth
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	keys := make([]string, 0)
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	start := 0
+	if token := r.URL.Query().Get("continuation-token"); token != "" {
+		start, _ = strconv.Atoi(token)
+	}
+	end := min(start+testPageSize, len(keys))
+
+	type content struct {
+		Key string `xml:"Key"`
+	}
+	result := struct {
+		XMLName               xml.Name  `xml:"ListBucketResult"`
+		NextContinuationToken string    `xml:"NextContinuationToken,omitempty"`
+		Contents              []content `xml:"Contents"`
+		IsTruncated           bool      `xml:"IsTruncated"`
+	}{IsTruncated: end < len(keys)}
+	if result.IsTruncated {
+		result.NextContinuationToken = strconv.Itoa(end)
+	}
+	for _, key := range keys[start:end] {
+		result.Contents = append(result.Contents, content{Key: key})
+	}
+	require.NoError(f.t, xml.NewEncoder(w).Encode(result))
+}
+
+func (f *fakeS3) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newTestStorage(t *testing.T, endpoint string, generations int) *S3Storage {
+	t.Helper()
+	s, err := New(Config{
+		Endpoint:    endpoint,
+		Region:      testRegion,
+		Bucket:      testBucket,
+		Prefix:      "server-1/",
+		AccessKey:   testAccessKey,
+		SecretKey:   testSecretKey,
+		Format:      fileStorage.FormatProtoGzip,
+		Generations: generations,
+	}, &testutil.MockLogger{})
+	require.NoError(t, err)
+
+	// фиксированное время, чтобы проверить порядок ключей снимков, сохранённых в одну наносекунду
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestS3Storage_SaveAndLoad(t *testing.T) {
+	fake, srv := newFakeS3(t)
+	s := newTestStorage(t, srv.URL, 3)
+
+	for i := range 5 {
+		delta := int64(i)
+		require.NoError(t, s.SaveData([]entity.Metrics{{ID: "PollCount", MType: entity.Counter, Delta: &delta}}))
+	}
+
+	// хранятся три последних снимка с версионированными ключами
+	assert.Equal(t, []string{
+		"server-1/snapshot-20250102T030405.000000002Z",
+		"server-1/snapshot-20250102T030405.000000003Z",
+		"server-1/snapshot-20250102T030405.000000004Z",
+	}, fake.keys())
+
+	// восстановление после перезапуска читает самый новый снимок
+	loaded, err := newTestStorage(t, srv.URL, 3).LoadData()
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, int64(4), *loaded[0].Delta)
+}
+
+func TestS3Storage_SaveAfterClockGoesBack(t *testing.T) {
+	fake, srv := newFakeS3(t)
+	stale := "server-1/snapshot-20250102T040000.000000000Z"
+	fake.objects[stale] = []byte("stale")
+
+	// после перезапуска часы отстают от времени в ключе сохранённого снимка
+	s := newTestStorage(t, srv.URL, 1)
+	delta := int64(7)
+	require.NoError(t, s.SaveData([]entity.Metrics{{ID: "PollCount", MType: entity.Counter, Delta: &delta}}))
+
+	keys := fake.keys()
+	require.Len(t, keys, 1)
+	assert.Greater(t, keys[0], stale, "new snapshot must sort after existing ones")
+
+	loaded, err := s.LoadData()
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, int64(7), *loaded[0].Delta)
+}
+
+func TestS3Storage_LoadFallback(t *testing.T) {
+	fake, srv := newFakeS3(t)
+	s := newTestStorage(t, srv.URL, 2)
+
+	v := 1.5
+	require.NoError(t, s.SaveData([]entity.Metrics{{ID: "Alloc", MType: entity.Gauge, Value: &v}}))
+	require.NoError(t, s.SaveData([]entity.Metrics{{ID: "Alloc", MType: entity.Gauge, Value: &v}}))
+
+	keys := fake.keys()
+	fake.mu.Lock()
+	fake.objects[keys[len(keys)-1]] = []byte("GMCS\x01\x01broken")
+	fake.mu.Unlock()
+
+	loaded, err := s.LoadData()
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Metrics{{ID: "Alloc", MType: entity.Gauge, Value: &v}}, loaded)
+}
+
+func TestS3Storage_Errors(t *testing.T) {
+	_, srv := newFakeS3(t)
+
+	data, err := newTestStorage(t, srv.URL, 1).LoadData()
+	assert.EqualError(t, err, errNoSnapshot)
+	assert.Empty(t, data)
+
+	// неверный секретный ключ: сервер отклоняет подпись
+	s := newTestStorage(t, srv.URL, 1)
+	s.cfg.SecretKey = "wrong"
+	assert.Error(t, s.SaveData(nil))
+	_, err = s.LoadData()
+	assert.EqualError(t, err, errListFailure)
+}
+
+func TestParseDSN(t *testing.T) {
+	env := map[string]string{
+		envAccessKey: "ak",
+		envSecretKey: "sk",
+		envRegion:    "eu-west-1",
+	}
+	getenv := func(key string) string { return env[key] }
+
+	cfg, err := ParseDSN("s3://metrics/prod/server?endpoint=http://localhost:9000&generations=5&format=proto", getenv)
+	require.NoError(t, err)
+	assert.Equal(t, Config{
+		Endpoint:    "http://localhost:9000",
+		Region:      "eu-west-1",
+		Bucket:      "metrics",
+		Prefix:      "prod/server/",
+		AccessKey:   "ak",
+		SecretKey:   "sk",
+		Format:      fileStorage.FormatProto,
+		Generations: 5,
+	}, cfg)
+
+	cfg, err = ParseDSN("s3://metrics?region=us-west-2", getenv)
+	require.NoError(t, err)
+	assert.Equal(t, defaultEndpoint, cfg.Endpoint)
+	assert.Equal(t, "us-west-2", cfg.Region)
+	assert.Equal(t, "", cfg.Prefix)
+	assert.Equal(t, 1, cfg.Generations)
+
+	_, err = ParseDSN("s3:///prefix", getenv)
+	assert.Error(t, err)
+	_, err = ParseDSN("s3://metrics?generations=-1", getenv)
+	assert.Error(t, err)
+}
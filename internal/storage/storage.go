@@ -2,10 +2,80 @@
 // которому должно соотвестовать любое хранилище проекта.
 package storage
 
-import "github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+)
 
 // Storage описание интерфейса для реализации хранилища.
 type Storage interface {
 	LoadData() ([]entity.Metrics, error)  // загрузка данных
 	SaveData(data []entity.Metrics) error // сохранение данных
 }
+
+// Константы - операции записи журнала изменений.
+const (
+	OperationUpdate = "update" // метрика создана или обновлена, запись содержит итоговое значение
+	OperationRemove = "remove" // метрика удалена
+)
+
+// Metric описывает метрику в сохраняемых данных (JSON-снимке и журнале изменений).
+// В отличие от ответов API сохраняет время последнего изменения метрики,
+// чтобы перезапуск сервера не продлевал время её хранения.
+type Metric struct {
+	entity.Metrics
+	UpdatedAt time.Time `json:"updated_at"` // время последнего изменения метрики на сервере (UTC)
+}
+
+// NewMetric возвращает метрику для сохранения.
+//
+// Параметры:
+//   - m: метрика
+func NewMetric(m entity.Metrics) Metric {
+	return Metric{Metrics: m, UpdatedAt: m.UpdatedAt}
+}
+
+// Entity возвращает сохранённую метрику вместе с временем последнего изменения.
+func (m Metric) Entity() entity.Metrics {
+	e := m.Metrics
+	e.UpdatedAt = m.UpdatedAt
+	return e
+}
+
+// Record описывает одну запись журнала изменений.
+type Record struct {
+	Op     string         `json:"op"`     // операция (OperationUpdate или OperationRemove)
+	Metric entity.Metrics `json:"metric"` // метрика
+}
+
+// recordJSON - представление записи журнала изменений в JSON.
+type recordJSON struct {
+	Op     string `json:"op"`
+	Metric Metric `json:"metric"`
+}
+
+// MarshalJSON кодирует запись журнала вместе с временем последнего изменения метрики.
+func (r Record) MarshalJSON() ([]byte, error) {
+	return json.Marshal(recordJSON{Op: r.Op, Metric: NewMetric(r.Metric)})
+}
+
+// UnmarshalJSON декодирует запись журнала вместе с временем последнего изменения метрики.
+func (r *Record) UnmarshalJSON(data []byte) error {
+	var rec recordJSON
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return err
+	}
+	r.Op, r.Metric = rec.Op, rec.Metric.Entity()
+	return nil
+}
+
+// Journal описание интерфейса хранилища, которое дополнительно к снимку данных
+// ведёт журнал изменений (write-ahead log). LoadData возвращает снимок с применённым журналом,
+// SaveData сохраняет новый снимок и очищает журнал.
+type Journal interface {
+	Storage
+	Append(records ...Record) error // запись изменений в журнал
+	Size() int64                    // размер журнала (в байтах)
+}
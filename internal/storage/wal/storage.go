@@ -0,0 +1,233 @@
+// Пакет storage предоставляет реализацию хранилища со снимком данных в файле
+// и журналом изменений (write-ahead log), в который дописывается каждое изменение метрик.
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/logger"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/storage"
+	fileStorage "github.com/Mr-Filatik/go-metrics-collector/internal/storage/file"
+)
+
+// Костанты для работы с файловой системой.
+const (
+	filePermission os.FileMode = 0o600  // разрешения для работы с файлом журнала
+	walSuffix                  = ".wal" // суффикс имени файла журнала относительно файла снимка
+)
+
+// WALStorage хранилище со снимком данных и журналом изменений.
+// Журнал хранит записи storage.Record в формате JSON, по одной на строку.
+type WALStorage struct {
+	log      logger.Logger            // логгер
+	snapshot *fileStorage.FileStorage // хранилище снимка данных
+	file     *os.File                 // файл журнала, открытый на дозапись
+	walPath  string                   // путь до файла журнала
+	size     int64                    // размер журнала (в байтах)
+	mu       sync.Mutex               // защита журнала от конкурентного доступа
+}
+
+var _ storage.Journal = (*WALStorage)(nil)
+
+// New создаёт и инициализирует новый экзепляр *WALStorage.
+// Снимок хранится в filePath, журнал - рядом в файле с суффиксом .wal.
+//
+// Параметры:
+//   - filePath: путь для сохранения снимка
+//   - log: логгер
+func New(filePath string, log logger.Logger) (*WALStorage, error) {
+	walPath := filePath + walSuffix
+	f, err := os.OpenFile(walPath, os.O_CREATE|os.O_RDWR|os.O_APPEND, filePermission)
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("stat journal: %w", err)
+	}
+
+	return &WALStorage{
+		log:      log,
+		snapshot: fileStorage.New(filePath, log),
+		file:     f,
+		walPath:  walPath,
+		size:     info.Size(),
+	}, nil
+}
+
+// SetGenerations задаёт количество хранимых поколений снимка, включая текущее.
+//
+// Параметры:
+//   - n: количество поколений (не меньше 1)
+func (s *WALStorage) SetGenerations(n int) *WALStorage {
+	s.snapshot.SetGenerations(n)
+	return s
+}
+
+// SetFormat задаёт формат записи снимка.
+//
+// Параметры:
+//   - f: формат снимка
+func (s *WALStorage) SetFormat(f fileStorage.Format) *WALStorage {
+	s.snapshot.SetFormat(f)
+	return s
+}
+
+// LoadData загружает снимок и применяет к нему записи журнала.
+// Повреждённый конец журнала (запись, прерванная сбоем) пропускается.
+func (s *WALStorage) LoadData() ([]entity.Metrics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.snapshot.LoadData()
+	if err != nil {
+		// без снимка данные восстанавливаются только из журнала
+		s.log.Warn("Load snapshot error", err)
+		data = make([]entity.Metrics, 0)
+	}
+
+	wal, err := os.ReadFile(s.walPath)
+	if err != nil {
+		return data, errors.New("failed to read journal")
+	}
+	records, err := decodeRecords(wal)
+	if err != nil {
+		s.log.Warn("Journal is truncated, tail records skipped", err, "records", len(records))
+	}
+
+	s.log.Info("Load snapshot and journal", "metrics", len(data), "records", len(records))
+	return replay(data, records), nil
+}
+
+// SaveData сохраняет снимок данных и очищает журнал.
+// Если сбой произойдёт после записи снимка, но до очистки журнала, повторное применение журнала
+// к новому снимку даст тот же результат: записи содержат итоговые значения метрик.
+//
+// Параметры:
+//   - data: метрики
+func (s *WALStorage) SaveData(data []entity.Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.snapshot.SaveData(data); err != nil {
+		return err
+	}
+	if err := s.file.Truncate(0); err != nil {
+		return errors.New("failed to truncate journal")
+	}
+	if err := s.file.Sync(); err != nil {
+		return errors.New("failed to sync journal")
+	}
+	s.size = 0
+	return nil
+}
+
+// Append дописывает записи в журнал и дожидается их сохранения на диск.
+//
+// Параметры:
+//   - records: записи журнала
+func (s *WALStorage) Append(records ...storage.Record) error {
+	var buf bytes.Buffer
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return errors.New("failed to serialize journal record")
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := s.file.Write(buf.Bytes())
+	s.size += int64(n)
+	if err != nil {
+		return errors.New("failed to write journal")
+	}
+	if err := s.file.Sync(); err != nil {
+		return errors.New("failed to sync journal")
+	}
+	return nil
+}
+
+// Size возвращает размер журнала (в байтах).
+func (s *WALStorage) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.size
+}
+
+// Close закрывает файл журнала.
+func (s *WALStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+// decodeRecords разбирает записи журнала. При ошибке возвращает записи, прочитанные до повреждённой строки.
+func decodeRecords(wal []byte) ([]storage.Record, error) {
+	records := make([]storage.Record, 0)
+	sc := bufio.NewScanner(bytes.NewReader(wal))
+	sc.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), len(wal)+1)
+	line := 0
+	for sc.Scan() {
+		line++
+		var r storage.Record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return records, fmt.Errorf("line %d: %w", line, err)
+		}
+		if r.Op != storage.OperationUpdate && r.Op != storage.OperationRemove {
+			return records, fmt.Errorf("line %d: unknown operation %q", line, r.Op)
+		}
+		records = append(records, r)
+	}
+	return records, sc.Err()
+}
+
+// replay применяет записи журнала к снимку. Порядок метрик - по первому появлению ключа.
+func replay(data []entity.Metrics, records []storage.Record) []entity.Metrics {
+	index := make(map[string]int, len(data))
+	metrics := make([]entity.Metrics, 0, len(data))
+	removed := make([]bool, 0, len(data))
+	put := func(m entity.Metrics) {
+		if i, ok := index[m.Key()]; ok {
+			metrics[i], removed[i] = m, false
+			return
+		}
+		index[m.Key()] = len(metrics)
+		metrics = append(metrics, m)
+		removed = append(removed, false)
+	}
+
+	for _, m := range data {
+		put(m)
+	}
+	for _, r := range records {
+		if r.Op == storage.OperationRemove {
+			if i, ok := index[r.Metric.Key()]; ok {
+				removed[i] = true
+			}
+			continue
+		}
+		put(r.Metric)
+	}
+
+	result := make([]entity.Metrics, 0, len(metrics))
+	for i, m := range metrics {
+		if !removed[i] {
+			result = append(result, m)
+		}
+	}
+	return result
+}
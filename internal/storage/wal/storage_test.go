@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Mr-Filatik/go-metrics-collector/internal/entity"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/storage"
+	"github.com/Mr-Filatik/go-metrics-collector/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func floatPtr(f float64) *float64 { return &f }
+func intPtr(i int64) *int64       { return &i }
+
+func newTestStorage(t *testing.T, path string) *WALStorage {
+	t.Helper()
+	s, err := New(path, &testutil.MockLogger{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+	return s
+}
+
+func TestLoadData_ReplaysJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	s := newTestStorage(t, path)
+
+	require.NoError(t, s.SaveData([]entity.Metrics{
+		{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(1)},
+		{ID: "Old", MType: entity.Gauge, Value: floatPtr(5)},
+	}))
+	assert.Equal(t, int64(0), s.Size())
+
+	update := func(m entity.Metrics) storage.Record {
+		return storage.Record{Op: storage.OperationUpdate, Metric: m}
+	}
+	require.NoError(t, s.Append(
+		update(entity.Metrics{ID: "PollCount", MType: entity.Counter, Delta: intPtr(3)}),
+		update(entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(2)}),
+	))
+	require.NoError(t, s.Append(
+		storage.Record{Op: storage.OperationRemove, Metric: entity.Metrics{ID: "Old", MType: entity.Gauge}},
+		update(entity.Metrics{ID: "PollCount", MType: entity.Counter, Delta: intPtr(7)}),
+	))
+	assert.Positive(t, s.Size())
+
+	// журнал читается новым экземпляром, как после перезапуска
+	data, err := newTestStorage(t, path).LoadData()
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Metrics{
+		{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(2)},
+		{ID: "PollCount", MType: entity.Counter, Delta: intPtr(7)},
+	}, data)
+}
+
+func TestSaveData_TruncatesJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	s := newTestStorage(t, path)
+
+	require.NoError(t, s.Append(storage.Record{
+		Op:     storage.OperationUpdate,
+		Metric: entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(1)},
+	}))
+	require.NoError(t, s.SaveData([]entity.Metrics{{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(1)}}))
+
+	info, err := os.Stat(path + walSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+	assert.Equal(t, int64(0), s.Size())
+
+	// после очистки запись продолжается с начала файла
+	require.NoError(t, s.Append(storage.Record{
+		Op:     storage.OperationUpdate,
+		Metric: entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(4)},
+	}))
+	data, err := s.LoadData()
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Metrics{{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(4)}}, data)
+}
+
+func TestLoadData_TruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	wal := `{"op":"update","metric":{"id":"Alloc","type":"gauge","value":1}}` + "\n" +
+		`{"op":"update","metric":{"id":"Alloc","ty`
+	require.NoError(t, os.WriteFile(path+walSuffix, []byte(wal), filePermission))
+
+	// снимка нет: данные восстанавливаются только из журнала
+	data, err := newTestStorage(t, path).LoadData()
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Metrics{{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(1)}}, data)
+}
+
+func TestReplay_Labels(t *testing.T) {
+	labeled := entity.Metrics{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(2), Labels: map[string]string{"host": "a"}}
+	data := replay(
+		[]entity.Metrics{{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(1)}},
+		[]storage.Record{
+			{Op: storage.OperationUpdate, Metric: labeled},
+			{Op: storage.OperationRemove, Metric: entity.Metrics{ID: "Alloc", MType: entity.Gauge}},
+		},
+	)
+	assert.Equal(t, []entity.Metrics{labeled}, data)
+}
+
+func TestLoadData_UpdatedAt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	s := newTestStorage(t, path)
+	updated := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, s.SaveData([]entity.Metrics{
+		{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(1), UpdatedAt: updated},
+	}))
+	require.NoError(t, s.Append(storage.Record{
+		Op:     storage.OperationUpdate,
+		Metric: entity.Metrics{ID: "PollCount", MType: entity.Counter, Delta: intPtr(3), UpdatedAt: updated},
+	}))
+
+	data, err := newTestStorage(t, path).LoadData()
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Metrics{
+		{ID: "Alloc", MType: entity.Gauge, Value: floatPtr(1), UpdatedAt: updated},
+		{ID: "PollCount", MType: entity.Counter, Delta: intPtr(3), UpdatedAt: updated},
+	}, data)
+}